@@ -44,14 +44,15 @@ var (
 		cli.StringSliceFlag{Name: "docker-dns", Value: &cli.StringSlice{}, Usage: "Docker DNS server.", EnvVar: "DOCKER_DNS", Hidden: true},
 		cli.BoolFlag{Name: "docker-local", Usage: "Don't interact with remote repositories"},
 		cli.StringFlag{Name: "checkpoint", Value: "", Usage: "Skip to the next step after a recent build checkpoint."},
-		cli.IntFlag{Name: "docker-cpu-period", Usage: "Set docker CPU period NOTIMPLEMENTED", Hidden: true},
-		cli.IntFlag{Name: "docker-cpu-quota", Usage: "Set docker CPU quota NOTIMPLEMENTED", Hidden: true},
+		cli.IntFlag{Name: "docker-cpu-period", Usage: "Set docker CPU period of the box in microseconds", Hidden: true},
+		cli.IntFlag{Name: "docker-cpu-quota", Usage: "Set docker CPU quota of the box in microseconds per CPU period", Hidden: true},
 		cli.IntFlag{Name: "docker-memory", Usage: "Set docker user memory limit in MB", Hidden: true},
 		cli.IntFlag{Name: "docker-memory-swap", Usage: "Set docker user memory swap limit in MB", Hidden: true},
 		cli.IntFlag{Name: "docker-memory-reservation", Usage: "Set docker user memory soft limit in MB NOTIMPLEMENTED", Hidden: true},
 		cli.IntFlag{Name: "docker-kernel-memory", Usage: "Set docker kernel memory limit in MB NOTIMPLEMENTED", Hidden: true},
 		cli.BoolFlag{Name: "docker-cleanup-image", Usage: "Remove image from the Docker when finished pushing them", Hidden: true},
 		cli.StringFlag{Name: "docker-network", Value: "", Usage: "Docker network name.", Hidden: true},
+		cli.StringFlag{Name: "docker-healthcheck-image", Value: "", Usage: "Image to run the tcp and http healthchecks of services in, it needs nc and wget. Defaults to the box of the pipeline."},
 		cli.StringFlag{Name: "rdd-service-uri", Value: "", Usage: "Rempte Docker Daemon API Service endpoint", Hidden: true},
 		cli.DurationFlag{Name: "rdd-provision-timeout", Value: 300 * time.Second, Usage: "Timeout for Remote Docker Daemon provisioning from Remote Docker Daemon API Service", Hidden: true},
 	}
@@ -112,7 +113,7 @@ var (
 
 	// These flags affect our local execution environment
 	DevFlags = []cli.Flag{
-		cli.StringFlag{Name: "environment", Value: "ENVIRONMENT", Usage: "Specify additional environment variables in a file.", EnvVar: "WERCKER_ENVIRONMENT_FILE"},
+		cli.StringFlag{Name: "environment", Value: "ENVIRONMENT", Usage: "Specify additional environment variables in dotenv files, separated by commas, later files override earlier ones.", EnvVar: "WERCKER_ENVIRONMENT_FILE"},
 		cli.BoolFlag{Name: "verbose", Usage: "Print more information."},
 		cli.BoolFlag{Name: "no-colors", Usage: "Wercker output will not use colors (does not apply to step output)."},
 		cli.BoolFlag{Name: "debug", Usage: "Print additional debug information."},
@@ -142,6 +143,11 @@ var (
 		cli.StringSliceFlag{Name: "publish", Value: &cli.StringSlice{}, Usage: "[Deprecated] Use: --expose-ports. - Publish a port from the main container, same format as docker --publish.", Hidden: true},
 		cli.BoolFlag{Name: "attach-on-error", Usage: "Attach shell to container if a step fails.", Hidden: true},
 		cli.BoolFlag{Name: "enable-volumes", Usage: "Mount local files and directories as volumes to your wercker container, specified in your wercker.yml."},
+		cli.BoolFlag{Name: "matrix-parallel", Usage: "Run the variants of a matrix pipeline in parallel."},
+		cli.BoolFlag{Name: "snapshot-steps", Usage: "Snapshot the box after every step that passed so the build can be resumed."},
+		cli.StringFlag{Name: "resume-from", Value: "", Usage: "Resume from the latest snapshot before this step, or \"failed\" for the step that failed last. Implies --snapshot-steps."},
+		cli.DurationFlag{Name: "snapshot-max-age", Value: 72 * time.Hour, Usage: "Remove snapshots that are older than this."},
+		cli.BoolFlag{Name: "no-step-cache", Usage: "Run steps with a cache-key even if their outputs are cached."},
 		cli.BoolFlag{Name: "enable-dev-steps", Hidden: true, Usage: `
 		Enable internal dev steps.
 		This enables:
@@ -149,6 +155,38 @@ var (
 		`},
 	}
 
+	// Flags to print the resolved pipeline instead of running it
+	PlanFlags = []cli.Flag{
+		cli.BoolFlag{Name: "plan", Usage: "Print the resolved pipeline without running it."},
+		cli.StringFlag{Name: "plan-format", Value: "text", Usage: "Format of --plan, text or json."},
+	}
+
+	// Flags to record the build for wercker replay
+	RecordFlags = []cli.Flag{
+		cli.StringFlag{Name: "record", Value: "", Usage: "Record what the build prints to this file as an asciicast."},
+	}
+
+	// Flags to write the events for other programs
+	OutputFlags = []cli.Flag{
+		cli.StringFlag{Name: "output", Value: "text", Usage: "Format of the build output, text or jsonl for a JSON event per line."},
+		cli.StringFlag{Name: "output-file", Value: "", Usage: "Write the --output=jsonl events to this file instead of stdout."},
+		cli.BoolFlag{Name: "service-logs", Usage: "Show the output of all services, not only of the ones with logs: true in wercker.yml."},
+	}
+
+	// Flags to profile where the time of a run goes
+	ProfileFlags = []cli.Flag{
+		cli.StringFlag{Name: "profile", Value: "", Usage: "Write how long the phases of the run took to this file as a Chrome trace, implies --profile-summary."},
+		cli.BoolFlag{Name: "profile-summary", Usage: "Print how long the phases of the run took at the end, slowest first."},
+	}
+
+	// Flags to post the build events to webhooks, besides the ones in the
+	// notifications section of wercker.yml
+	WebhookFlags = []cli.Flag{
+		cli.StringSliceFlag{Name: "webhook", Value: &cli.StringSlice{}, Usage: "Post the build events to this url, can be given more than once."},
+		cli.StringFlag{Name: "webhook-secret", Value: "", Usage: "Sign the --webhook payloads with HMAC-SHA256 using this key.", EnvVar: "WERCKER_WEBHOOK_SECRET"},
+		cli.StringFlag{Name: "webhook-template", Value: "", Usage: "File with a template for the --webhook payloads."},
+	}
+
 	// Flags for advanced deploy settings
 	InternalDeployFlags = []cli.Flag{
 		cli.BoolFlag{Name: "expose-ports", Usage: "Enable ports from wercker.yml beeing exposed to the host system."},
@@ -184,6 +222,7 @@ var (
 		cli.Float64Flag{Name: "no-response-timeout", Value: 5, Usage: "Timeout if no script output is received in this many minutes."},
 		cli.Float64Flag{Name: "command-timeout", Value: 25, Usage: "Timeout if command does not complete in this many minutes."},
 		cli.StringFlag{Name: "wercker-yml", Value: "", Usage: "Specify a specific yaml file.", EnvVar: "WERCKER_YML_FILE"},
+		cli.BoolFlag{Name: "frozen-lockfile", Usage: "Require every image and step to be pinned in wercker.lock and a --docker-local image to be the pinned one. Tags that moved in the registry since wercker lock aren't noticed, run wercker lock to update them."},
 	}
 
 	// Steps options
@@ -192,6 +231,40 @@ var (
 		cli.BoolFlag{Name: "private", Usage: "Publish the step as private; public by default."},
 	}
 
+	SecretsKeygenFlags = []cli.Flag{
+		cli.BoolFlag{Name: "symmetric", Usage: "Generate a symmetric key instead of an identity and its recipient."},
+	}
+
+	SecretsEncryptFlags = []cli.Flag{
+		cli.StringSliceFlag{Name: "recipient", Value: &cli.StringSlice{}, Usage: "Encrypt for this recipient, can be given more than once."},
+		cli.StringSliceFlag{Name: "key", Value: &cli.StringSlice{}, Usage: "Encrypt for this symmetric key, can be given more than once."},
+		cli.StringFlag{Name: "output", Value: "", Usage: "Write the encrypted file here instead of stdout."},
+	}
+
+	SecretsDecryptFlags = []cli.Flag{
+		cli.StringFlag{Name: "identity", Value: "", Usage: "Decrypt with this identity.", EnvVar: "WERCKER_SECRETS_IDENTITY"},
+		cli.StringFlag{Name: "key", Value: "", Usage: "Decrypt with this symmetric key.", EnvVar: "WERCKER_SECRETS_KEY"},
+	}
+
+	CheckConfigFlagSet = [][]cli.Flag{
+		[]cli.Flag{
+			cli.BoolFlag{Name: "json-schema", Usage: "Print the JSON Schema for wercker.yml and exit."},
+		},
+	}
+
+	LockFlagSet = [][]cli.Flag{
+		[]cli.Flag{
+			cli.BoolFlag{Name: "update", Usage: "Resolve the images and steps again, only the ones named as arguments if there are any."},
+		},
+	}
+
+	ReplayFlagSet = [][]cli.Flag{
+		[]cli.Flag{
+			cli.Float64Flag{Name: "speed", Value: 1, Usage: "Play back this many times faster."},
+			cli.StringFlag{Name: "step", Value: "", Usage: "Start at the step with this name or number."},
+		},
+	}
+
 	PullFlagSet = [][]cli.Flag{
 		[]cli.Flag{
 			cli.StringFlag{Name: "branch", Value: "", Usage: "Filter on this branch."},
@@ -217,6 +290,11 @@ var (
 		WerckerFlags,
 		DockerFlags,
 		InternalBuildFlags,
+		PlanFlags,
+		RecordFlags,
+		OutputFlags,
+		WebhookFlags,
+		ProfileFlags,
 		GitFlags,
 		RegistryFlags,
 		ArtifactFlags,
@@ -229,6 +307,11 @@ var (
 		WerckerFlags,
 		DockerFlags,
 		InternalDeployFlags,
+		PlanFlags,
+		RecordFlags,
+		OutputFlags,
+		WebhookFlags,
+		ProfileFlags,
 		GitFlags,
 		RegistryFlags,
 		ArtifactFlags,
@@ -241,6 +324,11 @@ var (
 		WerckerFlags,
 		DockerFlags,
 		InternalDevFlags,
+		PlanFlags,
+		RecordFlags,
+		OutputFlags,
+		WebhookFlags,
+		ProfileFlags,
 		GitFlags,
 		RegistryFlags,
 		ArtifactFlags,
@@ -0,0 +1,157 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+// cmdLock pins the images and registry steps of all the pipelines in the
+// wercker.yml in the wercker.lock next to it. What is in the wercker.lock
+// already is kept unless update is set, names limits the update to the
+// images and steps with those names.
+func cmdLock(ctx context.Context, options *core.PipelineOptions, dockerOptions *dockerlocal.Options, update bool, names []string) error {
+	logger := util.RootLogger().WithField("Logger", "Lock")
+	f := &util.Formatter{ShowColors: options.GlobalOptions.ShowColors}
+
+	config, werckerYml, err := readWerckerConfig(options)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(filepath.Dir(werckerYml), core.LockfileName)
+	current, err := core.ReadLockfile(path)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		current = core.NewLockfile()
+	}
+
+	// Resolve the tags, not what is locked already
+	lockOptions := *options
+	lockOptions.Lockfile = nil
+	lockOptions.Checkpoint = ""
+
+	refresh := func(name string) bool {
+		return update && (len(names) == 0 || core.LockNameMatches(name, names))
+	}
+
+	ctx = core.NewEmitterContext(ctx)
+	env := options.HostEnv
+	lockfile := core.NewLockfile()
+	for _, boxConfig := range config.LockBoxes() {
+		box, err := dockerlocal.NewDockerBox(boxConfig, &lockOptions, dockerOptions)
+		if err != nil {
+			return err
+		}
+		name := env.Interpolate(box.GetName())
+		if _, ok := lockfile.Images[name]; ok {
+			continue
+		}
+		if digest, ok := current.Images[name]; ok && !refresh(name) {
+			lockfile.Images[name] = digest
+			continue
+		}
+		digest, err := box.Lock(ctx, env)
+		if err != nil {
+			return err
+		}
+		if current.Images[name] != digest {
+			logger.Println(f.Info("Locked "+name, digest))
+		}
+		lockfile.Images[name] = digest
+	}
+
+	for _, stepConfig := range config.LockSteps() {
+		// The internal steps are part of wercker
+		if strings.HasPrefix(stepConfig.ID, "internal/") {
+			continue
+		}
+		step, err := core.NewStep(stepConfig, &lockOptions)
+		if err != nil {
+			return err
+		}
+		if !step.Lockable() {
+			continue
+		}
+		name := step.LockName()
+		if _, ok := lockfile.Steps[name]; ok {
+			continue
+		}
+		if locked, ok := current.Steps[name]; ok && !refresh(name) {
+			lockfile.Steps[name] = locked
+			continue
+		}
+		locked, err := lockStep(step)
+		if err != nil {
+			return err
+		}
+		if previous, ok := current.Steps[name]; !ok || *previous != *locked {
+			logger.Println(f.Info("Locked "+name, locked.Version))
+		}
+		lockfile.Steps[name] = locked
+	}
+
+	for _, name := range names {
+		if !lockfileHas(lockfile, name) {
+			logger.Warnln("Nothing named", name, "in", werckerYml)
+		}
+	}
+	return lockfile.Write(path)
+}
+
+// lockStep resolves the version of step and checksums its tarball
+func lockStep(step *core.ExternalStep) (*core.LockedStep, error) {
+	version, url, err := step.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := util.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	tarball, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &core.LockedStep{
+		Version:  version,
+		URL:      url,
+		Checksum: core.Checksum(tarball),
+	}, nil
+}
+
+// lockfileHas tells whether lockfile has an image or step called name
+func lockfileHas(lockfile *core.Lockfile, name string) bool {
+	for image := range lockfile.Images {
+		if core.LockNameMatches(image, []string{name}) {
+			return true
+		}
+	}
+	for step := range lockfile.Steps {
+		if core.LockNameMatches(step, []string{name}) {
+			return true
+		}
+	}
+	return false
+}
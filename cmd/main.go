@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -51,9 +52,7 @@ var (
 		Usage:     "build a project",
 		Action: func(c *cli.Context) {
 			ctx := context.Background()
-			envfile := c.GlobalString("environment")
-			env := util.NewEnvironment(os.Environ()...)
-			env.LoadFile(envfile)
+			env := loadEnvironment(c)
 
 			settings := util.NewCLISettings(c)
 			opts, err := core.NewBuildOptions(settings, env)
@@ -66,7 +65,7 @@ var (
 				cliLogger.Errorln("Invalid options\n", err)
 				os.Exit(1)
 			}
-			_, err = cmdBuild(ctx, opts, dockerOptions)
+			err = cmdBuildMatrix(ctx, opts, dockerOptions)
 			if err != nil {
 				cliLogger.Fatal(err)
 			}
@@ -79,10 +78,8 @@ var (
 		Usage: "develop and run a local project",
 		Action: func(c *cli.Context) {
 			ctx := context.Background()
-			envfile := c.GlobalString("environment")
 			settings := util.NewCLISettings(c)
-			env := util.NewEnvironment(os.Environ()...)
-			env.LoadFile(envfile)
+			env := loadEnvironment(c)
 			opts, err := core.NewDevOptions(settings, env)
 			if err != nil {
 				cliLogger.Errorln("Invalid options\n", err)
@@ -106,11 +103,16 @@ var (
 		// ShortName: "b",
 		Usage: "check the project's yaml",
 		Action: func(c *cli.Context) {
+			if c.Bool("json-schema") {
+				err := cmdJSONSchema(os.Stdout)
+				if err != nil {
+					cliLogger.Fatal(err)
+				}
+				return
+			}
 			ctx := context.Background()
-			envfile := c.GlobalString("environment")
 			settings := util.NewCLISettings(c)
-			env := util.NewEnvironment(os.Environ()...)
-			env.LoadFile(envfile)
+			env := loadEnvironment(c)
 			opts, err := core.NewCheckConfigOptions(settings, env)
 			if err != nil {
 				cliLogger.Errorln("Invalid options\n", err)
@@ -126,7 +128,32 @@ var (
 				os.Exit(1)
 			}
 		},
-		Flags: FlagsFor(PipelineFlagSet, WerckerInternalFlagSet),
+		Flags: FlagsFor(PipelineFlagSet, WerckerInternalFlagSet, CheckConfigFlagSet),
+	}
+
+	lockCommand = cli.Command{
+		Name:  "lock",
+		Usage: "pin the images and steps in wercker.yml in wercker.lock",
+		Action: func(c *cli.Context) {
+			ctx := context.Background()
+			settings := util.NewCLISettings(c)
+			env := loadEnvironment(c)
+			opts, err := core.NewCheckConfigOptions(settings, env)
+			if err != nil {
+				cliLogger.Errorln("Invalid options\n", err)
+				os.Exit(1)
+			}
+			dockerOptions, err := dockerlocal.NewOptions(ctx, settings, env)
+			if err != nil {
+				cliLogger.Errorln("Invalid options\n", err)
+				os.Exit(1)
+			}
+			err = cmdLock(ctx, opts, dockerOptions, c.Bool("update"), c.Args())
+			if err != nil {
+				cliLogger.Fatal(err)
+			}
+		},
+		Flags: FlagsFor(PipelineFlagSet, WerckerInternalFlagSet, LockFlagSet),
 	}
 
 	deployCommand = cli.Command{
@@ -135,10 +162,8 @@ var (
 		Usage:     "deploy a project",
 		Action: func(c *cli.Context) {
 			ctx := context.Background()
-			envfile := c.GlobalString("environment")
 			settings := util.NewCLISettings(c)
-			env := util.NewEnvironment(os.Environ()...)
-			env.LoadFile(envfile)
+			env := loadEnvironment(c)
 			opts, err := core.NewDeployOptions(settings, env)
 			if err != nil {
 				cliLogger.Errorln("Invalid options\n", err)
@@ -157,6 +182,36 @@ var (
 		Flags: FlagsFor(DeployPipelineFlagSet, WerckerInternalFlagSet),
 	}
 
+	workflowCommand = cli.Command{
+		Name:      "workflow",
+		ShortName: "w",
+		Usage:     "run a workflow from the wercker.yml",
+		Action: func(c *cli.Context) {
+			if len(c.Args()) > 1 {
+				cliLogger.Errorln("Workflow takes the name of the workflow as its only argument")
+				os.Exit(1)
+			}
+			ctx := context.Background()
+			settings := util.NewCLISettings(c)
+			env := loadEnvironment(c)
+			opts, err := core.NewBuildOptions(settings, env)
+			if err != nil {
+				cliLogger.Errorln("Invalid options\n", err)
+				os.Exit(1)
+			}
+			dockerOptions, err := dockerlocal.NewOptions(ctx, settings, env)
+			if err != nil {
+				cliLogger.Errorln("Invalid options\n", err)
+				os.Exit(1)
+			}
+			err = cmdWorkflow(ctx, c.Args().First(), opts, dockerOptions)
+			if err != nil {
+				cliLogger.Fatal(err)
+			}
+		},
+		Flags: FlagsFor(PipelineFlagSet, WerckerInternalFlagSet),
+	}
+
 	detectCommand = cli.Command{
 		Name:      "detect",
 		ShortName: "de",
@@ -276,6 +331,31 @@ var (
 		},
 	}
 
+	replayCommand = cli.Command{
+		Name:        "replay",
+		Usage:       "replay <file>",
+		Description: "play back a build recorded with --record, n jumps to the next step, p to the previous one",
+		Flags:       FlagsFor(ReplayFlagSet),
+		Action: func(c *cli.Context) {
+			if len(c.Args()) != 1 {
+				cliLogger.Errorln("Replay requires the recording as the only argument")
+				os.Exit(1)
+			}
+			settings := util.NewCLISettings(c)
+			env := util.NewEnvironment(os.Environ()...)
+			opts, err := core.NewReplayOptions(settings, env)
+			if err != nil {
+				cliLogger.Errorln("Invalid options\n", err)
+				os.Exit(1)
+			}
+			opts.Path = c.Args().First()
+			err = cmdReplay(opts)
+			if err != nil {
+				cliLogger.Fatal(err)
+			}
+		},
+	}
+
 	versionCommand = cli.Command{
 		Name:      "version",
 		ShortName: "v",
@@ -377,6 +457,54 @@ var (
 		},
 	}
 
+	secretsCommand = cli.Command{
+		Name:  "secrets",
+		Usage: "manage encrypted env files for secret://env-file/ references",
+		Subcommands: []cli.Command{
+			{
+				Name:  "keygen",
+				Usage: "generate an identity and its recipient, or a symmetric key",
+				Action: func(c *cli.Context) {
+					err := cmdSecretsKeygen(os.Stdout, c.Bool("symmetric"))
+					if err != nil {
+						cliLogger.Fatal(err)
+					}
+				},
+				Flags: SecretsKeygenFlags,
+			},
+			{
+				Name:  "encrypt",
+				Usage: "encrypt <env file>",
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 1 {
+						cliLogger.Errorln("Encrypt requires the env file as the only argument")
+						os.Exit(1)
+					}
+					err := cmdSecretsEncrypt(c.Args().First(), c.String("output"), c.StringSlice("key"), c.StringSlice("recipient"))
+					if err != nil {
+						cliLogger.Fatal(err)
+					}
+				},
+				Flags: SecretsEncryptFlags,
+			},
+			{
+				Name:  "decrypt",
+				Usage: "decrypt <encrypted env file>",
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 1 {
+						cliLogger.Errorln("Decrypt requires the encrypted env file as the only argument")
+						os.Exit(1)
+					}
+					err := cmdSecretsDecrypt(os.Stdout, c.Args().First(), c.String("key"), c.String("identity"))
+					if err != nil {
+						cliLogger.Fatal(err)
+					}
+				},
+				Flags: SecretsDecryptFlags,
+			},
+		},
+	}
+
 	runnerCommand = cli.Command{
 		Name:      "runner",
 		ShortName: "run",
@@ -468,6 +596,28 @@ func setupExternalRunnerParams(c *cli.Context, params *external.RunnerParams) er
 	return nil
 }
 
+// loadEnvironment is the host environment with the --environment files
+// added, a file that can't be read is fatal unless it's the default one
+// and doesn't exist
+func loadEnvironment(c *cli.Context) *util.Environment {
+	env := util.NewEnvironment(os.Environ()...)
+	files := []string{}
+	for _, f := range strings.Split(c.GlobalString("environment"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	err := env.LoadFiles(files...)
+	if os.IsNotExist(err) && !c.GlobalIsSet("environment") {
+		return env
+	}
+	if err != nil {
+		cliLogger.Errorln("Invalid environment file\n", err)
+		os.Exit(1)
+	}
+	return env
+}
+
 func GetApp() *cli.App {
 	// logger.SetLevel(logger.DebugLevel)
 	// util.RootLogger().SetLevel("debug")
@@ -485,16 +635,20 @@ func GetApp() *cli.App {
 		buildCommand,
 		devCommand,
 		checkConfigCommand,
+		lockCommand,
 		deployCommand,
+		workflowCommand,
 		detectCommand,
 		// inspectCommand,
 		loginCommand,
 		logoutCommand,
 		pullCommand,
+		replayCommand,
 		versionCommand,
 		documentCommand(app),
 		dockerCommand,
 		stepCommand,
+		secretsCommand,
 		runnerCommand,
 	}
 	app.Before = func(ctx *cli.Context) error {
@@ -542,16 +696,27 @@ func cmdDev(ctx context.Context, options *core.PipelineOptions, dockerOptions *d
 		options.Pipeline = "dev"
 	}
 	pipelineGetter := GetDevPipelineFactory(options.Pipeline)
+	if options.Plan {
+		return nil, cmdPlan(options, dockerOptions, pipelineGetter)
+	}
 	ctx = core.NewEmitterContext(ctx)
 	return executePipeline(ctx, options, dockerOptions, pipelineGetter)
 }
 
 func cmdBuild(ctx context.Context, options *core.PipelineOptions, dockerOptions *dockerlocal.Options) (*RunnerShared, error) {
+	return buildWithEmitter(core.NewEmitterContext(ctx), options, dockerOptions)
+}
+
+// buildWithEmitter is cmdBuild for a caller that sets up the emitter in ctx
+// itself
+func buildWithEmitter(ctx context.Context, options *core.PipelineOptions, dockerOptions *dockerlocal.Options) (*RunnerShared, error) {
 	if options.Pipeline == "" {
 		options.Pipeline = "build"
 	}
 	pipelineGetter := GetBuildPipelineFactory(options.Pipeline)
-	ctx = core.NewEmitterContext(ctx)
+	if options.Plan {
+		return nil, cmdPlan(options, dockerOptions, pipelineGetter)
+	}
 	return executePipeline(ctx, options, dockerOptions, pipelineGetter)
 }
 
@@ -560,6 +725,9 @@ func cmdDeploy(ctx context.Context, options *core.PipelineOptions, dockerOptions
 		options.Pipeline = "deploy"
 	}
 	pipelineGetter := GetDeployPipelineFactory(options.Pipeline)
+	if options.Plan {
+		return nil, cmdPlan(options, dockerOptions, pipelineGetter)
+	}
 	ctx = core.NewEmitterContext(ctx)
 	return executePipeline(ctx, options, dockerOptions, pipelineGetter)
 }
@@ -570,18 +738,30 @@ func cmdCheckConfig(options *core.PipelineOptions, dockerOptions *dockerlocal.Op
 
 	// TODO(termie): this is pretty much copy-paste from the
 	//               runner.GetConfig step, we should probably refactor
-	var werckerYaml []byte
-	var err error
-	if options.WerckerYml != "" {
-		werckerYaml, err = ioutil.ReadFile(options.WerckerYml)
+	yamlFile := options.WerckerYml
+	if yamlFile == "" {
+		found, err := core.FindWerckerYaml([]string{"."})
 		if err != nil {
 			return soft.Exit(err)
 		}
-	} else {
-		werckerYaml, err = core.ReadWerckerYaml([]string{"."}, false)
-		if err != nil {
-			return soft.Exit(err)
+		yamlFile = found
+	}
+	werckerYaml, err := ioutil.ReadFile(yamlFile)
+	if err != nil {
+		return soft.Exit(err)
+	}
+
+	// Check it against the schema first so we can point at everything
+	// that is wrong instead of just the first thing the parser trips on
+	configErrors, err := core.ValidateConfig(werckerYaml)
+	if err != nil {
+		return soft.Exit(fmt.Errorf("Error parsing your wercker.yml:\n  %s", err))
+	}
+	if len(configErrors) > 0 {
+		for _, configError := range configErrors {
+			logger.Errorf("%s:%s", yamlFile, configError)
 		}
+		return soft.Exit(fmt.Errorf("Found %d problem(s) in %s", len(configErrors), yamlFile))
 	}
 
 	// Parse that bad boy.
@@ -604,6 +784,16 @@ func cmdCheckConfig(options *core.PipelineOptions, dockerOptions *dockerlocal.Op
 	return nil
 }
 
+// cmdJSONSchema writes the JSON Schema for wercker.yml to w
+func cmdJSONSchema(w io.Writer) error {
+	b, err := json.MarshalIndent(core.WerckerSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
 // detectProject inspects the the current directory that wercker is running in
 // and detects the project's programming language
 func cmdDetect(options *core.DetectOptions) error {
@@ -1036,6 +1226,15 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 	mainTimer := util.NewTimer()
 	timer := util.NewTimer()
 
+	// Record the phases of the run for --profile, the spans are nil and do
+	// nothing without it
+	var profile *core.Profile
+	if options.ProfileSummary {
+		profile = core.NewProfile(options.Pipeline)
+		defer writeProfile(profile, options, logger)
+	}
+	cmdCtx = core.WithProfileSpan(cmdCtx, profile.Root())
+
 	// These will be emitted at the end of the execution, we're going to be
 	// pessimistic and report that we failed, unless overridden at the end of the
 	// execution.
@@ -1062,6 +1261,7 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 	// Start copying code
 	logger.Println(f.Info("Executing pipeline"))
 	timer.Reset()
+	codeSpan := profile.Root().Start("code", "get code")
 	_, err = r.EnsureCode()
 	if err != nil {
 		if r.options.LocalFileStore == "" {
@@ -1079,8 +1279,31 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 			Logs:   err.Error() + "\n",
 		})
 	}
+	codeSpan.End()
 	logger.Printf(f.Success("Copied working directory", timer.String()))
 
+	// Snapshots are stored per project, find the one we resume from before
+	// the box gets started from it
+	var snapshotter *Snapshotter
+	if options.SnapshotSteps {
+		snapshotter = NewSnapshotter(options, dockerOptions)
+		err = snapshotter.Prune()
+		if err != nil {
+			logger.WithField("Error", err).Warnln("Unable to remove expired snapshots")
+		}
+	}
+	if options.ResumeFrom != "" {
+		options.Resume, err = snapshotter.Resume()
+		if err != nil {
+			e.Emit(core.Logs, &core.LogsArgs{
+				Stream: "stderr",
+				Logs:   err.Error() + "\n",
+			})
+			return nil, soft.Exit(err)
+		}
+		logger.Println(f.Info("Resuming from snapshot", options.Resume.Step))
+	}
+
 	// Setup environment is still a fairly special step, it needs
 	// to start our boxes and get everything set up
 	logger.Println(f.Info("Running step", "setup environment"))
@@ -1147,9 +1370,9 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 
 	e.Emit(core.BuildStepsAdded, &core.BuildStepsAddedArgs{
 		Build:      pipeline,
-		Steps:      pipeline.Steps(),
+		Steps:      core.FlattenSteps(pipeline.Steps()),
 		StoreStep:  storeStep,
-		AfterSteps: pipeline.AfterSteps(),
+		AfterSteps: core.FlattenSteps(pipeline.AfterSteps()),
 	})
 
 	pr := &core.PipelineResult{
@@ -1158,12 +1381,35 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 		FailedStepMessage: "",
 	}
 
+	if snapshotter != nil {
+		err = snapshotter.Start(pipeline)
+		if err == nil && options.Resume != nil {
+			err = resumeEnvironment(shared, options.Resume)
+		}
+		if err != nil {
+			e.Emit(core.Logs, &core.LogsArgs{
+				Stream: "stderr",
+				Logs:   err.Error() + "\n",
+			})
+			return nil, soft.Exit(err)
+		}
+	}
+
 	// stepCounter starts at 3, step 1 is "get code", step 2 is "setup
 	// environment".
 	stepCounter := &util.Counter{Current: 3}
 	checkpoint := false
-	for _, step := range pipeline.Steps() {
+	for i, step := range pipeline.Steps() {
 		defer step.Clean()
+		// the steps up to the snapshot already ran, except for wercker-init
+		// which sets up the session
+		if options.Resume != nil && i > 0 && i <= options.Resume.Index {
+			logger.Printf(f.Info("Skipping step", step.DisplayName(), "restored from snapshot"))
+			for _, restored := range core.FlattenSteps([]core.Step{step}) {
+				r.RestoreStep(shared, restored, stepCounter.Increment())
+			}
+			continue
+		}
 		// we always want to run the wercker-init step to provide some functions
 		if !checkpoint && stepCounter.Current > 3 {
 			if options.EnableDevSteps && options.Checkpoint != "" {
@@ -1173,22 +1419,56 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 					logger.Printf(f.Info("Found checkpoint", options.Checkpoint))
 					checkpoint = true
 				}
-				stepCounter.Increment()
+				// parallel groups take up an order per step
+				for range core.FlattenSteps([]core.Step{step}) {
+					stepCounter.Increment()
+				}
 				continue
 			}
 		}
+		shouldRun, err := ShouldRunStep(step, pipeline.Env())
+		if err != nil {
+			pr.Success = false
+			pr.FailedStepName = step.DisplayName()
+			pr.FailedStepMessage = err.Error()
+			logger.Printf(f.Fail("Step failed", step.DisplayName(), err.Error()))
+			break
+		}
+		if !shouldRun {
+			logger.Printf(f.Info("Skipping step", step.DisplayName(), step.When()))
+			r.SkipStep(shared, step, stepCounter.Increment())
+			continue
+		}
 		logger.Printf(f.Info("Running step", step.DisplayName()))
 		timer.Reset()
-		sr, err := r.RunStep(cmdCtx, shared, step, stepCounter.Increment())
+		var sr *StepResult
+		if group, ok := step.(*core.ParallelStep); ok {
+			sr, err = r.RunParallel(cmdCtx, shared, group, stepCounter)
+		} else {
+			sr, err = r.RunStep(cmdCtx, shared, step, stepCounter.Increment())
+		}
 		if err != nil {
 			pr.Success = false
 			pr.FailedStepName = step.DisplayName()
 			pr.FailedStepMessage = sr.Message
+			pr.TimedOut = sr.TimedOut
 			logger.Printf(f.Fail(sr.Message))
 			logger.Printf(f.Fail("Step failed", step.DisplayName(), sr.Message, timer.String()))
+			if options.SnapshotSteps {
+				if err := snapshotter.Failed(i); err != nil {
+					logger.WithField("Error", err).Warnln("Unable to save snapshots")
+				}
+			}
 			break
 		}
 
+		if options.SnapshotSteps {
+			logger.Printf(f.Info("Snapshotting", step.DisplayName()))
+			if err := snapshotter.Snapshot(shared, step, i); err != nil {
+				logger.WithField("Error", err).Warnln("Unable to snapshot step")
+			}
+		}
+
 		if options.EnableDevSteps && step.Checkpoint() != "" {
 			logger.Printf(f.Info("Checkpointing", step.Checkpoint()))
 			box.Commit(box.Repository(), fmt.Sprintf("w-%s", step.Checkpoint()), "checkpoint", false)
@@ -1200,16 +1480,18 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 	}
 
 	if options.ShouldCommit {
+		commitSpan := profile.Root().Start("commit", fmt.Sprintf("%s:%s", repoName, tag))
 		_, err = box.Commit(repoName, tag, message, true)
 		if err != nil {
 			logger.Errorln("Failed to commit:", err.Error())
 		}
+		commitSpan.End()
 	}
 
 	// We need to wind the counter to where it should be if we failed a step
 	// so that is the number of steps + get code + setup environment + store
 	// TODO(termie): remove all the this "order" stuff completely
-	stepCounter.Current = len(pipeline.Steps()) + 3
+	stepCounter.Current = len(core.FlattenSteps(pipeline.Steps())) + 3
 
 	if pr.Success && options.ShouldArtifacts {
 		// At this point the build has effectively passed but we can still mess it
@@ -1225,6 +1507,8 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 			}
 			finisher := r.StartStep(shared, storeStep, stepCounter.Increment())
 			defer finisher.Finish(sr)
+			storeSpan := profile.Root().Start("store", storeStep.Name())
+			defer storeSpan.End()
 
 			pr.FailedStepName = storeStep.Name()
 			pr.FailedStepMessage = "Unable to store pipeline output"
@@ -1352,7 +1636,9 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 		// into the CacheDir
 		if !options.DirectMount {
 			timer.Reset()
+			cacheSpan := profile.Root().Start("cache", "collect cache")
 			err = pipeline.CollectCache(cmdCtx, shared.containerID)
+			cacheSpan.End()
 			if err != nil {
 				logger.WithField("Error", err).Error("Unable to store cache")
 			}
@@ -1376,13 +1662,14 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 	pipelineArgs.RanAfterSteps = true
 
 	logger.Println(f.Info("Starting after-steps"))
+	restartSpan := profile.Root().Start("environment", "restart box")
 	// The container may have died, either way we'll have a fresh env
 	container, err := box.Restart()
 	if err != nil {
 		logger.Panicln(err)
 	}
 
-	newSessCtx, newSess, err := r.GetSession(cmdCtx, container.ID)
+	newSessCtx, newSess, err := r.GetSession(cmdCtx, pipeline.Transport(), container.ID)
 	if err != nil {
 		logger.Panicln(err)
 	}
@@ -1394,6 +1681,7 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 		sessionCtx:  newSessCtx,
 		containerID: shared.containerID,
 		config:      shared.config,
+		outputs:     shared.outputs,
 	}
 
 	// Set up the base environment
@@ -1408,10 +1696,33 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 		return nil, err
 	}
 
+	restartSpan.End()
+
+	// After-steps can also look at the result of the pipeline in their
+	// when conditions
+	afterEnv := util.NewEnvironment()
+	afterEnv.Update(pipeline.Env().Ordered())
+	afterEnv.Update(pr.Env().Ordered())
+	afterEnv.Hidden = pipeline.Env().Hidden
+
 	for _, step := range pipeline.AfterSteps() {
+		shouldRun, err := ShouldRunStep(step, afterEnv)
+		if err != nil {
+			logger.Println(f.Fail("After-step failed", step.DisplayName(), err.Error()))
+			break
+		}
+		if !shouldRun {
+			logger.Println(f.Info("Skipping after-step", step.DisplayName(), step.When()))
+			r.SkipStep(newShared, step, stepCounter.Increment())
+			continue
+		}
 		logger.Println(f.Info("Running after-step", step.DisplayName()))
 		timer.Reset()
-		_, err := r.RunStep(cmdCtx, newShared, step, stepCounter.Increment())
+		if group, ok := step.(*core.ParallelStep); ok {
+			_, err = r.RunParallel(cmdCtx, newShared, group, stepCounter)
+		} else {
+			_, err = r.RunStep(cmdCtx, newShared, step, stepCounter.Increment())
+		}
 		if err != nil {
 			logger.Println(f.Fail("After-step failed", step.DisplayName(), timer.String()))
 			break
@@ -1423,7 +1734,9 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 	// into the CacheDir
 	if !options.DirectMount {
 		timer.Reset()
+		cacheSpan := profile.Root().Start("cache", "collect cache")
 		err = pipeline.CollectCache(cmdCtx, newShared.containerID)
+		cacheSpan.End()
 		if err != nil {
 			logger.WithField("Error", err).Error("Unable to store cache")
 		}
@@ -1446,3 +1759,34 @@ func executePipeline(cmdCtx context.Context, options *core.PipelineOptions, dock
 
 	return shared, nil
 }
+
+// writeProfile ends the profile of the run and prints its summary, and
+// writes it as a Chrome trace to the --profile file if there is one
+func writeProfile(profile *core.Profile, options *core.PipelineOptions, logger *util.LogEntry) {
+	profile.End()
+	f := &util.Formatter{ShowColors: options.GlobalOptions.ShowColors}
+
+	var summary bytes.Buffer
+	profile.WriteSummary(&summary)
+	logger.Println(f.Info("Profile", "* is on the critical path"))
+	for _, line := range strings.Split(strings.TrimRight(summary.String(), "\n"), "\n") {
+		logger.Println(line)
+	}
+
+	if options.Profile == "" {
+		return
+	}
+	path := options.RunPath(options.Profile)
+	trace, err := os.Create(path)
+	if err == nil {
+		err = profile.WriteTrace(trace)
+		if closeErr := trace.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logger.WithField("Error", err).Warnln("Unable to write profile")
+		return
+	}
+	logger.Println(f.Info("Wrote profile", path))
+}
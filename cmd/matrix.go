@@ -0,0 +1,151 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MatrixResult is the outcome of a single matrix variant
+type MatrixResult struct {
+	Index    int
+	Name     string
+	RunID    string
+	Duration time.Duration
+	Err      error
+}
+
+// readMatrix returns the matrix of the pipeline we are about to run, if the
+// wercker.yml can't be read here we leave it to the normal build to report.
+func readMatrix(options *core.PipelineOptions) []*core.MatrixConfig {
+	config, _, err := readWerckerConfig(options)
+	if err != nil {
+		return nil
+	}
+	pipelineConfig, ok := config.PipelinesMap[options.Pipeline]
+	if !ok || pipelineConfig == nil {
+		return nil
+	}
+	return pipelineConfig.Matrix
+}
+
+// cmdBuildMatrix runs a build for every variant in the pipeline's matrix,
+// or a single normal build if it doesn't have one.
+func cmdBuildMatrix(ctx context.Context, options *core.PipelineOptions, dockerOptions *dockerlocal.Options) error {
+	if options.Pipeline == "" {
+		options.Pipeline = "build"
+	}
+	matrix := readMatrix(options)
+	if len(matrix) == 0 {
+		_, err := cmdBuild(ctx, options, dockerOptions)
+		return err
+	}
+
+	logger := util.RootLogger().WithField("Logger", "Matrix")
+	f := &util.Formatter{ShowColors: options.GlobalOptions.ShowColors}
+
+	results := make([]*MatrixResult, len(matrix))
+	run := func(i int) {
+		// Each variant gets its own RunID, which in turn gives it its own
+		// containers, network and build directory
+		variantOptions := *options
+		variantOptions.RunID = bson.NewObjectId().Hex()
+		variantOptions.MatrixIndex = i + 1
+
+		name := matrix[i].DisplayName()
+		if name == "" {
+			name = fmt.Sprintf("variant %d", i+1)
+		}
+		logger.Println(f.Info("Running matrix variant", name))
+
+		// Variants that run at the same time have their name in front of
+		// every line they log
+		e := core.NewNormalizedEmitter()
+		if options.MatrixParallel {
+			e = e.Fork(fmt.Sprintf("[%s] ", name))
+			defer e.Flush()
+		}
+
+		timer := util.NewTimer()
+		_, err := buildWithEmitter(core.WithEmitter(ctx, e), &variantOptions, dockerOptions)
+		results[i] = &MatrixResult{
+			Index:    i + 1,
+			Name:     name,
+			RunID:    variantOptions.RunID,
+			Duration: timer.Elapsed(),
+			Err:      err,
+		}
+	}
+
+	if options.MatrixParallel {
+		var wg sync.WaitGroup
+		for i := range matrix {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range matrix {
+			run(i)
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(FormatMatrixResults(results), "\n"), "\n") {
+		logger.Println(line)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		logger.Println(f.Fail("Matrix failed", fmt.Sprintf("%d of %d variants failed", failed, len(results))))
+		return fmt.Errorf("%d of %d matrix variants failed", failed, len(results))
+	}
+	logger.Println(f.Success("Matrix passed", fmt.Sprintf("%d variants", len(results))))
+	return nil
+}
+
+// FormatMatrixResults renders the results as a table
+func FormatMatrixResults(results []*MatrixResult) string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tVARIANT\tRESULT\tDURATION\tRUN ID")
+	for _, result := range results {
+		status := "passed"
+		if result.Err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.2fs\t%s\n", result.Index, result.Name, status, result.Duration.Seconds(), result.RunID)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,46 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type MatrixSuite struct {
+	*util.TestSuite
+}
+
+func TestMatrixSuite(t *testing.T) {
+	suiteTester := &MatrixSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *MatrixSuite) TestFormatMatrixResults() {
+	table := FormatMatrixResults([]*MatrixResult{
+		{Index: 1, Name: "golang:1.9", RunID: "abc", Duration: 1500 * time.Millisecond},
+		{Index: 2, Name: "golang:1.10 DB=postgres", RunID: "def", Duration: 2 * time.Second, Err: errors.New("Step failed: script")},
+	})
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	s.Require().Len(lines, 3)
+	s.Equal(strings.Fields("# VARIANT RESULT DURATION RUN ID"), strings.Fields(lines[0]))
+	s.Equal(strings.Fields("1 golang:1.9 passed 1.50s abc"), strings.Fields(lines[1]))
+	s.Equal(strings.Fields("2 golang:1.10 DB=postgres failed 2.00s def"), strings.Fields(lines[2]))
+}
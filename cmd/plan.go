@@ -0,0 +1,67 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+)
+
+// cmdPlan prints the pipeline getter would run without starting any
+// containers, see --plan
+func cmdPlan(options *core.PipelineOptions, dockerOptions *dockerlocal.Options, getter pipelineGetter) error {
+	// The code hasn't been copied to the ProjectDir, so read the wercker.yml
+	// from the project itself
+	_, werckerYml, err := readWerckerConfig(options)
+	if err != nil {
+		return err
+	}
+	planOptions := *options
+	planOptions.WerckerYml = werckerYml
+
+	r := &Runner{
+		options:       &planOptions,
+		dockerOptions: dockerOptions,
+		getPipeline:   getter,
+		emitter:       core.NewNormalizedEmitter(),
+		logger:        util.RootLogger().WithField("Logger", "Plan"),
+		formatter:     &util.Formatter{ShowColors: options.GlobalOptions.ShowColors},
+	}
+	config, _, err := r.GetConfig()
+	if err != nil {
+		return err
+	}
+	pipeline, err := r.GetPipeline(config)
+	if err != nil {
+		return err
+	}
+	pipeline.InitEnv(planOptions.HostEnv)
+
+	pipelineConfig, ok := config.PipelinesMap[planOptions.Pipeline]
+	if !ok || pipelineConfig == nil {
+		return fmt.Errorf("No pipeline named %s", planOptions.Pipeline)
+	}
+	_, section := pipelineConfig.StepsFor(planOptions.DeployTarget)
+
+	plan, err := core.NewPlan(pipeline, &planOptions, section)
+	if err != nil {
+		return err
+	}
+	return plan.Write(os.Stdout, planOptions.PlanFormat)
+}
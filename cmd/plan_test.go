@@ -0,0 +1,65 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+)
+
+type PlanSuite struct {
+	*util.TestSuite
+}
+
+func TestPlanSuite(t *testing.T) {
+	suiteTester := &PlanSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *PlanSuite) TestPlan() {
+	project := filepath.Join(s.WorkingDir(), "project")
+	s.Require().NoError(os.MkdirAll(project, 0755))
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(project, "wercker.yml"), []byte(`
+box: ubuntu
+secrets:
+  - 'password=(\S+)'
+build:
+  steps:
+    - script:
+        name: test
+        code: make test
+`), 0644))
+
+	options, err := core.NewBuildOptions(util.NewCheapSettings(map[string]interface{}{
+		"target":      project,
+		"working-dir": filepath.Join(s.WorkingDir(), ".wercker"),
+		"pipeline":    "build",
+	}), util.NewEnvironment())
+	s.Require().NoError(err)
+
+	err = cmdPlan(options, &dockerlocal.Options{}, GetBuildPipelineFactory("build"))
+	s.NoError(err)
+
+	options.Pipeline = "deploy"
+	err = cmdPlan(options, &dockerlocal.Options{}, GetBuildPipelineFactory("deploy"))
+	s.Error(err)
+}
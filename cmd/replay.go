@@ -0,0 +1,177 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/pkg/term"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/event"
+)
+
+// clearScreen is written before the step we jump to
+const clearScreen = "\x1b[2J\x1b[H"
+
+// cmdReplay plays back a recording made with --record. If stdin is a
+// terminal, n jumps to the next step, p to the previous one and q quits.
+func cmdReplay(options *core.ReplayOptions) error {
+	f, err := os.Open(options.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cast, err := event.ReadAsciicast(f)
+	if err != nil {
+		return err
+	}
+	start, err := replayStart(cast, options.Step)
+	if err != nil {
+		return err
+	}
+
+	r := &replayer{cast: cast, out: os.Stdout, speed: options.Speed}
+	if term.IsTerminal(os.Stdin.Fd()) {
+		oldState, err := term.SetRawTerminal(os.Stdin.Fd())
+		if err != nil {
+			return err
+		}
+		defer term.RestoreTerminal(os.Stdin.Fd(), oldState)
+		r.keys = make(chan byte, 16)
+		go r.readKeys(os.Stdin)
+	}
+	return r.Play(start)
+}
+
+// replayStart is the event to start playing at for step, the name or the
+// 1-based number of a step, the first event if it is empty
+func replayStart(cast *event.Asciicast, step string) (int, error) {
+	if step == "" {
+		return 0, nil
+	}
+	markers := cast.Markers()
+	if n, err := strconv.Atoi(step); err == nil {
+		if n < 1 || n > len(markers) {
+			return 0, fmt.Errorf("No step %d in the recording, it has %d steps", n, len(markers))
+		}
+		return markers[n-1], nil
+	}
+	for _, i := range markers {
+		if cast.Events[i].Data == step {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("No step named %s in the recording", step)
+}
+
+// replayer plays a recording to out
+type replayer struct {
+	cast  *event.Asciicast
+	out   io.Writer
+	speed float64
+	// Keys pressed while playing, nil if there is no terminal
+	keys chan byte
+}
+
+func (r *replayer) readKeys(in io.Reader) {
+	b := make([]byte, 1)
+	for {
+		if _, err := in.Read(b); err != nil {
+			return
+		}
+		r.keys <- b[0]
+	}
+}
+
+// Play the events starting at event i, waiting between them as long as
+// when they were recorded divided by the speed
+func (r *replayer) Play(i int) error {
+	events := r.cast.Events
+	wait := false
+	for i < len(events) {
+		if wait && i > 0 {
+			next, quit := r.wait(time.Duration(float64(events[i].Time-events[i-1].Time)/r.speed), i)
+			if quit {
+				return nil
+			}
+			if next != i {
+				i = next
+				wait = false
+				if _, err := io.WriteString(r.out, clearScreen); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		wait = true
+		if events[i].Type == event.AsciicastOutput {
+			if _, err := io.WriteString(r.out, events[i].Data); err != nil {
+				return err
+			}
+		}
+		i++
+	}
+	return nil
+}
+
+// wait for d before playing event i, returns the event to play instead if
+// a key is pressed to jump to another step
+func (r *replayer) wait(d time.Duration, i int) (int, bool) {
+	timeout := time.After(d)
+	for {
+		select {
+		case <-timeout:
+			return i, false
+		case key := <-r.keys:
+			switch key {
+			case 'n':
+				return r.nextStep(i), false
+			case 'p':
+				return r.previousStep(i), false
+			case 'q', 3:
+				// 3 is ctrl-c in a raw terminal
+				return i, true
+			}
+		}
+	}
+}
+
+// nextStep is the start of the step after the one that is playing, or the
+// end if it is the last one
+func (r *replayer) nextStep(i int) int {
+	for _, marker := range r.cast.Markers() {
+		if marker >= i {
+			return marker
+		}
+	}
+	return len(r.cast.Events)
+}
+
+// previousStep is the start of the step before the one that is playing, or
+// the beginning if it is the first one
+func (r *replayer) previousStep(i int) int {
+	previous, current := 0, 0
+	for _, marker := range r.cast.Markers() {
+		if marker >= i {
+			break
+		}
+		previous, current = current, marker
+	}
+	return previous
+}
@@ -0,0 +1,87 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/event"
+	"github.com/wercker/wercker/util"
+)
+
+type ReplaySuite struct {
+	*util.TestSuite
+}
+
+func TestReplaySuite(t *testing.T) {
+	suiteTester := &ReplaySuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func testCast() *event.Asciicast {
+	return &event.Asciicast{
+		Header: event.AsciicastHeader{Version: 2, Width: 80, Height: 24},
+		Events: []event.AsciicastEvent{
+			{Time: 0, Type: event.AsciicastMarker, Data: "setup environment"},
+			{Time: 10 * time.Millisecond, Type: event.AsciicastOutput, Data: "setup\r\n"},
+			{Time: 20 * time.Millisecond, Type: event.AsciicastMarker, Data: "test"},
+			{Time: 30 * time.Millisecond, Type: event.AsciicastOutput, Data: "test\r\n"},
+			{Time: 40 * time.Millisecond, Type: event.AsciicastMarker, Data: "deploy"},
+			{Time: 50 * time.Millisecond, Type: event.AsciicastOutput, Data: "deploy\r\n"},
+		},
+	}
+}
+
+func (s *ReplaySuite) TestReplayStart() {
+	cast := testCast()
+	for step, expected := range map[string]int{"": 0, "1": 0, "test": 2, "3": 4} {
+		i, err := replayStart(cast, step)
+		s.Nil(err, step)
+		s.Equal(expected, i, step)
+	}
+	for _, step := range []string{"0", "4", "lint"} {
+		_, err := replayStart(cast, step)
+		s.Error(err, step)
+	}
+}
+
+func (s *ReplaySuite) TestPlay() {
+	var b bytes.Buffer
+	r := &replayer{cast: testCast(), out: &b, speed: 100}
+	s.Nil(r.Play(2))
+	s.Equal("test\r\ndeploy\r\n", b.String())
+}
+
+func (s *ReplaySuite) TestPlayJump() {
+	var b bytes.Buffer
+	r := &replayer{cast: testCast(), out: &b, speed: 0.01, keys: make(chan byte, 2)}
+	r.keys <- 'n'
+	r.keys <- 'q'
+	s.Nil(r.Play(0))
+	s.Equal(clearScreen, b.String())
+}
+
+func (s *ReplaySuite) TestSteps() {
+	r := &replayer{cast: testCast()}
+	s.Equal(2, r.nextStep(1))
+	s.Equal(4, r.nextStep(3))
+	s.Equal(6, r.nextStep(5))
+	s.Equal(0, r.previousStep(3))
+	s.Equal(2, r.previousStep(5))
+	s.Equal(0, r.previousStep(1))
+}
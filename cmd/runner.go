@@ -16,11 +16,14 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/monochromegane/go-gitignore"
@@ -30,6 +33,7 @@ import (
 	"github.com/wercker/wercker/docker"
 	"github.com/wercker/wercker/event"
 	"github.com/wercker/wercker/rdd"
+	"github.com/wercker/wercker/secret"
 	"github.com/wercker/wercker/util"
 	"golang.org/x/net/context"
 )
@@ -83,11 +87,13 @@ type Runner struct {
 	dockerOptions *dockerlocal.Options
 	literalLogger *event.LiteralLogHandler
 	reporter      *event.ReportHandler
+	webhooks      *event.WebhookHandler
 	getPipeline   pipelineGetter
 	logger        *util.LogEntry
 	emitter       *core.NormalizedEmitter
 	formatter     *util.Formatter
 	rdd           *rdd.RDD
+	stepCache     *StepCacher
 }
 
 // NewRunner from global options
@@ -123,15 +129,44 @@ func NewRunner(ctx context.Context, options *core.PipelineOptions, dockerOptions
 		r.ListenTo(e)
 	}
 
+	if options.Record != "" {
+		h, err := event.NewAsciicastHandler(options)
+		if err != nil {
+			return nil, err
+		}
+		h.ListenTo(e)
+	}
+
+	if options.Output == core.OutputJSONL {
+		h, err := event.NewJSONLHandler(options)
+		if err != nil {
+			return nil, err
+		}
+		h.ListenTo(e)
+	}
+
+	webhooks, err := event.NewWebhookHandler(options)
+	if err != nil {
+		return nil, err
+	}
+	webhooks.ListenTo(e)
+
+	var stepCache *StepCacher
+	if !options.NoStepCache {
+		stepCache = NewStepCacher(options, dockerOptions)
+	}
+
 	return &Runner{
 		options:       options,
 		dockerOptions: dockerOptions,
 		literalLogger: l,
 		reporter:      r,
+		webhooks:      webhooks,
 		getPipeline:   getPipeline,
 		logger:        logger,
 		emitter:       e,
 		formatter:     &util.Formatter{ShowColors: options.GlobalOptions.ShowColors},
+		stepCache:     stepCache,
 	}, nil
 }
 
@@ -140,6 +175,14 @@ func (p *Runner) ProjectDir() string {
 	if p.options.DirectMount {
 		return p.options.ProjectPath
 	}
+	// Matrix variants may run in parallel so each needs its own copy
+	if p.options.MatrixIndex > 0 {
+		return fmt.Sprintf("%s/%s-matrix-%d", p.options.ProjectDownloadPath(), p.options.ApplicationID, p.options.MatrixIndex)
+	}
+	// As do the pipelines of a workflow
+	if p.options.WorkflowNode != "" {
+		return fmt.Sprintf("%s/%s-workflow-%s", p.options.ProjectDownloadPath(), p.options.ApplicationID, p.options.WorkflowNode)
+	}
 	return fmt.Sprintf("%s/%s", p.options.ProjectDownloadPath(), p.options.ApplicationID)
 }
 
@@ -293,6 +336,25 @@ func (p *Runner) GetConfig() (*core.Config, string, error) {
 		return nil, "", err
 	}
 
+	// Mask what the secrets patterns match in the logs
+	if p.emitter != nil {
+		p.emitter.Secrets().AddPatterns(rawConfig.Secrets...)
+	}
+
+	// The wercker.lock next to the wercker.yml pins the images and steps
+	lockDir := p.ProjectDir()
+	if p.options.WerckerYml != "" {
+		lockDir = filepath.Dir(p.options.WerckerYml)
+	}
+	lockfile, err := core.ReadLockfile(filepath.Join(lockDir, core.LockfileName))
+	if err != nil {
+		return nil, "", err
+	}
+	if lockfile == nil && p.options.FrozenLockfile {
+		return nil, "", fmt.Errorf("--frozen-lockfile needs a %s, run wercker lock to write one", core.LockfileName)
+	}
+	p.options.Lockfile = lockfile
+
 	// Add some options to the global config
 	if rawConfig.SourceDir != "" {
 		p.options.SourceDir = rawConfig.SourceDir
@@ -321,23 +383,34 @@ func (p *Runner) GetConfig() (*core.Config, string, error) {
 	return rawConfig, string(werckerYaml), nil
 }
 
+// useConfig starts what the config of the run adds to it, like the webhooks
+// in wercker.yml. GetConfig also reads the wercker.yml before the code is
+// copied, so only SetupEnvironment calls this.
+func (p *Runner) useConfig(rawConfig *core.Config) error {
+	if p.webhooks != nil {
+		if err := p.webhooks.AddConfig(rawConfig.Notifications); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AddServices fetches and links the services to the base box.
 func (p *Runner) AddServices(ctx context.Context, pipeline core.Pipeline, box core.Box) error {
 	f := p.formatter
 	timer := util.NewTimer()
 	for _, service := range pipeline.Services() {
 		timer.Reset()
+		span := core.ProfileSpanFromContext(ctx).Start("pull", service.GetName())
 		if _, err := service.Fetch(ctx, pipeline.Env()); err != nil {
 			return err
 		}
+		span.End()
 
 		box.AddService(service)
 		if p.options.Verbose {
 			p.logger.Printf(f.Success(fmt.Sprintf("Fetched %s", service.GetName()), timer.String()))
 		}
-		// TODO(mh): We want to make sure container is running fully before
-		// allowing build steps to run. We may need custom steps which block
-		// until service services are running.
 	}
 	return nil
 }
@@ -386,9 +459,14 @@ func (p *Runner) CopySource() error {
 	return nil
 }
 
-// GetSession attaches to the container and returns a session.
-func (p *Runner) GetSession(runnerContext context.Context, containerID string) (context.Context, *core.Session, error) {
-	dockerTransport, err := dockerlocal.NewDockerTransport(p.options, p.dockerOptions, containerID)
+// GetSession attaches to the container with the transport the pipeline asks
+// for and returns a session.
+func (p *Runner) GetSession(runnerContext context.Context, transport string, containerID string) (context.Context, *core.Session, error) {
+	newTransport := dockerlocal.NewDockerTransport
+	if transport == core.ExecTransport {
+		newTransport = dockerlocal.NewDockerExecTransport
+	}
+	dockerTransport, err := newTransport(p.options, p.dockerOptions, containerID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -418,11 +496,32 @@ type RunnerShared struct {
 	config      *core.Config
 	sessionCtx  context.Context
 	containerID string
+	// Set for the steps of a parallel group, see RunParallel
+	emitter *core.NormalizedEmitter
+	// When the pipeline times out, zero if it doesn't have a timeout
+	deadline time.Time
+	// The outputs of the steps that ran so far
+	outputs *core.StepOutputs
+}
+
+// outputsStep is a step whose data can refer to the outputs of the steps
+// before it
+type outputsStep interface {
+	InterpolateOutputs(*core.StepOutputs) error
+}
+
+// stepEmitter is the emitter to report a step on
+func (p *Runner) stepEmitter(shared *RunnerShared) *core.NormalizedEmitter {
+	if shared.emitter != nil {
+		return shared.emitter
+	}
+	return p.emitter
 }
 
 // StartStep emits BuildStepStarted and returns a Finisher for the end event.
 func (p *Runner) StartStep(ctx *RunnerShared, step core.Step, order int) *util.Finisher {
-	p.emitter.Emit(core.BuildStepStarted, &core.BuildStepStartedArgs{
+	e := p.stepEmitter(ctx)
+	e.Emit(core.BuildStepStarted, &core.BuildStepStartedArgs{
 		Box:   ctx.box,
 		Step:  step,
 		Order: order,
@@ -433,13 +532,17 @@ func (p *Runner) StartStep(ctx *RunnerShared, step core.Step, order int) *util.F
 		if r.Artifact != nil {
 			artifactURL = r.Artifact.URL()
 		}
-		p.emitter.Emit(core.BuildStepFinished, &core.BuildStepFinishedArgs{
+		e.Emit(core.BuildStepFinished, &core.BuildStepFinishedArgs{
 			Box:                 ctx.box,
 			Successful:          r.Success,
+			Skipped:             r.Skipped,
 			Message:             r.Message,
 			ArtifactURL:         artifactURL,
 			PackageURL:          r.PackageURL,
 			WerckerYamlContents: r.WerckerYamlContents,
+			Attempts:            r.Attempts,
+			Cached:              r.Cached,
+			Outputs:             r.Outputs,
 		})
 	})
 }
@@ -478,7 +581,8 @@ func (p *Runner) StartFullPipeline(options *core.PipelineOptions) *util.Finisher
 // box, and session. This is a bit of a long method, but it is pretty much
 // the entire "Setup Environment" step.
 func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, error) {
-	shared := &RunnerShared{}
+	started := time.Now()
+	shared := &RunnerShared{outputs: core.NewStepOutputs()}
 	f := &util.Formatter{ShowColors: p.options.GlobalOptions.ShowColors}
 	timer := util.NewTimer()
 
@@ -500,6 +604,10 @@ func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, err
 	finisher := p.StartStep(shared, setupEnvironmentStep, 2)
 	defer finisher.Finish(sr)
 
+	span := core.ProfileSpanFromContext(runnerCtx).Start("environment", setupEnvironmentStep.Name())
+	defer span.End()
+	runnerCtx = core.WithProfileSpan(runnerCtx, span)
+
 	if p.options.Verbose {
 		p.emitter.Emit(core.Logs, &core.LogsArgs{
 			Logs: fmt.Sprintf("Running wercker version: %s\n", util.FullVersion()),
@@ -515,6 +623,9 @@ func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, err
 			Logs: fmt.Sprintf("Using config:\n%s\n", stringConfig),
 		})
 	}
+	if err == nil {
+		err = p.useConfig(rawConfig)
+	}
 	if err != nil {
 		sr.Message = err.Error()
 		return shared, err
@@ -557,12 +668,28 @@ func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, err
 		}
 	}
 	pipeline.InitEnv(p.options.HostEnv)
+
+	// Swap the secret:// references in the env for the secrets
+	resolver, err := secret.NewResolver(rawConfig.SecretProviders, p.options.HostEnv, p.ProjectDir())
+	if err == nil {
+		err = resolver.ResolveEnvironment(pipeline.Env())
+	}
+	if err != nil {
+		sr.Message = err.Error()
+		return shared, err
+	}
+	p.emitter.Secrets().AddEnvironment(pipeline.Env())
 	shared.pipeline = pipeline
+	if pipeline.Timeout() > 0 {
+		shared.deadline = started.Add(pipeline.Timeout())
+	}
 
 	// Fetch the box
 	timer.Reset()
 	box := pipeline.Box()
+	pullSpan := span.Start("pull", box.GetName())
 	_, err = box.Fetch(runnerCtx, pipeline.Env())
+	pullSpan.End()
 	if err != nil {
 		sr.Message = err.Error()
 		return shared, err
@@ -604,10 +731,12 @@ func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, err
 	steps := pipeline.Steps()
 	for _, step := range steps {
 		timer.Reset()
+		fetchSpan := span.Start("fetch", step.DisplayName())
 		if _, err := step.Fetch(); err != nil {
 			sr.Message = err.Error()
 			return shared, err
 		}
+		fetchSpan.End()
 		if p.options.Verbose {
 			p.logger.Printf(f.Success("Prepared step", step.Name(), timer.String()))
 		}
@@ -618,10 +747,12 @@ func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, err
 	afterSteps := pipeline.AfterSteps()
 	for _, step := range afterSteps {
 		timer.Reset()
+		fetchSpan := span.Start("fetch", step.DisplayName())
 		if _, err := step.Fetch(); err != nil {
 			sr.Message = err.Error()
 			return shared, err
 		}
+		fetchSpan.End()
 
 		if p.options.Verbose {
 			p.logger.Printf(f.Success("Prepared step", step.Name(), timer.String()))
@@ -658,7 +789,7 @@ func (p *Runner) SetupEnvironment(runnerCtx context.Context) (*RunnerShared, err
 
 	p.logger.Debugln("Attaching session to base box")
 	// Start our session
-	sessionCtx, sess, err := p.GetSession(runnerCtx, container.ID)
+	sessionCtx, sess, err := p.GetSession(runnerCtx, pipeline.Transport(), container.ID)
 	if err != nil {
 		sr.Message = err.Error()
 		return shared, err
@@ -696,6 +827,49 @@ type StepResult struct {
 	Message             string
 	ExitCode            int
 	WerckerYamlContents string
+	Skipped             bool
+	// Attempts is how often the step was executed, see core.RetryConfig
+	Attempts int
+	// TimedOut is set if the step was killed for taking too long
+	TimedOut bool
+	// Cached is set if the step's outputs were restored instead of running
+	// it, see StepCacher
+	Cached bool
+	// Outputs the step wrote for the steps after it, see core.StepOutputs
+	Outputs map[string]string
+}
+
+// SkipStep emits the start and finish events for a step whose when
+// condition was false, so it is reported as skipped rather than missing.
+func (p *Runner) SkipStep(shared *RunnerShared, step core.Step, order int) *StepResult {
+	return p.skipStep(shared, step, order, fmt.Sprintf("Skipped, condition is false: %s", step.When()))
+}
+
+// RestoreStep reports a step that already ran in the snapshot we resumed
+// from as skipped
+func (p *Runner) RestoreStep(shared *RunnerShared, step core.Step, order int) *StepResult {
+	return p.skipStep(shared, step, order, "Skipped, restored from snapshot")
+}
+
+func (p *Runner) skipStep(shared *RunnerShared, step core.Step, order int, message string) *StepResult {
+	finisher := p.StartStep(shared, step, order)
+	sr := &StepResult{
+		Success:  true,
+		Skipped:  true,
+		Message:  message,
+		ExitCode: 0,
+	}
+	finisher.Finish(sr)
+	return sr
+}
+
+// ShouldRunStep evaluates the step's when condition against env, steps
+// without a condition always run.
+func ShouldRunStep(step core.Step, env *util.Environment) (bool, error) {
+	if step.When() == "" {
+		return true, nil
+	}
+	return core.EvalWhen(step.When(), env)
 }
 
 // RunStep runs a step and tosses error if it fails
@@ -709,7 +883,13 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 	}
 	defer finisher.Finish(sr)
 
-	if step.ShouldSyncEnv() {
+	span := core.ProfileSpanFromContext(ctx).Start("step", step.DisplayName())
+	defer span.End()
+	setupSpan := span.Start("setup", step.DisplayName())
+
+	// Steps in a parallel group share the pipeline environment, RunParallel
+	// syncs it for them before they start
+	if step.ShouldSyncEnv() && shared.emitter == nil {
 		err := shared.pipeline.SyncEnvironment(shared.sessionCtx, shared.sess)
 		if err != nil {
 			// If an error occured, just log and ignore it
@@ -717,6 +897,13 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 		}
 	}
 
+	if s, ok := step.(outputsStep); ok {
+		if err := s.InterpolateOutputs(shared.outputs); err != nil {
+			sr.Message = err.Error()
+			return sr, err
+		}
+	}
+
 	err := step.InitEnv(shared.pipeline.Env())
 	if err != nil {
 		sr.Message = err.Error()
@@ -728,9 +915,36 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 		p.logger.Debugln(" ", pair[0], pair[1])
 	}
 
+	cacheKey := ""
+	if p.stepCache != nil {
+		cacheKey, err = core.StepCacheKey(step, path.Join(p.ProjectDir(), p.options.SourceDir), shared.pipeline.Env())
+		if err != nil {
+			sr.Message = err.Error()
+			return sr, err
+		}
+	}
+	if cacheKey != "" {
+		restored, outputs, err := p.stepCache.Restore(ctx, shared.containerID, cacheKey)
+		if err != nil {
+			p.logger.WithField("Error", err).Warnln("Unable to restore cached outputs of step", step.DisplayName())
+		} else if restored {
+			sr.Outputs = outputs
+			shared.outputs.Set(step.DisplayName(), outputs)
+			sr.Success = true
+			sr.Cached = true
+			sr.ExitCode = 0
+			sr.Message = fmt.Sprintf("Cached, restored outputs for cache key %s", cacheKey[:12])
+			return sr, nil
+		}
+	}
+
+	setupSpan.End()
+
 	// we need to keep this err for a while, so giving it a unique name to prevent
 	// accidentally overwriting it
-	exit, execErr := step.Execute(shared.sessionCtx, shared.sess)
+	executeSpan := span.Start("execute", step.DisplayName())
+	exit, execErr := p.executeStep(ctx, shared, step, sr)
+	executeSpan.End()
 	if exit != 0 {
 		sr.ExitCode = exit
 		if p.options.AttachOnError {
@@ -746,6 +960,7 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 	}
 
 	// Grab the message
+	collectSpan := span.Start("collect", step.DisplayName())
 	var message bytes.Buffer
 	messageErr := step.CollectFile(shared.containerID, step.ReportPath(), "message.txt", &message)
 	if messageErr != nil {
@@ -755,6 +970,24 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 	}
 	sr.Message = message.String()
 
+	// Grab the outputs for the steps after this one
+	var outputs bytes.Buffer
+	outputsErr := step.CollectFile(shared.containerID, step.ReportPath(), core.StepOutputsFile, &outputs)
+	if outputsErr != nil {
+		if outputsErr != util.ErrEmptyTarball {
+			return sr, outputsErr
+		}
+	}
+	if outputs.Len() > 0 {
+		sr.Outputs, err = core.ParseStepOutputs(&outputs)
+		if err != nil {
+			sr.Success = false
+			sr.Message = err.Error()
+			return sr, err
+		}
+		shared.outputs.Set(step.DisplayName(), sr.Outputs)
+	}
+
 	// Grab artifacts if we want them
 	if p.options.ShouldArtifacts {
 		artifact, err := step.CollectArtifact(ctx, shared.containerID)
@@ -771,10 +1004,11 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 		}
 		sr.Artifact = artifact
 	}
+	collectSpan.End()
 
 	// This is the error from the step.Execute above
 	if execErr != nil {
-		if sr.Message == "" {
+		if sr.Message == "" || sr.TimedOut {
 			sr.Message = execErr.Error()
 		}
 		return sr, execErr
@@ -784,5 +1018,242 @@ func (p *Runner) RunStep(ctx context.Context, shared *RunnerShared, step core.St
 		return sr, fmt.Errorf("Step failed with exit code: %d", sr.ExitCode)
 	}
 
+	if cacheKey != "" {
+		cacheSpan := span.Start("cache", step.DisplayName())
+		if err := p.stepCache.Save(ctx, shared.containerID, step, cacheKey, sr.Outputs); err != nil {
+			p.logger.WithField("Error", err).Warnln("Unable to cache outputs of step", step.DisplayName())
+		}
+		cacheSpan.End()
+	}
+
 	return sr, nil
 }
+
+// executeStep executes step in the session, again as long as its retry
+// policy allows it. A step that may be retried starts every attempt, the
+// first one included, with a line that marks it.
+func (p *Runner) executeStep(ctx context.Context, shared *RunnerShared, step core.Step, sr *StepResult) (int, error) {
+	e := p.stepEmitter(shared)
+	retry := step.Retry()
+	for attempt := 1; ; attempt++ {
+		sr.Attempts = attempt
+		if retry != nil && retry.Attempts > 1 {
+			e.Emit(core.Logs, &core.LogsArgs{
+				Logs: fmt.Sprintf("\n--- Attempt %d of %d ---\n", attempt, retry.Attempts),
+			})
+		}
+
+		exit, err := p.executeAttempt(shared, step, sr)
+		if exit == 0 || sr.TimedOut || !retry.ShouldRetry(attempt, exit) {
+			return exit, err
+		}
+
+		wait := retry.Wait(attempt)
+		e.Emit(core.Logs, &core.LogsArgs{
+			Stream: "stderr",
+			Logs:   fmt.Sprintf("Attempt %d of %d failed with exit code %d, retrying in %s\n", attempt, retry.Attempts, exit, wait),
+		})
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return exit, err
+		}
+	}
+}
+
+// executeAttempt executes step once, within its timeouts and what's left
+// of the pipeline's. A step that times out is killed, and not retried.
+func (p *Runner) executeAttempt(shared *RunnerShared, step core.Step, sr *StepResult) (int, error) {
+	sessionCtx := shared.sessionCtx
+	var noResponse, timeout time.Duration
+	var timeoutErr error
+	if t := step.Timeout(); t != nil {
+		if t.NoResponse > 0 {
+			noResponse = t.NoResponse
+			sessionCtx = core.WithNoResponseTimeout(sessionCtx, noResponse)
+		}
+		if t.Step > 0 {
+			timeout = t.Step
+			timeoutErr = fmt.Errorf("Step timed out after %s", timeout)
+		}
+	}
+	if !shared.deadline.IsZero() {
+		remaining := shared.deadline.Sub(time.Now())
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+			timeoutErr = fmt.Errorf("Pipeline timed out after %s", shared.pipeline.Timeout())
+		}
+	}
+	if timeout != 0 {
+		// The step's timeout replaces the command-timeout rather than
+		// being cut short by it
+		var cancel context.CancelFunc
+		sessionCtx, cancel = context.WithTimeout(core.WithCommandTimeout(sessionCtx, timeout), timeout)
+		defer cancel()
+	}
+
+	exit, err := step.Execute(sessionCtx, shared.sess)
+	switch {
+	case err == nil:
+		return exit, nil
+	case timeout != 0 && sessionCtx.Err() == context.DeadlineExceeded:
+		err = timeoutErr
+	case err == core.ErrNoResponse:
+		if noResponse == 0 {
+			noResponse = time.Duration(p.options.NoResponseTimeout) * time.Millisecond
+		}
+		err = fmt.Errorf("Step timed out after no output for %s", noResponse)
+	case err == core.ErrCommandTimeout:
+		err = fmt.Errorf("Step timed out after %s", time.Duration(p.options.CommandTimeout)*time.Millisecond)
+	default:
+		return exit, err
+	}
+
+	sr.TimedOut = true
+	p.stepEmitter(shared).Emit(core.Logs, &core.LogsArgs{
+		Stream: "stderr",
+		Logs:   fmt.Sprintf("\n%s, killing it\n", err),
+	})
+	if killErr := shared.box.KillProcesses("KILL"); killErr != nil {
+		p.logger.WithField("Error", killErr).Warnln("Unable to kill step", step.DisplayName())
+	}
+	return exit, err
+}
+
+// RunParallel runs the steps of a parallel group at the same time, each in
+// its own container started from the current state of the box. The steps
+// are reported one by one with orders taken from counter and their logs
+// have the step name in front of every line. What the steps write to the
+// output dir is copied back to the box, in the order of the steps, other
+// changes in their containers don't carry over to the steps after the group.
+func (p *Runner) RunParallel(ctx context.Context, shared *RunnerShared, group *core.ParallelStep, counter *util.Counter) (*StepResult, error) {
+	steps := group.Steps()
+	orders := make([]int, len(steps))
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		orders[i] = counter.Increment()
+		names[i] = step.SafeID()
+	}
+
+	span := core.ProfileSpanFromContext(ctx).Start("parallel", group.DisplayName())
+	defer span.End()
+	ctx = core.WithProfileSpan(ctx, span)
+
+	for _, step := range steps {
+		if step.ShouldSyncEnv() {
+			err := shared.pipeline.SyncEnvironment(shared.sessionCtx, shared.sess)
+			if err != nil {
+				// If an error occured, just log and ignore it
+				p.logger.WithField("Error", err).Warn("Unable to sync environment")
+			}
+			break
+		}
+	}
+
+	results := make([]*StepResult, len(steps))
+	errs := make([]error, len(steps))
+
+	boxes, err := shared.box.Fork(ctx, shared.pipeline.Env(), names)
+	if err != nil {
+		// Still report the steps so none of them go missing
+		for i, step := range steps {
+			results[i] = &StepResult{Message: err.Error(), ExitCode: 1}
+			errs[i] = err
+			p.StartStep(shared, step, orders[i]).Finish(results[i])
+		}
+		return parallelResult(steps, results, errs)
+	}
+
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(i int, step core.Step) {
+			defer wg.Done()
+			results[i], errs[i] = p.runParallelStep(ctx, shared, boxes[i], step, orders[i])
+		}(i, step)
+	}
+	wg.Wait()
+
+	outputDir := p.options.GuestPath("output")
+	for i, box := range boxes {
+		if err := shared.box.CopyFrom(box, outputDir); err != nil {
+			p.logger.WithField("Error", err).Warnln("Unable to copy the output dir of step", steps[i].DisplayName())
+		}
+		box.Stop()
+		if p.options.ShouldRemove {
+			box.Clean()
+		}
+	}
+
+	return parallelResult(steps, results, errs)
+}
+
+// runParallelStep runs one step of a parallel group in box
+func (p *Runner) runParallelStep(ctx context.Context, shared *RunnerShared, box core.Box, step core.Step, order int) (*StepResult, error) {
+	e := p.emitter.Fork(fmt.Sprintf("[%s] ", step.DisplayName()))
+	stepShared := &RunnerShared{
+		box:         box,
+		pipeline:    shared.pipeline,
+		config:      shared.config,
+		containerID: box.GetID(),
+		emitter:     e,
+		deadline:    shared.deadline,
+		outputs:     shared.outputs,
+	}
+
+	sessionCtx, sess, err := p.GetSession(core.WithEmitter(ctx, e), shared.pipeline.Transport(), box.GetID())
+	if err == nil {
+		stepShared.sess = sess
+		stepShared.sessionCtx = sessionCtx
+		// The shell in the new container starts out empty, the step hasn't
+		// started yet so keep this out of its logs
+		sess.HideLogs()
+		err = shared.pipeline.ExportEnvironment(sessionCtx, sess)
+		sess.ShowLogs()
+	}
+	if err != nil {
+		sr := &StepResult{Message: err.Error(), ExitCode: 1}
+		p.StartStep(stepShared, step, order).Finish(sr)
+		return sr, err
+	}
+
+	shouldRun, err := ShouldRunStep(step, shared.pipeline.Env())
+	if err != nil {
+		sr := &StepResult{Message: err.Error(), ExitCode: 1}
+		p.StartStep(stepShared, step, order).Finish(sr)
+		return sr, err
+	}
+	if !shouldRun {
+		return p.SkipStep(stepShared, step, order), nil
+	}
+	return p.RunStep(ctx, stepShared, step, order)
+}
+
+// parallelResult combines the results of the steps in a parallel group,
+// the group fails if any of its steps failed.
+func parallelResult(steps []core.Step, results []*StepResult, errs []error) (*StepResult, error) {
+	sr := &StepResult{
+		Success:  true,
+		Message:  "",
+		ExitCode: 0,
+	}
+	failed := []string{}
+	for i, step := range steps {
+		if errs[i] == nil {
+			continue
+		}
+		failed = append(failed, step.DisplayName())
+		if sr.ExitCode == 0 {
+			sr.ExitCode = results[i].ExitCode
+		}
+		if results[i].TimedOut {
+			sr.TimedOut = true
+		}
+	}
+	if len(failed) == 0 {
+		return sr, nil
+	}
+	sr.Success = false
+	sr.Message = fmt.Sprintf("Parallel steps failed: %s", strings.Join(failed, ", "))
+	return sr, errors.New(sr.Message)
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 	"github.com/wercker/wercker/core"
@@ -125,3 +126,182 @@ func (s *RunnerSuite) TestRunnerStepFailedOnInitEnvError() {
 	s.Equal(sr.Message, initEnvErrorMessage)
 	s.NotEqual(sr.ExitCode, 0)
 }
+
+func (s *RunnerSuite) TestRunnerSkipStep() {
+	env := util.NewEnvironment("WERCKER_GIT_BRANCH=feature")
+	step := &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{
+		When: `WERCKER_GIT_BRANCH == "master"`,
+	})}
+
+	shouldRun, err := ShouldRunStep(step, env)
+	s.Nil(err)
+	s.False(shouldRun)
+
+	runner := &Runner{}
+	runner.emitter = core.NewNormalizedEmitter()
+	var finished *core.BuildStepFinishedArgs
+	runner.emitter.AddListener(core.BuildStepFinished, func(args *core.BuildStepFinishedArgs) {
+		finished = args
+	})
+
+	sr := runner.SkipStep(&RunnerShared{}, step, 3)
+	s.True(sr.Skipped)
+	s.Require().NotNil(finished)
+	s.True(finished.Skipped)
+	s.True(finished.Successful)
+	s.Equal(3, finished.Order)
+
+	shouldRun, err = ShouldRunStep(&MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{})}, env)
+	s.Nil(err)
+	s.True(shouldRun)
+}
+
+func (s *RunnerSuite) TestRunnerParallelStepsReportOwnStep() {
+	runner := &Runner{}
+	runner.emitter = core.NewNormalizedEmitter()
+	finished := []*core.BuildStepFinishedArgs{}
+	runner.emitter.AddListener(core.BuildStepFinished, func(args *core.BuildStepFinishedArgs) {
+		finished = append(finished, args)
+	})
+
+	lint := &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{DisplayName: "lint"})}
+	test := &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{DisplayName: "test"})}
+	lintFinisher := runner.StartStep(&RunnerShared{emitter: runner.emitter.Fork("[lint] ")}, lint, 4)
+	testFinisher := runner.StartStep(&RunnerShared{emitter: runner.emitter.Fork("[test] ")}, test, 5)
+
+	// Finishing out of order still reports the right step
+	testFinisher.Finish(&StepResult{Success: true})
+	lintFinisher.Finish(&StepResult{Success: false})
+
+	s.Require().Len(finished, 2)
+	s.Equal(test, finished[0].Step)
+	s.Equal(5, finished[0].Order)
+	s.True(finished[0].Successful)
+	s.Equal(lint, finished[1].Step)
+	s.Equal(4, finished[1].Order)
+	s.False(finished[1].Successful)
+}
+
+func (s *RunnerSuite) TestRunnerParallelResult() {
+	lint := &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{DisplayName: "lint"})}
+	test := &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{DisplayName: "test"})}
+	steps := []core.Step{lint, test}
+
+	sr, err := parallelResult(steps,
+		[]*StepResult{{Success: true}, {Success: true}},
+		[]error{nil, nil})
+	s.Nil(err)
+	s.True(sr.Success)
+	s.Equal(0, sr.ExitCode)
+
+	sr, err = parallelResult(steps,
+		[]*StepResult{{Success: true}, {ExitCode: 2}},
+		[]error{nil, errors.New("Step failed with exit code: 2")})
+	s.Error(err)
+	s.False(sr.Success)
+	s.Equal(2, sr.ExitCode)
+	s.Equal("Parallel steps failed: test", sr.Message)
+}
+
+// flakyStep exits with each of exits in turn
+type flakyStep struct {
+	*MockStep
+	exits []int
+	calls int
+}
+
+func (s *flakyStep) Execute(context.Context, *core.Session) (int, error) {
+	exit := s.exits[s.calls]
+	s.calls++
+	if exit != 0 {
+		return exit, fmt.Errorf("Step failed with exit code: %d", exit)
+	}
+	return 0, nil
+}
+
+func (s *RunnerSuite) TestRunnerRetriesStep() {
+	runner := &Runner{}
+	runner.emitter = core.NewNormalizedEmitter()
+	logs := []string{}
+	runner.emitter.AddListener(core.Logs, func(args *core.LogsArgs) {
+		logs = append(logs, args.Logs)
+	})
+
+	step := &flakyStep{
+		MockStep: &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{
+			Retry: &core.RetryConfig{Attempts: 3, Backoff: 1, ExitCodes: []int{1}},
+		})},
+		exits: []int{1, 1, 0},
+	}
+	sr := &StepResult{}
+	exit, err := runner.executeStep(context.Background(), &RunnerShared{}, step, sr)
+	s.Nil(err)
+	s.Equal(0, exit)
+	s.Equal(3, sr.Attempts)
+	s.Equal([]string{
+		"\n--- Attempt 1 of 3 ---\n",
+		"Attempt 1 of 3 failed with exit code 1, retrying in 0s\n",
+		"\n--- Attempt 2 of 3 ---\n",
+		"Attempt 2 of 3 failed with exit code 1, retrying in 0s\n",
+		"\n--- Attempt 3 of 3 ---\n",
+	}, logs)
+
+	// Exit codes that aren't listed fail right away
+	logs = []string{}
+	step.exits = []int{2, 0}
+	step.calls = 0
+	exit, err = runner.executeStep(context.Background(), &RunnerShared{}, step, sr)
+	s.Error(err)
+	s.Equal(2, exit)
+	s.Equal(1, sr.Attempts)
+	s.Equal([]string{"\n--- Attempt 1 of 3 ---\n"}, logs)
+
+	// A step that isn't retried doesn't get the marks
+	logs = []string{}
+	once := &flakyStep{MockStep: &MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{})}, exits: []int{0}}
+	exit, err = runner.executeStep(context.Background(), &RunnerShared{}, once, sr)
+	s.Nil(err)
+	s.Equal(0, exit)
+	s.Empty(logs)
+}
+
+// hangingStep runs until the session context is done, like a command
+// that never finishes
+type hangingStep struct {
+	*MockStep
+}
+
+func (s *hangingStep) Execute(ctx context.Context, sess *core.Session) (int, error) {
+	<-ctx.Done()
+	return -1, core.ErrCommandTimeout
+}
+
+// killBox records the signals sent to its processes
+type killBox struct {
+	core.Box
+	signals []string
+}
+
+func (b *killBox) KillProcesses(signal string) error {
+	b.signals = append(b.signals, signal)
+	return nil
+}
+
+func (s *RunnerSuite) TestRunnerStepTimeout() {
+	runner := &Runner{}
+	runner.emitter = core.NewNormalizedEmitter()
+	box := &killBox{}
+	step := &hangingStep{&MockStep{BaseStep: core.NewBaseStep(core.BaseStepOptions{
+		Retry:   &core.RetryConfig{Attempts: 3, Backoff: 1},
+		Timeout: &core.TimeoutConfig{Step: 10 * time.Millisecond},
+	})}}
+	shared := &RunnerShared{box: box, sessionCtx: context.Background()}
+
+	sr := &StepResult{}
+	exit, err := runner.executeStep(context.Background(), shared, step, sr)
+	s.Equal(-1, exit)
+	s.EqualError(err, "Step timed out after 10ms")
+	s.True(sr.TimedOut)
+	s.Equal(1, sr.Attempts, "timeouts aren't retried")
+	s.Equal([]string{"KILL"}, box.signals)
+}
@@ -0,0 +1,84 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/wercker/wercker/secret"
+	"github.com/wercker/wercker/util"
+)
+
+// cmdSecretsKeygen writes a new identity and the recipient to encrypt for
+// it, or a symmetric key
+func cmdSecretsKeygen(w io.Writer, symmetric bool) error {
+	if symmetric {
+		key, err := secret.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, key)
+		return nil
+	}
+	identity, recipient, err := secret.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# recipient: %s\n%s\n", recipient, identity)
+	return nil
+}
+
+// cmdSecretsEncrypt encrypts the env file for keys and recipients, to
+// output or stdout
+func cmdSecretsEncrypt(file, output string, keys, recipients []string) error {
+	plaintext, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	// Make sure the pipeline will be able to load it
+	if err := util.NewEnvironment().LoadFile(file); err != nil {
+		return err
+	}
+	data, err := secret.Encrypt(plaintext, keys, recipients)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = fmt.Fprintln(os.Stdout, string(data))
+		return err
+	}
+	return ioutil.WriteFile(output, append(data, '\n'), 0644)
+}
+
+// cmdSecretsDecrypt writes the env file encrypted in file
+func cmdSecretsDecrypt(w io.Writer, file, key, identity string) error {
+	if key == "" && identity == "" {
+		return errors.New("Decrypt requires --identity or --key")
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	plaintext, err := secret.Decrypt(data, util.SplitSpaceOrComma(key), util.SplitSpaceOrComma(identity))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(plaintext)
+	return err
+}
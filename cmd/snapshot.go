@@ -0,0 +1,200 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+)
+
+// snapshotEnvName matches the names we can export again on resume, which
+// leaves out things like exported bash functions
+var snapshotEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// snapshotEnvIgnore are set by the shell, not by the steps
+var snapshotEnvIgnore = map[string]bool{
+	"HOSTNAME": true,
+	"OLDPWD":   true,
+	"PWD":      true,
+	"SHLVL":    true,
+	"_":        true,
+}
+
+// Snapshotter commits the box after every step that passed so a later run
+// can resume from there, see --snapshot-steps and --resume-from
+type Snapshotter struct {
+	options       *core.PipelineOptions
+	dockerOptions *dockerlocal.Options
+	store         *core.SnapshotStore
+	set           *core.SnapshotSet
+	logger        *util.LogEntry
+}
+
+// NewSnapshotter for the pipeline in options
+func NewSnapshotter(options *core.PipelineOptions, dockerOptions *dockerlocal.Options) *Snapshotter {
+	return &Snapshotter{
+		options:       options,
+		dockerOptions: dockerOptions,
+		store:         core.NewSnapshotStore(options),
+		logger:        util.RootLogger().WithField("Logger", "Snapshotter"),
+	}
+}
+
+// Prune removes the snapshots that are older than SnapshotMaxAge
+func (s *Snapshotter) Prune() error {
+	if s.options.SnapshotMaxAge <= 0 {
+		return nil
+	}
+	expired, err := s.store.Expired(time.Now().Add(-s.options.SnapshotMaxAge))
+	if err != nil {
+		return err
+	}
+	for _, set := range expired {
+		s.logger.WithField("Pipeline", set.Pipeline).Debugln("Removing expired snapshots")
+		s.removeImages(set.Snapshots)
+		if err := s.store.Remove(set.Pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resume finds the snapshot to resume from, see ResumeFrom
+func (s *Snapshotter) Resume() (*core.StepSnapshot, error) {
+	set, err := s.store.Load(s.options.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, fmt.Errorf("No snapshots for pipeline %s, run with --snapshot-steps first", s.options.Pipeline)
+	}
+	s.set = set
+	return set.ResumePoint(s.options.ResumeFrom)
+}
+
+// Start a run of pipeline, when resuming the snapshots after the one we
+// resume from are dropped since the steps will run again.
+func (s *Snapshotter) Start(pipeline core.Pipeline) error {
+	steps := []string{}
+	for _, step := range pipeline.Steps() {
+		steps = append(steps, step.DisplayName())
+	}
+
+	if s.options.Resume == nil {
+		if s.set == nil {
+			set, err := s.store.Load(s.options.Pipeline)
+			if err != nil {
+				return err
+			}
+			s.set = set
+		}
+		if s.set != nil {
+			s.removeImages(s.set.Snapshots)
+		}
+		s.set = core.NewSnapshotSet(s.options.Pipeline, s.options.RunID, steps)
+		return s.store.Save(s.set)
+	}
+
+	if err := s.set.CheckSteps(s.options.Resume, steps); err != nil {
+		return err
+	}
+	s.removeImages(s.set.Truncate(s.options.Resume.Index + 1))
+	s.set.RunID = s.options.RunID
+	s.set.Steps = steps
+	s.set.FailedStep = -1
+	s.set.Updated = time.Now()
+	return s.store.Save(s.set)
+}
+
+// Snapshot commits the box after the step at index passed, together with
+// the environment the next step starts with. Hidden values aren't stored.
+func (s *Snapshotter) Snapshot(shared *RunnerShared, step core.Step, index int) error {
+	tag := fmt.Sprintf("%s-%d", core.SnapshotName(s.options.Pipeline), index)
+	image, err := shared.box.Commit(core.SnapshotRepository(s.options), tag, "snapshot", false)
+	if err != nil {
+		return err
+	}
+
+	env, err := shared.pipeline.ReadEnvironment(shared.sessionCtx, shared.sess)
+	if err != nil {
+		return err
+	}
+	hidden := shared.pipeline.Env().Hidden.Map
+	snapshotEnv := [][]string{}
+	for _, pair := range env {
+		key := pair[0]
+		if _, ok := hidden[key]; ok || snapshotEnvIgnore[key] || !snapshotEnvName.MatchString(key) {
+			continue
+		}
+		snapshotEnv = append(snapshotEnv, pair)
+	}
+
+	replaced := s.set.Add(&core.StepSnapshot{
+		Index:   index,
+		Step:    step.DisplayName(),
+		Image:   image.ID,
+		Env:     snapshotEnv,
+		Created: time.Now(),
+	})
+	// Committing to the same tag moves it, the images we replaced are only
+	// referenced by their ID now
+	s.removeImages(replaced)
+	return s.store.Save(s.set)
+}
+
+// Failed records the step at index failed so --resume-from=failed can find it
+func (s *Snapshotter) Failed(index int) error {
+	s.set.FailedStep = index
+	s.set.Updated = time.Now()
+	return s.store.Save(s.set)
+}
+
+func (s *Snapshotter) removeImages(snapshots []*core.StepSnapshot) {
+	if len(snapshots) == 0 {
+		return
+	}
+	client, err := dockerlocal.NewDockerClient(s.dockerOptions)
+	if err != nil {
+		s.logger.WithField("Error", err).Warnln("Unable to remove snapshots")
+		return
+	}
+	for _, snapshot := range snapshots {
+		if err := client.RemoveImage(snapshot.Image); err != nil {
+			s.logger.WithField("Error", err).Debugln("Unable to remove snapshot", snapshot.Image)
+		}
+	}
+}
+
+// resumeEnvironment exports the environment of snapshot into the session
+func resumeEnvironment(shared *RunnerShared, snapshot *core.StepSnapshot) error {
+	env := util.NewEnvironment()
+	env.Update(snapshot.Env)
+	shared.sess.HideLogs()
+	defer shared.sess.ShowLogs()
+	exit, _, err := shared.sess.SendChecked(shared.sessionCtx, env.Export()...)
+	if err != nil {
+		return err
+	}
+	if exit != 0 {
+		return fmt.Errorf("Unable to restore environment, exit code: %d", exit)
+	}
+	shared.pipeline.Env().Update(snapshot.Env)
+	return nil
+}
@@ -0,0 +1,138 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+// StepCacher skips the steps with a cache-key whose inputs didn't change
+// since they last passed and restores their outputs instead, see
+// core.CacheKeyConfig
+type StepCacher struct {
+	options       *core.PipelineOptions
+	dockerOptions *dockerlocal.Options
+	store         *core.StepCacheStore
+	logger        *util.LogEntry
+}
+
+// NewStepCacher for the project in options
+func NewStepCacher(options *core.PipelineOptions, dockerOptions *dockerlocal.Options) *StepCacher {
+	return &StepCacher{
+		options:       options,
+		dockerOptions: dockerOptions,
+		store:         core.NewStepCacheStore(options),
+		logger:        util.RootLogger().WithField("Logger", "StepCacher"),
+	}
+}
+
+// outputPaths are the outputs of step in the container, relative ones are
+// in the source dir
+func (c *StepCacher) outputPaths(step core.Step) []string {
+	paths := []string{}
+	for _, output := range step.Outputs() {
+		if !path.IsAbs(output) {
+			output = path.Join(c.options.SourcePath(), output)
+		}
+		paths = append(paths, path.Clean(output))
+	}
+	return paths
+}
+
+// Restore the outputs stored for key into the container, false if nothing
+// is stored for key. Also returns the key/value outputs the step wrote.
+func (c *StepCacher) Restore(ctx context.Context, containerID string, key string) (bool, map[string]string, error) {
+	entry, dir, err := c.store.Load(key)
+	if err != nil || entry == nil {
+		return false, nil, err
+	}
+
+	client, err := dockerlocal.NewOfficialDockerClient(c.dockerOptions)
+	if err != nil {
+		return false, nil, err
+	}
+	dfc := dockerlocal.NewDockerFileCollector(client, containerID)
+	for i, output := range entry.Outputs {
+		err := func() error {
+			f, err := os.Open(c.store.OutputPath(dir, i))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return dfc.Restore(ctx, path.Dir(output), f)
+		}()
+		if err != nil {
+			return false, nil, err
+		}
+	}
+	return true, entry.Values, nil
+}
+
+// Save the outputs of step under key after it passed, outputs that don't
+// exist in the container are left out. values are the key/value outputs
+// the step wrote.
+func (c *StepCacher) Save(ctx context.Context, containerID string, step core.Step, key string, values map[string]string) error {
+	dir, err := c.store.TempDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	client, err := dockerlocal.NewOfficialDockerClient(c.dockerOptions)
+	if err != nil {
+		return err
+	}
+	dfc := dockerlocal.NewDockerFileCollector(client, containerID)
+	entry := &core.StepCacheEntry{
+		Key:     key,
+		Step:    step.DisplayName(),
+		Outputs: []string{},
+		Values:  values,
+		Created: time.Now(),
+	}
+	for _, output := range c.outputPaths(step) {
+		err := func() error {
+			archive, err := dfc.Collect(ctx, output)
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			f, err := os.Create(c.store.OutputPath(dir, len(entry.Outputs)))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			archive.Tee(f)
+			return archive.Stream()
+		}()
+		if err == util.ErrEmptyTarball {
+			c.logger.Debugln("Output not found, not caching it:", output)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entry.Outputs = append(entry.Outputs, output)
+	}
+	return c.store.Save(entry, dir)
+}
@@ -0,0 +1,222 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/docker"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// WorkflowResult is the outcome of a single pipeline in a workflow
+type WorkflowResult struct {
+	Name     string
+	Pipeline string
+	RunID    string
+	Source   string
+	Output   string
+	Duration time.Duration
+	Skipped  bool
+	Err      error
+}
+
+// Status is passed, failed or skipped
+func (r *WorkflowResult) Status() string {
+	switch {
+	case r.Skipped:
+		return "skipped"
+	case r.Err != nil:
+		return "failed"
+	}
+	return "passed"
+}
+
+// workflowRunFunc runs a single pipeline of a workflow with source as its
+// code, an empty source means the project itself.
+type workflowRunFunc func(node *core.WorkflowPipelineConfig, source string) *WorkflowResult
+
+// runWorkflow runs every pipeline of the workflow as soon as the pipelines
+// it requires have passed, so independent branches run concurrently. The
+// output of the first required pipeline is the source of the next one.
+// Pipelines that require a pipeline that didn't pass are skipped. The
+// workflow needs to have passed Validate.
+func runWorkflow(workflow *core.WorkflowConfig, run workflowRunFunc) []*WorkflowResult {
+	index := map[string]int{}
+	done := make([]chan struct{}, len(workflow.Pipelines))
+	for i, node := range workflow.Pipelines {
+		index[node.Name] = i
+		done[i] = make(chan struct{})
+	}
+
+	// Each goroutine only writes its own result and only reads the results
+	// of the pipelines it requires once they are done
+	results := make([]*WorkflowResult, len(workflow.Pipelines))
+	var wg sync.WaitGroup
+	for i, node := range workflow.Pipelines {
+		wg.Add(1)
+		go func(i int, node *core.WorkflowPipelineConfig) {
+			defer wg.Done()
+			defer close(done[i])
+
+			source := ""
+			for j, req := range node.Requires {
+				<-done[index[req]]
+				required := results[index[req]]
+				if required.Status() != "passed" {
+					results[i] = &WorkflowResult{
+						Name:     node.Name,
+						Pipeline: node.PipelineName(),
+						Skipped:  true,
+					}
+					return
+				}
+				if j == 0 {
+					source = required.Output
+				}
+			}
+			results[i] = run(node, source)
+		}(i, node)
+	}
+	wg.Wait()
+	return results
+}
+
+// readWerckerConfig finds and parses the wercker.yml for options, returning
+// the path it was read from as well.
+func readWerckerConfig(options *core.PipelineOptions) (*core.Config, string, error) {
+	werckerYml := options.WerckerYml
+	if werckerYml == "" {
+		if options.ProjectPath == "" {
+			return nil, "", fmt.Errorf("No wercker.yml found")
+		}
+		var err error
+		werckerYml, err = core.FindWerckerYaml([]string{options.ProjectPath})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	werckerYml, err := filepath.Abs(werckerYml)
+	if err != nil {
+		return nil, "", err
+	}
+	werckerYaml, err := ioutil.ReadFile(werckerYml)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := core.ConfigFromYaml(werckerYaml)
+	if err != nil {
+		return nil, "", err
+	}
+	return config, werckerYml, nil
+}
+
+// cmdWorkflow runs the workflow called name from the wercker.yml
+func cmdWorkflow(ctx context.Context, name string, options *core.PipelineOptions, dockerOptions *dockerlocal.Options) error {
+	logger := util.RootLogger().WithField("Logger", "Workflow")
+	f := &util.Formatter{ShowColors: options.GlobalOptions.ShowColors}
+
+	config, werckerYml, err := readWerckerConfig(options)
+	if err != nil {
+		return err
+	}
+	workflow, err := config.FindWorkflow(name)
+	if err != nil {
+		return err
+	}
+	err = workflow.Validate(config)
+	if err != nil {
+		return err
+	}
+
+	logger.Println(f.Info("Running workflow", workflow.Name))
+	timer := util.NewTimer()
+
+	results := runWorkflow(workflow, func(node *core.WorkflowPipelineConfig, source string) *WorkflowResult {
+		// Every pipeline gets its own RunID and copy of the code, and always
+		// collects its output so the next pipeline can use it
+		nodeOptions := *options
+		nodeOptions.RunID = bson.NewObjectId().Hex()
+		nodeOptions.Pipeline = node.PipelineName()
+		nodeOptions.WorkflowNode = node.Name
+		nodeOptions.ShouldArtifacts = true
+		nodeOptions.WerckerYml = werckerYml
+		if source != "" {
+			nodeOptions.ProjectPath = source
+			nodeOptions.ProjectURL = ""
+		}
+
+		logger.Println(f.Info("Running pipeline", node.Name, nodeOptions.ProjectPath))
+		pipelineTimer := util.NewTimer()
+		var err error
+		if node.Type == core.WorkflowTypeDeploy {
+			_, err = cmdDeploy(ctx, &nodeOptions, dockerOptions)
+		} else {
+			_, err = cmdBuild(ctx, &nodeOptions, dockerOptions)
+		}
+		return &WorkflowResult{
+			Name:     node.Name,
+			Pipeline: nodeOptions.Pipeline,
+			RunID:    nodeOptions.RunID,
+			Source:   nodeOptions.ProjectPath,
+			Output:   nodeOptions.HostPath("output"),
+			Duration: pipelineTimer.Elapsed(),
+			Err:      err,
+		}
+	})
+
+	for _, line := range strings.Split(strings.TrimRight(FormatWorkflowResults(results), "\n"), "\n") {
+		logger.Println(line)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status() != "passed" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		logger.Println(f.Fail("Workflow failed", workflow.Name, timer.String()))
+		return fmt.Errorf("%d of %d pipelines in workflow %s did not pass", failed, len(results), workflow.Name)
+	}
+	logger.Println(f.Success("Workflow passed", workflow.Name, timer.String()))
+	return nil
+}
+
+// FormatWorkflowResults renders the results as a table
+func FormatWorkflowResults(results []*WorkflowResult) string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPIPELINE\tRESULT\tDURATION\tRUN ID")
+	for _, result := range results {
+		runID := result.RunID
+		if runID == "" {
+			runID = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2fs\t%s\n", result.Name, result.Pipeline, result.Status(), result.Duration.Seconds(), runID)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,151 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type WorkflowSuite struct {
+	*util.TestSuite
+}
+
+func TestWorkflowSuite(t *testing.T) {
+	suiteTester := &WorkflowSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+// fakeWorkflowRun records the source each pipeline got and fails the ones
+// named in fail
+func fakeWorkflowRun(sources map[string]string, mutex *sync.Mutex, fail ...string) workflowRunFunc {
+	return func(node *core.WorkflowPipelineConfig, source string) *WorkflowResult {
+		mutex.Lock()
+		sources[node.Name] = source
+		mutex.Unlock()
+		result := &WorkflowResult{
+			Name:     node.Name,
+			Pipeline: node.PipelineName(),
+			RunID:    "run-" + node.Name,
+			Output:   "/output/" + node.Name,
+		}
+		if util.ContainsString(fail, node.Name) {
+			result.Err = errors.New("Step failed: script")
+		}
+		return result
+	}
+}
+
+func (s *WorkflowSuite) TestRunWorkflowHandsOffOutput() {
+	workflow := &core.WorkflowConfig{
+		Name: "release",
+		Pipelines: []*core.WorkflowPipelineConfig{
+			{Name: "deploy", Requires: []string{"package", "lint"}},
+			{Name: "package", Requires: []string{"test"}},
+			{Name: "build"},
+			{Name: "test", Requires: []string{"build"}},
+			{Name: "lint", Requires: []string{"build"}},
+		},
+	}
+	sources := map[string]string{}
+	results := runWorkflow(workflow, fakeWorkflowRun(sources, &sync.Mutex{}))
+
+	s.Require().Len(results, 5)
+	for _, result := range results {
+		s.Equal("passed", result.Status(), result.Name)
+	}
+	s.Equal(map[string]string{
+		"build":   "",
+		"test":    "/output/build",
+		"lint":    "/output/build",
+		"package": "/output/test",
+		"deploy":  "/output/package",
+	}, sources)
+}
+
+func (s *WorkflowSuite) TestRunWorkflowSkipsAfterFailure() {
+	workflow := &core.WorkflowConfig{
+		Name: "release",
+		Pipelines: []*core.WorkflowPipelineConfig{
+			{Name: "build"},
+			{Name: "test", Requires: []string{"build"}},
+			{Name: "lint", Requires: []string{"build"}},
+			{Name: "deploy", Requires: []string{"test", "lint"}},
+		},
+	}
+	sources := map[string]string{}
+	results := runWorkflow(workflow, fakeWorkflowRun(sources, &sync.Mutex{}, "lint"))
+
+	statuses := []string{}
+	for _, result := range results {
+		statuses = append(statuses, result.Status())
+	}
+	s.Equal([]string{"passed", "passed", "failed", "skipped"}, statuses)
+	_, ran := sources["deploy"]
+	s.False(ran)
+}
+
+func (s *WorkflowSuite) TestRunWorkflowConcurrentBranches() {
+	workflow := &core.WorkflowConfig{
+		Name: "release",
+		Pipelines: []*core.WorkflowPipelineConfig{
+			{Name: "test"},
+			{Name: "lint"},
+		},
+	}
+	// Both pipelines wait for each other, so this only finishes if they
+	// are running at the same time
+	var started sync.WaitGroup
+	started.Add(2)
+	bothStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(bothStarted)
+	}()
+
+	results := runWorkflow(workflow, func(node *core.WorkflowPipelineConfig, source string) *WorkflowResult {
+		started.Done()
+		result := &WorkflowResult{Name: node.Name, Pipeline: node.PipelineName()}
+		select {
+		case <-bothStarted:
+		case <-time.After(5 * time.Second):
+			result.Err = errors.New("timed out waiting for the other branch")
+		}
+		return result
+	})
+	s.Nil(results[0].Err)
+	s.Nil(results[1].Err)
+}
+
+func (s *WorkflowSuite) TestFormatWorkflowResults() {
+	table := FormatWorkflowResults([]*WorkflowResult{
+		{Name: "build", Pipeline: "build", RunID: "abc", Duration: 1500 * time.Millisecond},
+		{Name: "test", Pipeline: "test", RunID: "def", Duration: 2 * time.Second, Err: errors.New("Step failed: script")},
+		{Name: "deploy", Pipeline: "deploy-production", Skipped: true},
+	})
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	s.Require().Len(lines, 4)
+	s.Equal(strings.Fields("NAME PIPELINE RESULT DURATION RUN ID"), strings.Fields(lines[0]))
+	s.Equal(strings.Fields("build build passed 1.50s abc"), strings.Fields(lines[1]))
+	s.Equal(strings.Fields("test test failed 2.00s def"), strings.Fields(lines[2]))
+	s.Equal(strings.Fields("deploy deploy-production skipped 0.00s -"), strings.Fields(lines[3]))
+}
@@ -26,15 +26,26 @@ type BoxOptions struct {
 }
 
 type Box interface {
+	GetID() string
 	GetName() string
 	GetTag() string
 	Repository() string
 	Clean() error
 	Stop()
+	// KillProcesses sends a signal to everything running in the box
+	// except its shell, which ends whatever step is running
+	KillProcesses(string) error
 	Commit(string, string, string, bool) (*docker.Image, error)
 	Restart() (*docker.Container, error)
 	AddService(ServiceBox)
 	Fetch(context.Context, *util.Environment) (*docker.Image, error)
 	Run(context.Context, *util.Environment, string) (*docker.Container, error)
 	RecoverInteractive(string, Pipeline, Step) error
+	// Fork starts a container per name from the current state of the box,
+	// with the same mounts, environment and network. The forks only clean
+	// up their own containers.
+	Fork(context.Context, *util.Environment, []string) ([]Box, error)
+	// CopyFrom copies a dir from another box, like one of its forks, on top
+	// of the same dir in this one
+	CopyFrom(Box, string) error
 }
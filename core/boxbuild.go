@@ -0,0 +1,216 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+// DefaultDockerfile is the Dockerfile of a build without a dockerfile
+const DefaultDockerfile = "Dockerfile"
+
+// BoxBuildConfig is the `build:` of a box or service, the Dockerfile in the
+// project its image is built from
+type BoxBuildConfig struct {
+	Context    string            `yaml:"context"`
+	Dockerfile string            `yaml:"dockerfile"`
+	Args       map[string]string `yaml:"args"`
+	Target     string            `yaml:"target"`
+}
+
+// BuildContext is the files in the context of a build, what a .dockerignore
+// in it excludes is left out like docker build does
+type BuildContext struct {
+	Dir        string
+	Dockerfile string
+	// Files are relative to Dir, with the dirs, in the order they are walked
+	Files []string
+}
+
+// NewBuildContext finds the files in the context dir, dockerfile is
+// relative to it
+func NewBuildContext(dir, dockerfile string) (*BuildContext, error) {
+	if dockerfile == "" {
+		dockerfile = DefaultDockerfile
+	}
+	dockerfile = filepath.Clean(dockerfile)
+	if _, err := os.Stat(filepath.Join(dir, dockerfile)); err != nil {
+		return nil, fmt.Errorf("No %s in the build context %s", dockerfile, dir)
+	}
+	ignore, err := readDockerignore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &BuildContext{Dir: dir, Dockerfile: dockerfile}
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		// docker build always gets the Dockerfile and the .dockerignore
+		if rel != dockerfile && rel != ".dockerignore" && ignore != nil {
+			excluded, err := ignore.Matches(rel)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				// An exception may add back something in the dir
+				if info.IsDir() && !ignore.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		c.Files = append(c.Files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Hash changes when a file in the context or the way it is built changes,
+// it is the tag of the image so unchanged contexts don't get built again
+func (c *BuildContext) Hash(target string, args map[string]string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "dockerfile %s\x00target %s\x00", c.Dockerfile, target)
+	for _, pair := range orderedEnv(args) {
+		fmt.Fprintf(h, "arg %s=%s\x00", pair[0], pair[1])
+	}
+	for _, rel := range c.Files {
+		p := filepath.Join(c.Dir, rel)
+		info, err := os.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %s %s\x00", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s\x00", link)
+		case info.Mode().IsRegular():
+			if err := copyFile(h, p); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteTar writes the context as the tarball docker build reads
+func (c *BuildContext) WriteTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, rel := range c.Files {
+		p := filepath.Join(c.Dir, rel)
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			if err := copyFile(tw, p); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func copyFile(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// readDockerignore reads the .dockerignore in dir, if there is one. The
+// patterns are matched like docker build does, a pattern matching a dir
+// leaves out everything in it, ** matches any number of dirs and a pattern
+// starting with ! adds back what the ones before it left out.
+func readDockerignore(dir string) (*fileutils.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		exception := strings.HasPrefix(line, "!")
+		if exception {
+			line = strings.TrimSpace(line[1:])
+		}
+		// Patterns are relative to the context, /bin is the same as bin
+		line = filepath.Clean(strings.TrimPrefix(filepath.ToSlash(line), "/"))
+		if exception {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	ignore, err := fileutils.NewPatternMatcher(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid .dockerignore in %s: %s", dir, err)
+	}
+	return ignore, nil
+}
@@ -0,0 +1,203 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type BoxBuildSuite struct {
+	*util.TestSuite
+}
+
+func TestBoxBuildSuite(t *testing.T) {
+	suiteTester := &BoxBuildSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *BoxBuildSuite) writeFiles(dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(p), 0755))
+		s.Require().NoError(ioutil.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func (s *BoxBuildSuite) TestConfig() {
+	config, err := ConfigFromYaml([]byte(`
+box:
+  build:
+    context: .
+    dockerfile: ci/Dockerfile
+    args:
+      GO_VERSION: "1.10"
+    target: ci
+services:
+  - name: fixtures
+    build:
+      context: testdata/fixtures
+build:
+  steps:
+    - script:
+        code: make
+`))
+	s.Require().NoError(err)
+	s.Equal(&BoxBuildConfig{
+		Context:    ".",
+		Dockerfile: "ci/Dockerfile",
+		Args:       map[string]string{"GO_VERSION": "1.10"},
+		Target:     "ci",
+	}, config.Box.Build)
+	s.Equal("", config.Box.ID)
+	s.Equal("testdata/fixtures", config.Services[0].Build.Context)
+	s.Empty(config.LockBoxes())
+}
+
+func (s *BoxBuildSuite) TestContext() {
+	dir := s.WorkingDir()
+	s.writeFiles(dir, map[string]string{
+		"ci/Dockerfile":      "FROM golang:1.10",
+		".dockerignore":      "# build output\n/bin\n*.log\nci/Dockerfile\nvendor\n!vendor/keep.go\n",
+		"main.go":            "package main",
+		"bin/wercker":        "binary",
+		"build.log":          "log",
+		"vendor/dep/dep.go":  "package dep",
+		"vendor/keep.go":     "package vendor",
+		"docs/docs.log":      "log",
+		"docs/index.md":      "docs",
+		"ci/scripts/test.sh": "make test",
+	})
+
+	_, err := NewBuildContext(dir, "")
+	s.Error(err)
+
+	c, err := NewBuildContext(dir, "ci/Dockerfile")
+	s.Require().NoError(err)
+	s.Equal([]string{
+		".dockerignore",
+		"ci",
+		"ci/Dockerfile",
+		"ci/scripts",
+		"ci/scripts/test.sh",
+		"docs",
+		"docs/docs.log",
+		"docs/index.md",
+		"main.go",
+		"vendor/keep.go",
+	}, c.Files)
+
+	var b bytes.Buffer
+	s.Require().NoError(c.WriteTar(&b))
+	tr := tar.NewReader(&b)
+	names := []string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		s.Require().NoError(err)
+		names = append(names, hdr.Name)
+		if hdr.Name == "main.go" {
+			content, err := ioutil.ReadAll(tr)
+			s.Require().NoError(err)
+			s.Equal("package main", string(content))
+		}
+	}
+	s.Equal([]string{
+		".dockerignore",
+		"ci/",
+		"ci/Dockerfile",
+		"ci/scripts/",
+		"ci/scripts/test.sh",
+		"docs/",
+		"docs/docs.log",
+		"docs/index.md",
+		"main.go",
+		"vendor/keep.go",
+	}, names)
+}
+
+func (s *BoxBuildSuite) TestContextDoubleStar() {
+	dir := filepath.Join(s.WorkingDir(), "doublestar")
+	s.writeFiles(dir, map[string]string{
+		"Dockerfile":            "FROM golang:1.10",
+		".dockerignore":         "**/*.tmp\ndocs/**/draft*\n",
+		"main.go":               "package main",
+		"top.tmp":               "tmp",
+		"ci/scripts/build.tmp":  "tmp",
+		"docs/draft.md":         "draft",
+		"docs/guide/draft2.md":  "draft",
+		"docs/guide/install.md": "docs",
+	})
+
+	c, err := NewBuildContext(dir, "")
+	s.Require().NoError(err)
+	s.Equal([]string{
+		".dockerignore",
+		"Dockerfile",
+		"ci",
+		"ci/scripts",
+		"docs",
+		"docs/guide",
+		"docs/guide/install.md",
+		"main.go",
+	}, c.Files)
+}
+
+func (s *BoxBuildSuite) TestHash() {
+	dir := s.WorkingDir()
+	s.writeFiles(dir, map[string]string{
+		"Dockerfile":    "FROM golang:1.10",
+		".dockerignore": "*.log",
+		"main.go":       "package main",
+	})
+	hash := func() string {
+		c, err := NewBuildContext(dir, "")
+		s.Require().NoError(err)
+		h, err := c.Hash("", nil)
+		s.Require().NoError(err)
+		return h
+	}
+
+	first := hash()
+	s.Len(first, 64)
+	s.Equal(first, hash())
+
+	// Ignored files don't change it
+	s.writeFiles(dir, map[string]string{"build.log": "log"})
+	s.Equal(first, hash())
+
+	s.writeFiles(dir, map[string]string{"main.go": "package main\n"})
+	changed := hash()
+	s.NotEqual(first, changed)
+
+	c, err := NewBuildContext(dir, "")
+	s.Require().NoError(err)
+	withTarget, err := c.Hash("ci", nil)
+	s.Require().NoError(err)
+	s.NotEqual(changed, withTarget)
+	withArgs, err := c.Hash("", map[string]string{"GO_VERSION": "1.10"})
+	s.Require().NoError(err)
+	s.NotEqual(changed, withArgs)
+}
@@ -18,8 +18,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
@@ -44,6 +46,15 @@ type BoxConfig struct {
 	URL        string
 	Volumes    string
 	Auth       dockerauth.CheckAccessOptions `yaml:",inline"`
+	Resources  *ResourcesConfig              `yaml:"resources"`
+	// Healthcheck and DependsOn only apply to services, DependsOn are the
+	// names of the services that need to be ready before this one starts
+	Healthcheck *HealthcheckConfig `yaml:"healthcheck"`
+	DependsOn   []string           `yaml:"depends-on"`
+	// Logs shows the output of the service while the pipeline runs
+	Logs bool `yaml:"logs"`
+	// Build builds the image from a Dockerfile instead of pulling it
+	Build *BoxBuildConfig `yaml:"build"`
 }
 
 // IsExternal tells us if the box (service) is located on disk
@@ -81,8 +92,23 @@ type StepConfig struct {
 	Name       string
 	Data       map[string]string
 	Checkpoint string
+	When       string
+	Retry      *RetryConfig
+	Timeout    *TimeoutConfig
+	CacheKey   *CacheKeyConfig
+	Outputs    []string
+	// Properties keeps the step data as it was in the yaml, lists and maps
+	// included, Data has the string form of each with lists and maps as JSON
+	Properties map[string]interface{}
+	// Parallel is set for a `parallel:` group, the steps in it run at the
+	// same time
+	Parallel RawStepsConfig
 }
 
+// StepSettingsKey holds the retry, timeouts and cache of a step, under a key
+// of their own so they don't take properties a step.yml declares
+const StepSettingsKey = "wercker"
+
 // ifaceToString takes a value from yaml and makes it a string (currently
 // supported: string, int, bool). Returns an empty string if the type is not
 // supported.
@@ -114,6 +140,12 @@ func ifaceToString(dataValue interface{}) string {
 //        code: done right
 //    - script:      # this parses as a map[string]string
 //      code: done wrong
+// A fourth one is a group of steps to run in parallel:
+//    - parallel:    # this parses as a list of steps
+//        - script:
+//            code: one
+//        - script:
+//            code: two
 func (r *RawStepConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	r.StepConfig = &StepConfig{}
 
@@ -126,18 +158,25 @@ func (r *RawStepConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// Next check whether we are a one-key map
 	var stepID string
 	stepData := make(map[string]string)
+	properties := make(map[string]interface{})
 	var topMap yaml.MapSlice
 	err = unmarshal(&topMap)
 	if len(topMap) == 1 {
 		// The only item's key will be the stepID, value is data
 		item := topMap[0]
 		stepID = item.Key
+		if stepID == ParallelStepID {
+			if _, ok := item.Value.([]interface{}); ok {
+				return r.unmarshalParallel(item.Value)
+			}
+		}
 		interData, ok := item.Value.(yaml.MapSlice)
 		if !ok {
 			return fmt.Errorf("Step %s is empty", item.Key)
 		}
 		for _, item := range interData {
-			stepData[item.Key] = ifaceToString(item.Value)
+			properties[item.Key] = normalizeProperty(item.Value)
+			stepData[item.Key] = propertyToString(properties[item.Key])
 		}
 	} else {
 		// Otherwise the first element's key is the id, and the rest
@@ -146,7 +185,8 @@ func (r *RawStepConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		firstItem := topMap[0]
 		stepID = firstItem.Key
 		for _, item := range topMap[1:] {
-			stepData[item.Key] = ifaceToString(item.Value)
+			properties[item.Key] = normalizeProperty(item.Value)
+			stepData[item.Key] = propertyToString(properties[item.Key])
 		}
 	}
 
@@ -156,22 +196,176 @@ func (r *RawStepConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if v, ok := stepData["cwd"]; ok {
 		r.Cwd = v
 		delete(stepData, "cwd")
+		delete(properties, "cwd")
 	}
 	if v, ok := stepData["name"]; ok {
 		r.Name = v
 		delete(stepData, "name")
+		delete(properties, "name")
 	}
 	if v, ok := stepData["checkpoint"]; ok {
 		r.Checkpoint = v
 		delete(stepData, "checkpoint")
+		delete(properties, "checkpoint")
+	}
+	if v, ok := stepData["when"]; ok {
+		if _, err := ParseWhen(v); err != nil {
+			return fmt.Errorf("Invalid when condition for step %s: %s", stepID, err)
+		}
+		r.When = v
+		delete(stepData, "when")
+		delete(properties, "when")
+	}
+	if v, ok := properties[StepSettingsKey]; ok {
+		if err := r.unmarshalSettings(v); err != nil {
+			return fmt.Errorf("Invalid %s for step %s: %s", StepSettingsKey, stepID, err)
+		}
+		delete(stepData, StepSettingsKey)
+		delete(properties, StepSettingsKey)
 	}
 	r.Data = stepData
+	r.Properties = properties
+	return nil
+}
+
+// unmarshalSettings reads the `wercker:` map of a step, the settings for
+// how wercker runs it rather than properties of the step itself
+func (r *RawStepConfig) unmarshalSettings(value interface{}) error {
+	settings, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("should be a map, got %v", value)
+	}
+	for key, v := range settings {
+		var err error
+		switch key {
+		case "retry":
+			r.Retry, err = ParseRetry(v)
+		case "timeout", "no-response-timeout":
+			var timeout time.Duration
+			timeout, err = ParseTimeout(v)
+			if r.Timeout == nil {
+				r.Timeout = &TimeoutConfig{}
+			}
+			if key == "timeout" {
+				r.Timeout.Step = timeout
+			} else {
+				r.Timeout.NoResponse = timeout
+			}
+		case "cache-key":
+			r.CacheKey, err = ParseCacheKey(v)
+		case "outputs":
+			r.Outputs, err = ParseOutputs(v)
+		default:
+			return fmt.Errorf("unknown setting %s", key)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+	}
+	if r.Outputs != nil && r.CacheKey == nil {
+		return fmt.Errorf("outputs without a cache-key to store them under")
+	}
+	return nil
+}
+
+// unmarshalParallel reads the steps of a parallel group
+func (r *RawStepConfig) unmarshalParallel(value interface{}) error {
+	// Marshal the data so we can use the unmarshal logic on it
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var steps RawStepsConfig
+	err = yaml.Unmarshal(b, &steps)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("Step %s is empty", ParallelStepID)
+	}
+	for _, step := range steps {
+		if step.Parallel != nil {
+			return fmt.Errorf("Parallel steps can't be nested")
+		}
+	}
+	r.ID = ParallelStepID
+	r.Parallel = steps
 	return nil
 }
 
 // RawStepsConfig is a list of RawStepConfigs
 type RawStepsConfig []*RawStepConfig
 
+// MatrixConfig is one variant of a pipeline's matrix, anything set here
+// replaces the pipeline's own box and services and env is added to the
+// pipeline environment.
+type MatrixConfig struct {
+	Name     string
+	Box      *RawBoxConfig
+	Services []*RawBoxConfig
+	Env      map[string]string
+}
+
+// DisplayName is the variant's name or, if it doesn't have one, a summary
+// of its box and env
+func (m *MatrixConfig) DisplayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	parts := []string{}
+	if m.Box != nil {
+		parts = append(parts, m.Box.ID)
+	}
+	for _, pair := range m.OrderedEnv() {
+		parts = append(parts, fmt.Sprintf("%s=%s", pair[0], pair[1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// OrderedEnv returns the variant's env sorted by key
+func (m *MatrixConfig) OrderedEnv() [][]string {
+	return orderedEnv(m.Env)
+}
+
+// orderedEnv returns the pairs of env sorted by key
+func orderedEnv(env map[string]string) [][]string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := [][]string{}
+	for _, k := range keys {
+		pairs = append(pairs, []string{k, env[k]})
+	}
+	return pairs
+}
+
+// WorkflowConfig is a named DAG of pipelines, each pipeline's output
+// becomes the source of the pipelines that require it
+type WorkflowConfig struct {
+	Name      string
+	Pipelines []*WorkflowPipelineConfig
+}
+
+// WorkflowPipelineConfig is a single pipeline in a workflow, Name is how the
+// other pipelines in the workflow refer to it and Pipeline is the section of
+// the wercker.yml it runs, which defaults to Name.
+type WorkflowPipelineConfig struct {
+	Name     string
+	Pipeline string
+	Type     string
+	Requires []string
+}
+
+// PipelineName returns the name of the pipeline section to run
+func (w *WorkflowPipelineConfig) PipelineName() string {
+	if w.Pipeline != "" {
+		return w.Pipeline
+	}
+	return w.Name
+}
+
 // RawPipelineConfig is our unwrapper for PipelineConfig
 type RawPipelineConfig struct {
 	*PipelineConfig
@@ -189,6 +383,33 @@ type PipelineConfig struct {
 	Services   []*RawBoxConfig `yaml:"services"`
 	BasePath   string          `yaml:"base-path"`
 	Docker     bool            `yaml:"docker"`
+	Matrix     []*MatrixConfig `yaml:"matrix"`
+	// Env is added to the pipeline environment, values may refer to
+	// secrets with secret://provider/path#key
+	Env map[string]string `yaml:"env"`
+	// Timeout is how long the steps of the pipeline may take altogether,
+	// the after-steps run regardless
+	Timeout time.Duration `yaml:"-"`
+	// Transport is how commands are sent to the box, AttachTransport if
+	// empty or ExecTransport
+	Transport string `yaml:"transport"`
+}
+
+// StepsFor returns the steps to run for deployTarget and the section of the
+// pipeline they come from, which is "steps" unless the pipeline has a
+// section named after the deploy target.
+func (c *PipelineConfig) StepsFor(deployTarget string) (RawStepsConfig, string) {
+	if deployTarget != "" {
+		if sectionSteps, ok := c.StepsMap[deployTarget]; ok {
+			return sectionSteps, deployTarget
+		}
+	}
+	return c.Steps, "steps"
+}
+
+// OrderedEnv returns the pipeline's env sorted by key
+func (c *PipelineConfig) OrderedEnv() [][]string {
+	return orderedEnv(c.Env)
 }
 
 var pipelineReservedWords = map[string]struct{}{
@@ -198,6 +419,10 @@ var pipelineReservedWords = map[string]struct{}{
 	"after-steps": struct{}{},
 	"base-path":   struct{}{},
 	"docker":      struct{}{},
+	"matrix":      struct{}{},
+	"env":         struct{}{},
+	"timeout":     struct{}{},
+	"transport":   struct{}{},
 }
 
 // UnmarshalYAML in this case is a little involved due to the myriad shapes our
@@ -219,6 +444,18 @@ func (r *RawPipelineConfig) UnmarshalYAML(unmarshal func(interface{}) error) err
 	if err != nil {
 		return err
 	}
+	if v, ok := m["timeout"]; ok {
+		timeout, err := ParseTimeout(v)
+		if err != nil {
+			return fmt.Errorf("Invalid timeout for pipeline: %s", err)
+		}
+		r.PipelineConfig.Timeout = timeout
+	}
+	switch r.PipelineConfig.Transport {
+	case "", AttachTransport, ExecTransport:
+	default:
+		return fmt.Errorf("Invalid transport for pipeline: %s, expected %s or %s", r.PipelineConfig.Transport, AttachTransport, ExecTransport)
+	}
 	for k, v := range m {
 		// Skip the fields we already know
 		if _, ok := pipelineReservedWords[k]; ok {
@@ -249,13 +486,18 @@ func (r *RawPipelineConfig) UnmarshalYAML(unmarshal func(interface{}) error) err
 
 // Config is the data type for wercker.yml
 type Config struct {
-	Box               *RawBoxConfig   `yaml:"box"`
-	CommandTimeout    int             `yaml:"command-timeout"`
-	NoResponseTimeout int             `yaml:"no-response-timeout"`
-	Services          []*RawBoxConfig `yaml:"services"`
-	SourceDir         string          `yaml:"source-dir"`
-	IgnoreFile        string          `yaml:"ignore-file"`
-	PipelinesMap      map[string]*RawPipelineConfig
+	Box               *RawBoxConfig        `yaml:"box"`
+	CommandTimeout    int                  `yaml:"command-timeout"`
+	NoResponseTimeout int                  `yaml:"no-response-timeout"`
+	Services          []*RawBoxConfig      `yaml:"services"`
+	SourceDir         string               `yaml:"source-dir"`
+	IgnoreFile        string               `yaml:"ignore-file"`
+	Workflows         []*WorkflowConfig    `yaml:"workflows"`
+	Notifications     *NotificationsConfig `yaml:"notifications"`
+	Secrets           []*SecretPattern     `yaml:"secrets"`
+	// SecretProviders are the settings of each provider by name
+	SecretProviders map[string]map[string]string `yaml:"secret-providers"`
+	PipelinesMap    map[string]*RawPipelineConfig
 }
 
 // RawConfig is the unwrapper for Config
@@ -269,6 +511,10 @@ var configReservedWords = map[string]struct{}{
 	"no-response-timeout": struct{}{},
 	"services":            struct{}{},
 	"source-dir":          struct{}{},
+	"workflows":           struct{}{},
+	"notifications":       struct{}{},
+	"secrets":             struct{}{},
+	"secret-providers":    struct{}{},
 }
 
 // UnmarshalYAML in this case is a little involved due to the myriad shapes our
@@ -280,6 +526,11 @@ func (r *RawConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		PipelinesMap: make(map[string]*RawPipelineConfig),
 	}
 	err := unmarshal(r.Config)
+	if err != nil {
+		if _, ok := err.(*yaml.TypeError); !ok {
+			return err
+		}
+	}
 
 	// Then treat it like a map to get the extra fields
 	m := map[string]*RawPipelineConfig{}
@@ -329,6 +580,12 @@ func findYaml(searchDirs []string) (string, error) {
 	return "", fmt.Errorf("No wercker.yml found")
 }
 
+// FindWerckerYaml returns the path of the first wercker.yml found in
+// searchDirs
+func FindWerckerYaml(searchDirs []string) (string, error) {
+	return findYaml(searchDirs)
+}
+
 // ReadWerckerYaml will try to find a wercker.yml file and return its bytes.
 // TODO(termie): If allowDefault is true it will try to generate a
 // default yaml file by inspecting the project.
@@ -18,6 +18,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/wercker/wercker/auth"
 
@@ -123,3 +124,344 @@ func (s *ConfigSuite) TestIfaceToString() {
 		s.Equal(test.expected, actual, "")
 	}
 }
+
+func (s *ConfigSuite) TestConfigStepWhen() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: echo deploying
+        when: WERCKER_GIT_BRANCH == "master"
+  after-steps:
+    - slack-notifier:
+        when: WERCKER_RESULT == "failed"
+`))
+	s.Require().Nil(err)
+	build := config.PipelinesMap["build"]
+	s.Equal(`WERCKER_GIT_BRANCH == "master"`, build.Steps[0].When)
+	s.Equal(`WERCKER_RESULT == "failed"`, build.AfterSteps[0].When)
+	_, ok := build.Steps[0].Data["when"]
+	s.False(ok)
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: echo deploying
+        when: WERCKER_GIT_BRANCH = "master"
+`))
+	s.NotNil(err)
+}
+
+func (s *ConfigSuite) TestConfigMatrix() {
+	config, err := ConfigFromYaml([]byte(`
+box: golang:1.9
+build:
+  matrix:
+    - box: golang:1.9
+    - name: latest
+      box:
+        id: golang
+        tag: "1.10"
+      services:
+        - postgres:10
+      env:
+        DB: postgres
+        CGO_ENABLED: 0
+  steps:
+    - script:
+        code: go test ./...
+`))
+	s.Require().Nil(err)
+	build := config.PipelinesMap["build"]
+	s.Require().Len(build.Matrix, 2)
+	_, ok := build.StepsMap["matrix"]
+	s.False(ok)
+
+	s.Equal("golang:1.9", build.Matrix[0].DisplayName())
+	s.Nil(build.Matrix[0].Services)
+
+	latest := build.Matrix[1]
+	s.Equal("latest", latest.DisplayName())
+	s.Equal("golang", latest.Box.ID)
+	s.Equal("postgres:10", latest.Services[0].ID)
+	s.Equal([][]string{{"CGO_ENABLED", "0"}, {"DB", "postgres"}}, latest.OrderedEnv())
+
+	latest.Name = ""
+	s.Equal("golang CGO_ENABLED=0 DB=postgres", latest.DisplayName())
+}
+
+func (s *ConfigSuite) TestConfigStepProperties() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  steps:
+    - internal/docker-push:
+        name: push it
+        repository: wercker/example
+        ports:
+          - 8080
+          - 9090/udp
+        labels:
+          maintainer: wercker
+          version: 1.5
+        retries: 3
+`))
+	s.Require().Nil(err)
+	step := config.PipelinesMap["build"].Steps[0]
+
+	s.Equal("push it", step.Name)
+	_, ok := step.Properties["name"]
+	s.False(ok)
+
+	s.Equal("wercker/example", step.Data["repository"])
+	s.Equal(`[8080,"9090/udp"]`, step.Data["ports"])
+	s.Equal(`{"maintainer":"wercker","version":1.5}`, step.Data["labels"])
+	s.Equal("3", step.Data["retries"])
+
+	s.Equal([]interface{}{8080, "9090/udp"}, step.Properties["ports"])
+	s.Equal(map[string]interface{}{"maintainer": "wercker", "version": 1.5}, step.Properties["labels"])
+	s.Equal(3, step.Properties["retries"])
+
+	ports, ok := step.PropertyList("ports")
+	s.True(ok)
+	s.Equal([]string{"8080", "9090/udp"}, ports)
+	_, ok = step.PropertyList("repository")
+	s.False(ok)
+
+	labels, ok := step.PropertyMap("labels")
+	s.True(ok)
+	s.Equal(map[string]string{"maintainer": "wercker", "version": "1.5"}, labels)
+}
+
+func (s *ConfigSuite) TestConfigParallelSteps() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  steps:
+    - parallel:
+        - script:
+            name: lint
+            code: make lint
+        - script:
+            name: test
+            code: make test
+    - script:
+        code: make
+`))
+	s.Require().Nil(err)
+	steps := config.PipelinesMap["build"].Steps
+	s.Require().Len(steps, 2)
+
+	s.Equal(ParallelStepID, steps[0].ID)
+	s.Require().Len(steps[0].Parallel, 2)
+	s.Equal("lint", steps[0].Parallel[0].Name)
+	s.Equal("make test", steps[0].Parallel[1].Data["code"])
+	s.Nil(steps[1].Parallel)
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - parallel: []
+`))
+	s.Error(err)
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - parallel:
+        - parallel:
+            - script:
+                code: make
+`))
+	s.Error(err)
+}
+
+func (s *ConfigSuite) TestConfigStepRetry() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: npm install
+        wercker:
+          retry:
+            attempts: 3
+            delay: 2s
+            backoff: 2
+            exit-codes: [1, 28]
+    - script:
+        code: make
+        wercker:
+          retry: 2
+    - script:
+        code: make test
+`))
+	s.Require().Nil(err)
+	steps := config.PipelinesMap["build"].Steps
+	s.Require().Len(steps, 3)
+
+	s.Equal(&RetryConfig{Attempts: 3, Delay: 2 * time.Second, Backoff: 2, ExitCodes: []int{1, 28}}, steps[0].Retry)
+	_, ok := steps[0].Data["wercker"]
+	s.False(ok)
+	s.Equal(&RetryConfig{Attempts: 2, Backoff: 1}, steps[1].Retry)
+	s.Nil(steps[2].Retry)
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: make
+        wercker:
+          retry:
+            attempts: 0
+`))
+	s.Error(err)
+}
+
+func (s *ConfigSuite) TestConfigStepTimeout() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  timeout: 90
+  steps:
+    - script:
+        code: make integration
+        wercker:
+          timeout: 60
+    - script:
+        code: npm install
+        wercker:
+          timeout: 2m
+          no-response-timeout: 30s
+    - script:
+        code: make
+    - wait-for-deploy:
+        timeout: 300
+`))
+	s.Require().Nil(err)
+	pipeline := config.PipelinesMap["build"]
+	s.Equal(90*time.Minute, pipeline.Timeout)
+	s.NotContains(pipeline.StepsMap, "timeout")
+	steps := pipeline.Steps
+	s.Require().Len(steps, 4)
+
+	s.Equal(&TimeoutConfig{Step: time.Hour}, steps[0].Timeout)
+	s.Equal(&TimeoutConfig{Step: 2 * time.Minute, NoResponse: 30 * time.Second}, steps[1].Timeout)
+	s.Nil(steps[2].Timeout)
+
+	// A timeout next to the step's other properties is its own, for a step
+	// whose step.yml declares one
+	s.Nil(steps[3].Timeout)
+	s.Equal("300", steps[3].Data["timeout"])
+	desc := &StepDesc{
+		Name:       "wait-for-deploy",
+		Properties: []StepDescProperty{{Name: "timeout", Type: "number"}},
+	}
+	s.Nil(desc.ValidateProperties(steps[3].Properties))
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: make
+        wercker:
+          timeout: forever
+`))
+	s.Error(err)
+}
+
+func (s *ConfigSuite) TestConfigPipelineTransport() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  transport: exec
+  steps:
+    - script:
+        code: make
+deploy:
+  steps:
+    - script:
+        code: make deploy
+`))
+	s.Require().Nil(err)
+	s.Equal(ExecTransport, config.PipelinesMap["build"].Transport)
+	s.NotContains(config.PipelinesMap["build"].StepsMap, "transport")
+	s.Equal("", config.PipelinesMap["deploy"].Transport)
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  transport: ssh
+  steps:
+    - script:
+        code: make
+`))
+	s.Error(err)
+}
+
+func (s *ConfigSuite) TestConfigSecretProviders() {
+	config, err := ConfigFromYaml([]byte(`
+secret-providers:
+  ci:
+    type: vault
+    address: https://vault.example.com
+build:
+  env:
+    TOKEN: secret://ci/deploy#token
+    DEBUG: 1
+  steps:
+    - script:
+        code: make
+`))
+	s.Require().Nil(err)
+	s.Equal(map[string]string{"type": "vault", "address": "https://vault.example.com"}, config.SecretProviders["ci"])
+	s.NotContains(config.PipelinesMap, "secret-providers")
+	build := config.PipelinesMap["build"]
+	s.Equal([][]string{{"DEBUG", "1"}, {"TOKEN", "secret://ci/deploy#token"}}, build.OrderedEnv())
+	s.NotContains(build.StepsMap, "env")
+}
+
+func (s *ConfigSuite) TestConfigStepCacheKey() {
+	config, err := ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: npm install
+        wercker:
+          cache-key:
+            files: [package.json, yarn.lock]
+            env: [NODE_VERSION]
+          outputs: [node_modules]
+    - script:
+        code: go generate
+        wercker:
+          cache-key: [gen/*.go]
+    - publish-docs:
+        outputs: [site]
+`))
+	s.Require().Nil(err)
+	steps := config.PipelinesMap["build"].Steps
+	s.Require().Len(steps, 3)
+
+	s.Equal(&CacheKeyConfig{Files: []string{"package.json", "yarn.lock"}, Env: []string{"NODE_VERSION"}}, steps[0].CacheKey)
+	s.Equal([]string{"node_modules"}, steps[0].Outputs)
+	s.Equal(&CacheKeyConfig{Files: []string{"gen/*.go"}}, steps[1].CacheKey)
+	s.Nil(steps[1].Outputs)
+	s.Nil(steps[2].Outputs)
+	s.Equal(`["site"]`, steps[2].Data["outputs"])
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: npm install
+        wercker:
+          outputs: [node_modules]
+`))
+	s.Error(err, "outputs without a cache-key")
+
+	_, err = ConfigFromYaml([]byte(`
+build:
+  steps:
+    - script:
+        code: npm install
+        wercker:
+          cache: [package.json]
+`))
+	s.Error(err, "unknown setting")
+}
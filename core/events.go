@@ -15,10 +15,12 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/chuckpreslar/emission"
 	"github.com/wercker/wercker/util"
@@ -110,6 +112,15 @@ type BuildStepFinishedArgs struct {
 	PackageURL string
 	// Only applicable to the setup environment step
 	WerckerYamlContents string
+	// Only applicable to steps with a when condition
+	Skipped bool
+	// How often the step was executed, more than once if it was retried
+	Attempts int
+	// Only applicable to steps with a cache-key, set if the outputs were
+	// restored instead of running the step
+	Cached bool
+	// The key/value outputs the step wrote, see StepOutputsFile
+	Outputs map[string]string
 }
 
 // FullPipelineFinishedArgs contains the args associated with the
@@ -199,11 +210,38 @@ type NormalizedEmitter struct {
 	build        Pipeline         // Set by BuildStepsAdded
 	currentOrder int              // Set by BuildStepStarted
 	currentStep  Step             // Set by BuildStepStarted
+
+	// The secrets are masked in the logs before they are emitted, held is
+	// the end of each stream that may be the start of one
+	secrets *Secrets
+	held    map[logKey]string
+
+	// Only used by forks, see Fork
+	logPrefix string
+	logLock   sync.Mutex
+	partial   map[logKey]string // The unfinished line of each stream
+}
+
+// logKey is the step and stream logs are held back for, so that they are
+// flushed with the step they belong to
+type logKey struct {
+	step   Step
+	order  int
+	stream string
 }
 
 // NewNormalizedEmitter constructor
 func NewNormalizedEmitter() *NormalizedEmitter {
-	return &NormalizedEmitter{Emitter: emission.NewEmitter()}
+	return &NormalizedEmitter{
+		Emitter: emission.NewEmitter(),
+		secrets: NewSecrets(),
+	}
+}
+
+// Secrets are masked in the logs emitted, the forks of the emitter share
+// them
+func (e *NormalizedEmitter) Secrets() *Secrets {
+	return e.secrets
 }
 
 // Emit normalizes our events by storing some state
@@ -231,6 +269,8 @@ func (e *NormalizedEmitter) Emit(event interface{}, args interface{}) {
 		if a.Build == nil {
 			a.Build = e.build
 		}
+		// Whatever is still held back was logged before the step
+		e.Flush()
 		e.currentStep = a.Step
 		e.currentOrder = a.Order
 		e.Emitter.Emit(event, a)
@@ -252,6 +292,21 @@ func (e *NormalizedEmitter) Emit(event interface{}, args interface{}) {
 		if a.Stream == "" {
 			a.Stream = "stdout"
 		}
+		key := logKey{step: a.Step, order: a.Order, stream: a.Stream}
+		if a.Hidden {
+			a.Logs = e.secrets.Mask(a.Logs)
+		} else if a.Logs != "" {
+			a.Logs = e.maskLogs(key, a.Logs)
+			if a.Logs == "" {
+				return
+			}
+		}
+		if e.logPrefix != "" && !a.Hidden {
+			a.Logs = e.prefixLogs(key, a.Logs)
+			if a.Logs == "" {
+				return
+			}
+		}
 		e.Emitter.Emit(event, a)
 	// Add options, build, step, order, reset step and order after
 	case BuildStepFinished:
@@ -268,6 +323,11 @@ func (e *NormalizedEmitter) Emit(event interface{}, args interface{}) {
 		if a.Order == 0 {
 			a.Order = e.currentOrder
 		}
+		a.Message = e.secrets.Mask(a.Message)
+		step, order := a.Step, a.Order
+		e.flushLogs(func(key logKey) bool {
+			return key.step == step && key.order == order
+		})
 		e.Emitter.Emit(event, a)
 		e.currentStep = nil
 		e.currentOrder = -1
@@ -288,12 +348,145 @@ func (e *NormalizedEmitter) Emit(event interface{}, args interface{}) {
 	}
 }
 
+// Fork returns an emitter with the same listeners that keeps track of its
+// own current step, so steps running at the same time each get their logs
+// and events right. Every line logged through it starts with prefix.
+func (e *NormalizedEmitter) Fork(prefix string) *NormalizedEmitter {
+	return &NormalizedEmitter{
+		Emitter:   e.Emitter,
+		options:   e.options,
+		build:     e.build,
+		secrets:   e.secrets,
+		logPrefix: prefix,
+	}
+}
+
+// maskLogs masks the secrets in logs, the end of the logs is held back as
+// long as it may be the start of a secret that is logged in parts.
+func (e *NormalizedEmitter) maskLogs(key logKey, logs string) string {
+	e.logLock.Lock()
+	defer e.logLock.Unlock()
+	if e.held == nil {
+		e.held = make(map[logKey]string)
+	}
+
+	logs = e.secrets.Mask(e.held[key] + logs)
+	cut := e.secrets.Hold(logs)
+	e.held[key] = logs[cut:]
+	return logs[:cut]
+}
+
+// prefixLogs puts the prefix in front of every line in logs. The last line
+// is held back until it is finished so that lines from forks don't end up
+// mixed together.
+func (e *NormalizedEmitter) prefixLogs(key logKey, logs string) string {
+	e.logLock.Lock()
+	defer e.logLock.Unlock()
+	if e.partial == nil {
+		e.partial = make(map[logKey]string)
+	}
+
+	logs = e.partial[key] + logs
+	end := strings.LastIndex(logs, "\n") + 1
+	e.partial[key] = logs[end:]
+
+	var b bytes.Buffer
+	for _, line := range strings.SplitAfter(logs[:end], "\n") {
+		if line != "" {
+			b.WriteString(e.logPrefix)
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// Flush emits all the logs that are held back, each stream with the step it
+// was logged for. Forks that don't run a step are never flushed at the end
+// of one and need this.
+func (e *NormalizedEmitter) Flush() {
+	e.flushLogs(func(logKey) bool { return true })
+}
+
+// flushLogs emits the logs maskLogs and the lines prefixLogs held back for
+// the steps and streams flush is true for
+func (e *NormalizedEmitter) flushLogs(flush func(logKey) bool) {
+	e.logLock.Lock()
+	held := takeLogs(e.held, flush)
+	e.logLock.Unlock()
+
+	for _, key := range sortedLogKeys(held) {
+		logs := e.secrets.Mask(held[key])
+		if e.logPrefix != "" {
+			// The unfinished line is flushed with the partial lines below
+			logs = e.prefixLogs(key, logs)
+		}
+		if logs == "" {
+			continue
+		}
+		e.emitFlushed(key, logs)
+	}
+
+	e.logLock.Lock()
+	partial := takeLogs(e.partial, flush)
+	e.logLock.Unlock()
+
+	for _, key := range sortedLogKeys(partial) {
+		e.emitFlushed(key, e.logPrefix+partial[key]+"\n")
+	}
+}
+
+func (e *NormalizedEmitter) emitFlushed(key logKey, logs string) {
+	e.Emitter.Emit(Logs, &LogsArgs{
+		Options: e.options,
+		Build:   e.build,
+		Step:    key.step,
+		Order:   key.order,
+		Stream:  key.stream,
+		Logs:    logs,
+	})
+}
+
+// takeLogs removes the logs flush is true for from logs and returns them
+func takeLogs(logs map[logKey]string, flush func(logKey) bool) map[logKey]string {
+	taken := make(map[logKey]string)
+	for key, l := range logs {
+		if !flush(key) {
+			continue
+		}
+		delete(logs, key)
+		if l != "" {
+			taken[key] = l
+		}
+	}
+	return taken
+}
+
+// sortedLogKeys is the keys of logs by order and stream
+func sortedLogKeys(logs map[logKey]string) []logKey {
+	keys := make([]logKey, 0, len(logs))
+	for key := range logs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].order != keys[j].order {
+			return keys[i].order < keys[j].order
+		}
+		return keys[i].stream < keys[j].stream
+	})
+	return keys
+}
+
 // NewEmitterContext gives us a new context with an emitter
 func NewEmitterContext(ctx context.Context) context.Context {
 	e := NewNormalizedEmitter()
 	return context.WithValue(ctx, "Emitter", e)
 }
 
+// WithEmitter gives us a new context with e as the emitter
+func WithEmitter(ctx context.Context, e *NormalizedEmitter) context.Context {
+	return context.WithValue(ctx, "Emitter", e)
+}
+
 // EmitterFromContext gives us the emitter attached to the context
 func EmitterFromContext(ctx context.Context) (e *NormalizedEmitter, err error) {
 	e, ok := ctx.Value("Emitter").(*NormalizedEmitter)
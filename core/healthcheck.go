@@ -0,0 +1,142 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Healthcheck types
+const (
+	TCPHealthcheck     = "tcp"
+	HTTPHealthcheck    = "http"
+	CommandHealthcheck = "command"
+)
+
+// HealthcheckConfig is the `healthcheck:` of a service, how to tell it is
+// ready
+type HealthcheckConfig struct {
+	Type     string
+	Port     int
+	Path     string
+	Command  string
+	Interval time.Duration
+	Retries  int
+	Timeout  time.Duration
+}
+
+// UnmarshalYAML reads the healthcheck and fills in the defaults
+func (h *HealthcheckConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	m := map[string]interface{}{}
+	if err := unmarshal(&m); err != nil {
+		return fmt.Errorf("healthcheck should be a map with tcp, http or command")
+	}
+	*h = HealthcheckConfig{
+		Interval: time.Second,
+		Retries:  60,
+		Timeout:  5 * time.Second,
+	}
+	for key, item := range m {
+		var err error
+		switch key {
+		case TCPHealthcheck, HTTPHealthcheck, CommandHealthcheck:
+			if h.Type != "" {
+				return fmt.Errorf("healthcheck should have one of tcp, http or command, got %s and %s", h.Type, key)
+			}
+			h.Type = key
+			err = h.parseCheck(fmt.Sprint(item))
+		case "interval":
+			h.Interval, err = parseHealthcheckDuration(key, item)
+		case "timeout":
+			h.Timeout, err = parseHealthcheckDuration(key, item)
+		case "retries":
+			n, ok := item.(int)
+			if !ok || n < 1 {
+				err = fmt.Errorf("healthcheck.retries should be a positive number, got %v", item)
+			}
+			h.Retries = n
+		default:
+			err = fmt.Errorf("Unknown healthcheck setting %s, expected tcp, http, command, interval, retries or timeout", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if h.Type == "" {
+		return fmt.Errorf("healthcheck should have one of tcp, http or command")
+	}
+	return nil
+}
+
+// parseCheck reads the port, port/path or command of the check
+func (h *HealthcheckConfig) parseCheck(value string) error {
+	if h.Type == CommandHealthcheck {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("healthcheck.command can't be empty")
+		}
+		h.Command = value
+		return nil
+	}
+	port := value
+	if h.Type == HTTPHealthcheck {
+		parts := strings.SplitN(value, "/", 2)
+		port = parts[0]
+		h.Path = "/"
+		if len(parts) == 2 {
+			h.Path += parts[1]
+		}
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("healthcheck.%s should start with a port, got %s", h.Type, value)
+	}
+	h.Port = n
+	return nil
+}
+
+func parseHealthcheckDuration(key string, item interface{}) (time.Duration, error) {
+	var d time.Duration
+	switch v := item.(type) {
+	case int:
+		d = time.Duration(v) * time.Second
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	case string:
+		var err error
+		d, err = time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("healthcheck.%s should be a duration like 2s, got %s", key, v)
+		}
+	default:
+		return 0, fmt.Errorf("healthcheck.%s should be a duration like 2s, got %v", key, item)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("healthcheck.%s should be positive, got %s", key, d)
+	}
+	return d, nil
+}
+
+func (h *HealthcheckConfig) String() string {
+	switch h.Type {
+	case TCPHealthcheck:
+		return fmt.Sprintf("tcp %d", h.Port)
+	case HTTPHealthcheck:
+		return fmt.Sprintf("http %d%s", h.Port, h.Path)
+	}
+	return fmt.Sprintf("command %s", h.Command)
+}
@@ -0,0 +1,142 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+type HealthcheckSuite struct {
+	*util.TestSuite
+}
+
+func TestHealthcheckSuite(t *testing.T) {
+	suiteTester := &HealthcheckSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *HealthcheckSuite) TestConfig() {
+	config, err := ConfigFromYaml([]byte(`
+box: ubuntu
+services:
+  - id: postgres:10
+    healthcheck:
+      tcp: 5432
+  - id: elasticsearch:6.2.4
+    name: search
+    healthcheck:
+      http: 9200/_cluster/health
+      interval: 2
+      retries: 10
+      timeout: 500ms
+  - id: app
+    depends-on: [postgres, search]
+    healthcheck:
+      command: curl -f localhost:8080
+build:
+  steps:
+    - script:
+        code: make
+`))
+	s.Require().NoError(err)
+	s.Require().Len(config.Services, 3)
+
+	s.Equal(&HealthcheckConfig{
+		Type:     TCPHealthcheck,
+		Port:     5432,
+		Interval: time.Second,
+		Retries:  60,
+		Timeout:  5 * time.Second,
+	}, config.Services[0].Healthcheck)
+	s.Equal(&HealthcheckConfig{
+		Type:     HTTPHealthcheck,
+		Port:     9200,
+		Path:     "/_cluster/health",
+		Interval: 2 * time.Second,
+		Retries:  10,
+		Timeout:  500 * time.Millisecond,
+	}, config.Services[1].Healthcheck)
+	s.Equal("command curl -f localhost:8080", config.Services[2].Healthcheck.String())
+	s.Equal([]string{"postgres", "search"}, config.Services[2].DependsOn)
+}
+
+func (s *HealthcheckSuite) TestInvalidConfig() {
+	for _, healthcheck := range []string{
+		"{tcp: 5432, http: 8080}",
+		"{tcp: postgres}",
+		"{http: /health}",
+		"{interval: 2s}",
+		"{command: make, retries: 0}",
+		"{command: make, timeout: soon}",
+		"{command: make, delay: 2s}",
+	} {
+		_, err := ConfigFromYaml([]byte("box: ubuntu\nservices:\n  - id: postgres\n    healthcheck: " + healthcheck + "\n"))
+		s.Error(err, healthcheck)
+	}
+}
+
+type fakeService struct {
+	alias     string
+	dependsOn []string
+}
+
+func (f *fakeService) Run(context.Context, *util.Environment, []string) (*docker.Container, error) {
+	return nil, nil
+}
+func (f *fakeService) Fetch(context.Context, *util.Environment) (*docker.Image, error) {
+	return nil, nil
+}
+func (f *fakeService) GetID() string                        { return f.alias }
+func (f *fakeService) GetName() string                      { return f.alias }
+func (f *fakeService) GetServiceAlias() string              { return f.alias }
+func (f *fakeService) DependsOn() []string                  { return f.dependsOn }
+func (f *fakeService) WaitUntilReady(context.Context) error { return nil }
+
+func aliases(services []ServiceBox) []string {
+	names := []string{}
+	for _, service := range services {
+		names = append(names, service.GetServiceAlias())
+	}
+	return names
+}
+
+func (s *HealthcheckSuite) TestSortServices() {
+	sorted, err := SortServices([]ServiceBox{
+		&fakeService{alias: "app", dependsOn: []string{"search", "db"}},
+		&fakeService{alias: "cache"},
+		&fakeService{alias: "search", dependsOn: []string{"db"}},
+		&fakeService{alias: "db"},
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"db", "search", "app", "cache"}, aliases(sorted))
+
+	_, err = SortServices([]ServiceBox{
+		&fakeService{alias: "a", dependsOn: []string{"b"}},
+		&fakeService{alias: "b", dependsOn: []string{"c"}},
+		&fakeService{alias: "c", dependsOn: []string{"a"}},
+	})
+	s.Require().Error(err)
+	s.Equal("Services can't depend on each other: a -> b -> c -> a", err.Error())
+
+	_, err = SortServices([]ServiceBox{&fakeService{alias: "app", dependsOn: []string{"db"}}})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "depends on db")
+}
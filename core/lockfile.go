@@ -0,0 +1,202 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LockfileName is the name of the lockfile, it sits next to the wercker.yml
+const LockfileName = "wercker.lock"
+
+// LockfileVersion is the version of the lockfile format we write
+const LockfileVersion = 1
+
+const lockfileHeader = "# Written by wercker lock, run it again to update this file.\n"
+
+// Lockfile is wercker.lock, the digests of the images and the versions of
+// the steps a wercker.yml uses
+type Lockfile struct {
+	Version int `yaml:"version"`
+	// Images has the digest of each image by the name in the wercker.yml,
+	// with the tag
+	Images map[string]string `yaml:"images"`
+	// Steps are by the id in the wercker.yml, with the version if it has
+	// one
+	Steps map[string]*LockedStep `yaml:"steps"`
+}
+
+// LockedStep is the version of a step and its tarball
+type LockedStep struct {
+	Version  string `yaml:"version"`
+	URL      string `yaml:"url"`
+	Checksum string `yaml:"checksum"`
+}
+
+// NewLockfile is an empty lockfile
+func NewLockfile() *Lockfile {
+	return &Lockfile{
+		Version: LockfileVersion,
+		Images:  map[string]string{},
+		Steps:   map[string]*LockedStep{},
+	}
+}
+
+// ReadLockfile reads the lockfile at path, it returns nil if there isn't one
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lockfile := NewLockfile()
+	if err := yaml.Unmarshal(data, lockfile); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+	if lockfile.Version != LockfileVersion {
+		return nil, fmt.Errorf("Unknown version %d of %s, expected %d", lockfile.Version, path, LockfileVersion)
+	}
+	if lockfile.Images == nil {
+		lockfile.Images = map[string]string{}
+	}
+	if lockfile.Steps == nil {
+		lockfile.Steps = map[string]*LockedStep{}
+	}
+	return lockfile, nil
+}
+
+// Write the lockfile to path, the entries are sorted so it diffs well
+func (l *Lockfile) Write(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.WriteString(lockfileHeader)
+	b.Write(data)
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+// Checksum is how the tarballs of steps are checksummed in the lockfile
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Verify checks that tarball is the one that was locked
+func (s *LockedStep) Verify(tarball []byte) error {
+	if checksum := Checksum(tarball); checksum != s.Checksum {
+		return fmt.Errorf("The tarball at %s has checksum %s but %s has %s, run wercker lock --update if it changed on purpose", s.URL, checksum, LockfileName, s.Checksum)
+	}
+	return nil
+}
+
+// LockNameMatches tells whether name is one of names, which may leave out
+// the tag of an image or the version of a step
+func LockNameMatches(name string, names []string) bool {
+	for _, n := range names {
+		if name == n || strings.HasPrefix(name, n+":") || strings.HasPrefix(name, n+"@") {
+			return true
+		}
+	}
+	return false
+}
+
+// LockBoxes are the boxes and services of all the pipelines in config and
+// the images of their internal/docker-run steps. Boxes built from a local
+// dir or a Dockerfile can't be locked and are left out.
+func (c *Config) LockBoxes() []*BoxConfig {
+	raw := []*RawBoxConfig{c.Box}
+	raw = append(raw, c.Services...)
+	for _, name := range c.pipelineNames() {
+		pipeline := c.PipelinesMap[name]
+		raw = append(raw, pipeline.Box)
+		raw = append(raw, pipeline.Services...)
+		for _, variant := range pipeline.Matrix {
+			raw = append(raw, variant.Box)
+			raw = append(raw, variant.Services...)
+		}
+	}
+
+	boxes := []*BoxConfig{}
+	for _, box := range raw {
+		if box == nil || box.BoxConfig == nil || box.ID == "" || box.IsExternal() || box.Build != nil {
+			continue
+		}
+		boxes = append(boxes, box.BoxConfig)
+	}
+	for _, step := range c.LockSteps() {
+		if step.ID == "internal/docker-run" && step.Data["image"] != "" {
+			boxes = append(boxes, &BoxConfig{ID: step.Data["image"]})
+		}
+	}
+	return boxes
+}
+
+// LockSteps are the steps and after-steps of all the pipelines in config,
+// with the steps of parallel groups instead of the groups
+func (c *Config) LockSteps() []*StepConfig {
+	steps := []*StepConfig{}
+	var add func(raw RawStepsConfig)
+	add = func(raw RawStepsConfig) {
+		for _, step := range raw {
+			if step == nil || step.StepConfig == nil {
+				continue
+			}
+			if step.Parallel != nil {
+				add(step.Parallel)
+				continue
+			}
+			steps = append(steps, step.StepConfig)
+		}
+	}
+	for _, name := range c.pipelineNames() {
+		pipeline := c.PipelinesMap[name]
+		add(pipeline.Steps)
+		sections := []string{}
+		for section := range pipeline.StepsMap {
+			sections = append(sections, section)
+		}
+		sort.Strings(sections)
+		for _, section := range sections {
+			add(pipeline.StepsMap[section])
+		}
+		add(pipeline.AfterSteps)
+	}
+	return steps
+}
+
+// pipelineNames are the names of the pipelines in config, sorted
+func (c *Config) pipelineNames() []string {
+	names := []string{}
+	for name, pipeline := range c.PipelinesMap {
+		if pipeline != nil && pipeline.PipelineConfig != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,144 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type LockfileSuite struct {
+	*util.TestSuite
+}
+
+func TestLockfileSuite(t *testing.T) {
+	suiteTester := &LockfileSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *LockfileSuite) TestReadWrite() {
+	path := filepath.Join(s.WorkingDir(), LockfileName)
+	lockfile, err := ReadLockfile(path)
+	s.Require().NoError(err)
+	s.Nil(lockfile)
+
+	lockfile = NewLockfile()
+	lockfile.Images["golang:1.10"] = "sha256:b3ad"
+	lockfile.Steps["wercker/golint"] = &LockedStep{
+		Version:  "1.4.1",
+		URL:      "https://example.com/golint.tar.gz",
+		Checksum: Checksum([]byte("tarball")),
+	}
+	s.Require().NoError(lockfile.Write(path))
+
+	read, err := ReadLockfile(path)
+	s.Require().NoError(err)
+	s.Equal(lockfile, read)
+
+	s.Require().NoError(ioutil.WriteFile(path, []byte("version: 2\n"), 0644))
+	_, err = ReadLockfile(path)
+	s.Error(err)
+}
+
+func (s *LockfileSuite) TestVerify() {
+	locked := &LockedStep{URL: "https://example.com/golint.tar.gz", Checksum: Checksum([]byte("tarball"))}
+	s.Equal("sha256:", locked.Checksum[:7])
+	s.NoError(locked.Verify([]byte("tarball")))
+	s.Error(locked.Verify([]byte("changed")))
+}
+
+func (s *LockfileSuite) TestLockBoxesAndSteps() {
+	config, err := ConfigFromYaml([]byte(`
+box: golang:1.10
+services:
+  - postgres:10
+  - id: cache
+    url: file://./cache
+build:
+  steps:
+    - wercker/golint@1
+    - parallel:
+        - script:
+            code: make test
+        - internal/docker-run:
+            image: redis
+  after-steps:
+    - slack-notifier
+deploy:
+  box: alpine
+  services:
+    - redis:4
+  matrix:
+    - box: alpine:3.7
+  steps:
+    - script:
+        code: make deploy
+  production:
+    - wercker/heroku-deploy
+`))
+	s.Require().NoError(err)
+
+	ids := []string{}
+	for _, box := range config.LockBoxes() {
+		ids = append(ids, box.ID)
+	}
+	s.Equal([]string{"golang:1.10", "postgres:10", "alpine", "redis:4", "alpine:3.7", "redis"}, ids)
+
+	ids = []string{}
+	for _, step := range config.LockSteps() {
+		ids = append(ids, step.ID)
+	}
+	s.Equal([]string{
+		"wercker/golint@1", "script", "internal/docker-run", "slack-notifier",
+		"script", "wercker/heroku-deploy",
+	}, ids)
+
+	s.True(LockNameMatches("golang:1.10", []string{"golang"}))
+	s.True(LockNameMatches("wercker/golint@1", []string{"wercker/golint"}))
+	s.False(LockNameMatches("golang-tools:1", []string{"golang"}))
+}
+
+func (s *LockfileSuite) TestPin() {
+	options := EmptyPipelineOptions()
+	options.Lockfile = NewLockfile()
+	options.Lockfile.Steps["wercker/golint@1"] = &LockedStep{
+		Version: "1.4.1",
+		URL:     "https://example.com/golint.tar.gz",
+	}
+
+	step, err := NewStep(&StepConfig{ID: "wercker/golint@1"}, options)
+	s.Require().NoError(err)
+	s.True(step.Lockable())
+	s.Equal("wercker/golint@1", step.LockName())
+	version, url, err := step.Resolve()
+	s.Require().NoError(err)
+	s.Equal("1.4.1", version)
+	s.Equal("https://example.com/golint.tar.gz", url)
+
+	script, err := NewStep(&StepConfig{ID: "script", Data: map[string]string{"code": "make"}}, options)
+	s.Require().NoError(err)
+	s.False(script.Lockable())
+
+	options.FrozenLockfile = true
+	step, err = NewStep(&StepConfig{ID: "slack-notifier"}, options)
+	s.Require().NoError(err)
+	_, _, err = step.Resolve()
+	s.Require().Error(err)
+	s.Contains(err.Error(), "wercker/slack-notifier isn't in wercker.lock")
+}
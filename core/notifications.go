@@ -0,0 +1,161 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+const (
+	// WebhookBuildStarted is sent when the build starts
+	WebhookBuildStarted = "build-started"
+	// WebhookStepFinished is sent when a step is done, passed or not
+	WebhookStepFinished = "step-finished"
+	// WebhookPipelineFinished is sent when the pipeline is done, including
+	// the after-steps
+	WebhookPipelineFinished = "pipeline-finished"
+)
+
+// WebhookEvents are the events a webhook is sent for if it doesn't say
+var WebhookEvents = []string{
+	WebhookBuildStarted,
+	WebhookStepFinished,
+	WebhookPipelineFinished,
+}
+
+// NotificationsConfig is the `notifications:` section of wercker.yml
+type NotificationsConfig struct {
+	Webhooks []*WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig is a webhook in the notifications section
+type WebhookConfig struct {
+	URL     string
+	Secret  string
+	Events  []string
+	Headers map[string]string
+	Retry   *RetryConfig
+	// Template renders the payload, the JSON of the event if nil
+	Template *template.Template
+}
+
+// DefaultWebhookRetry is how webhooks are retried if they don't say
+func DefaultWebhookRetry() *RetryConfig {
+	return &RetryConfig{Attempts: 3, Delay: time.Second, Backoff: 2}
+}
+
+type rawWebhookConfig struct {
+	URL      string            `yaml:"url"`
+	Secret   string            `yaml:"secret"`
+	Events   []string          `yaml:"events"`
+	Headers  map[string]string `yaml:"headers"`
+	Retry    interface{}       `yaml:"retry"`
+	Template string            `yaml:"template"`
+}
+
+// UnmarshalYAML parses and checks the retry policy and template
+func (w *WebhookConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := &rawWebhookConfig{}
+	err := unmarshal(raw)
+	if err != nil {
+		return err
+	}
+
+	if raw.URL == "" {
+		return fmt.Errorf("Invalid webhook: url is required")
+	}
+	w.URL = raw.URL
+	w.Secret = raw.Secret
+	w.Headers = raw.Headers
+
+	w.Events = raw.Events
+	if len(w.Events) == 0 {
+		w.Events = WebhookEvents
+	}
+	for _, event := range w.Events {
+		if !isWebhookEvent(event) {
+			return fmt.Errorf("Invalid webhook event %s for %s, expected %s, %s or %s", event, raw.URL, WebhookBuildStarted, WebhookStepFinished, WebhookPipelineFinished)
+		}
+	}
+
+	w.Retry = DefaultWebhookRetry()
+	if raw.Retry != nil {
+		w.Retry, err = ParseRetry(normalizeProperty(raw.Retry))
+		if err != nil {
+			return fmt.Errorf("Invalid retry for webhook %s: %s", raw.URL, err)
+		}
+		if len(w.Retry.ExitCodes) > 0 {
+			return fmt.Errorf("Invalid retry for webhook %s: exit-codes only apply to steps", raw.URL)
+		}
+	}
+
+	if raw.Template != "" {
+		w.Template, err = ParseWebhookTemplate(raw.Template)
+		if err != nil {
+			return fmt.Errorf("Invalid template for webhook %s: %s", raw.URL, err)
+		}
+	}
+	return nil
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SendsEvent tells whether the webhook is sent for event
+func (w *WebhookConfig) SendsEvent(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL makes sure the url, with the env vars filled in, can be posted to
+func (w *WebhookConfig) CheckURL() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("Invalid webhook url %s: %s", w.URL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid webhook url %s, expected an http or https url", w.URL)
+	}
+	return nil
+}
+
+// webhookTemplateFuncs are available in payload templates, json quotes a
+// value so it can be put in a JSON payload
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseWebhookTemplate parses a payload template, it is executed with the
+// event in the same shape as --output=jsonl writes it
+func ParseWebhookTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+}
@@ -0,0 +1,107 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type NotificationsSuite struct {
+	*util.TestSuite
+}
+
+func TestNotificationsSuite(t *testing.T) {
+	suiteTester := &NotificationsSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *NotificationsSuite) TestConfigWebhooks() {
+	config, err := ConfigFromYaml([]byte(`
+box: golang
+notifications:
+  webhooks:
+    - url: https://chat.example.com/hooks/$CHAT_HOOK
+      secret: $CHAT_SECRET
+      events: [pipeline-finished]
+      headers:
+        X-Team: ci
+      retry:
+        attempts: 5
+        delay: 2s
+      template: '{"text": {{json .Pipeline}}}'
+    - url: https://deploys.example.com
+build:
+  steps:
+    - script:
+        code: go test
+`))
+	s.Require().NoError(err)
+	s.Require().NotNil(config.Notifications)
+	s.Require().Len(config.Notifications.Webhooks, 2)
+	s.NotContains(config.PipelinesMap, "notifications")
+
+	chat := config.Notifications.Webhooks[0]
+	s.Equal("https://chat.example.com/hooks/$CHAT_HOOK", chat.URL)
+	s.Equal("$CHAT_SECRET", chat.Secret)
+	s.Equal([]string{WebhookPipelineFinished}, chat.Events)
+	s.Equal(map[string]string{"X-Team": "ci"}, chat.Headers)
+	s.Equal(&RetryConfig{Attempts: 5, Delay: 2 * time.Second, Backoff: 1}, chat.Retry)
+	s.True(chat.SendsEvent(WebhookPipelineFinished))
+	s.False(chat.SendsEvent(WebhookStepFinished))
+	s.Require().NotNil(chat.Template)
+	var b bytes.Buffer
+	s.Require().NoError(chat.Template.Execute(&b, map[string]string{"Pipeline": "build"}))
+	s.Equal(`{"text": "build"}`, b.String())
+
+	deploys := config.Notifications.Webhooks[1]
+	s.Equal(WebhookEvents, deploys.Events)
+	s.Equal(DefaultWebhookRetry(), deploys.Retry)
+	s.Nil(deploys.Template)
+}
+
+func (s *NotificationsSuite) TestInvalidWebhooks() {
+	invalid := []string{
+		`{secret: s3cr3t}`,
+		`{url: "https://example.com", events: [step-started]}`,
+		`{url: "https://example.com", retry: {attempts: 0}}`,
+		`{url: "https://example.com", retry: {attempts: 2, exit-codes: [1]}}`,
+		`{url: "https://example.com", template: "{{.Pipeline"}`,
+	}
+	for _, webhook := range invalid {
+		_, err := ConfigFromYaml([]byte(`
+box: golang
+notifications:
+  webhooks:
+    - ` + webhook + `
+build:
+  steps:
+    - script:
+        code: go test
+`))
+		s.Error(err, webhook)
+	}
+}
+
+func (s *NotificationsSuite) TestCheckURL() {
+	s.NoError((&WebhookConfig{URL: "https://example.com/hook"}).CheckURL())
+	s.Error((&WebhookConfig{URL: "example.com/hook"}).CheckURL())
+	s.Error((&WebhookConfig{URL: "ftp://example.com"}).CheckURL())
+	s.Error((&WebhookConfig{URL: "https://"}).CheckURL())
+}
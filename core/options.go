@@ -26,6 +26,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pborman/uuid"
 	"github.com/wercker/wercker/util"
@@ -37,6 +38,13 @@ var (
 	DEFAULT_BASE_URL = "https://app.wercker.com"
 )
 
+const (
+	// OutputText prints the build for people to read
+	OutputText = "text"
+	// OutputJSONL also writes every event as a line of JSON
+	OutputJSONL = "jsonl"
+)
+
 // GlobalOptions applicable to everything
 type GlobalOptions struct {
 	BaseURL         string
@@ -351,10 +359,61 @@ type PipelineOptions struct {
 	EnableDevSteps bool
 	PublishPorts   []string
 	ExposePorts    bool
+	ServiceLogs    bool
 	EnableVolumes  bool
 	WerckerYml     string
 	Checkpoint     string
 
+	// Lockfile pins the images and steps, nil without a wercker.lock. With
+	// FrozenLockfile everything has to be pinned in it, whether a tag has
+	// moved in the registry since isn't checked.
+	Lockfile       *Lockfile
+	FrozenLockfile bool
+
+	// MatrixIndex selects a variant (1-based) of the pipeline's matrix
+	MatrixIndex    int
+	MatrixParallel bool
+
+	// WorkflowNode is the name of the pipeline in the workflow being run
+	WorkflowNode string
+
+	// SnapshotSteps commits the box after every step that passed so that
+	// a later run can resume from it with ResumeFrom, which implies it so
+	// resuming keeps the snapshots up to date
+	SnapshotSteps  bool
+	ResumeFrom     string
+	SnapshotMaxAge time.Duration
+	// Resume is the snapshot we are resuming from, the box starts from its
+	// image
+	Resume *StepSnapshot
+
+	// NoStepCache runs the steps with a cache-key even if their outputs
+	// are cached
+	NoStepCache bool
+
+	// Plan prints the resolved pipeline in PlanFormat instead of running it
+	Plan       bool
+	PlanFormat string
+
+	// Record is the file to record the build to as an asciicast
+	Record string
+
+	// Output is the format the events are written in, OutputFile is where
+	// to write them for OutputJSONL, stdout if empty
+	Output     string
+	OutputFile string
+
+	// Webhooks are posted to like the webhooks in the notifications
+	// section of wercker.yml, WebhookTemplate is a file with the template
+	Webhooks        []string
+	WebhookSecret   string
+	WebhookTemplate string
+
+	// Profile is the file to write the Chrome trace of the run to,
+	// ProfileSummary prints how long its phases took at the end
+	Profile        string
+	ProfileSummary bool
+
 	DefaultsUsed PipelineDefaultsUsed
 }
 
@@ -558,9 +617,32 @@ func NewPipelineOptions(c util.Settings, e *util.Environment) (*PipelineOptions,
 	// Deprecated
 	publishPorts, _ := c.StringSlice("publish")
 	exposePorts, _ := c.Bool("expose-ports")
+	serviceLogs, _ := c.Bool("service-logs")
 	enableVolumes, _ := c.Bool("enable-volumes")
 	werckerYml, _ := c.String("wercker-yml")
+	frozenLockfile, _ := c.Bool("frozen-lockfile")
 	checkpoint, _ := c.String("checkpoint")
+	matrixParallel, _ := c.Bool("matrix-parallel")
+	snapshotSteps, _ := c.Bool("snapshot-steps")
+	resumeFrom, _ := c.String("resume-from")
+	snapshotMaxAge, _ := c.Duration("snapshot-max-age")
+	noStepCache, _ := c.Bool("no-step-cache")
+	plan, _ := c.Bool("plan")
+	planFormat, _ := c.String("plan-format")
+	record, _ := c.String("record")
+	output, _ := c.String("output")
+	if output == "" {
+		output = OutputText
+	}
+	if output != OutputText && output != OutputJSONL {
+		return nil, fmt.Errorf("Unknown output %q, expected %q or %q", output, OutputText, OutputJSONL)
+	}
+	outputFile, _ := c.String("output-file")
+	webhooks, _ := c.StringSlice("webhook")
+	webhookSecret, _ := c.String("webhook-secret")
+	webhookTemplate, _ := c.String("webhook-template")
+	profile, _ := c.String("profile")
+	profileSummary, _ := c.Bool("profile-summary")
 
 	defaultsUsed := PipelineDefaultsUsed{
 		IgnoreFile: !ignoreFileSet,
@@ -615,10 +697,36 @@ func NewPipelineOptions(c util.Settings, e *util.Environment) (*PipelineOptions,
 		// Deprecated
 		PublishPorts:  publishPorts,
 		ExposePorts:   exposePorts,
+		ServiceLogs:   serviceLogs,
 		EnableVolumes: enableVolumes,
 		WerckerYml:    werckerYml,
 		Checkpoint:    checkpoint,
 
+		FrozenLockfile: frozenLockfile,
+
+		MatrixParallel: matrixParallel,
+
+		SnapshotSteps:  snapshotSteps || resumeFrom != "",
+		ResumeFrom:     resumeFrom,
+		SnapshotMaxAge: snapshotMaxAge,
+
+		NoStepCache: noStepCache,
+
+		Plan:       plan,
+		PlanFormat: planFormat,
+
+		Record: record,
+
+		Output:     output,
+		OutputFile: outputFile,
+
+		Webhooks:        webhooks,
+		WebhookSecret:   webhookSecret,
+		WebhookTemplate: webhookTemplate,
+
+		Profile:        profile,
+		ProfileSummary: profileSummary || profile != "",
+
 		DefaultsUsed: defaultsUsed,
 	}, nil
 }
@@ -633,6 +741,23 @@ func (o *PipelineOptions) WorkingPath(s ...string) string {
 	return path.Join(o.WorkingDir, path.Join(s...))
 }
 
+// RunPath adds the matrix variant and workflow node to the file name in
+// path, so the runs of a matrix or workflow each get their own file
+func (o *PipelineOptions) RunPath(path string) string {
+	suffix := ""
+	if o.MatrixIndex > 0 {
+		suffix = fmt.Sprintf("-matrix-%d", o.MatrixIndex)
+	}
+	if o.WorkflowNode != "" {
+		suffix = fmt.Sprintf("%s-%s", suffix, o.WorkflowNode)
+	}
+	if suffix == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
+
 // GuestPath returns a path relative to the build root on the guest.
 func (o *PipelineOptions) GuestPath(s ...string) string {
 	return path.Join(o.GuestRoot, path.Join(s...))
@@ -674,8 +799,12 @@ func (o *PipelineOptions) BuildPath(s ...string) string {
 	return path.Join(o.WorkingDir, "builds", path.Join(s...))
 }
 
-// CachePath returns the path for storing pipeline cache
+// CachePath returns the path for storing pipeline cache, matrix variants
+// each get their own so they can run side by side
 func (o *PipelineOptions) CachePath() string {
+	if o.MatrixIndex > 0 {
+		return path.Join(o.WorkingDir, fmt.Sprintf("cache-matrix-%d", o.MatrixIndex))
+	}
 	return path.Join(o.WorkingDir, "cache")
 }
 
@@ -875,6 +1004,36 @@ func NewPullOptions(c util.Settings, e *util.Environment) (*PullOptions, error)
 	}, nil
 }
 
+// ReplayOptions for the replay command
+type ReplayOptions struct {
+	*GlobalOptions
+
+	Path  string
+	Speed float64
+	Step  string
+}
+
+// NewReplayOptions constructor
+func NewReplayOptions(c util.Settings, e *util.Environment) (*ReplayOptions, error) {
+	globalOpts, err := NewGlobalOptions(c, e)
+	if err != nil {
+		return nil, err
+	}
+
+	speed, _ := c.Float64("speed")
+	if speed <= 0 {
+		return nil, fmt.Errorf("Speed should be positive, got %v", speed)
+	}
+	step, _ := c.String("step")
+
+	return &ReplayOptions{
+		GlobalOptions: globalOpts,
+
+		Speed: speed,
+		Step:  step,
+	}, nil
+}
+
 // VersionOptions contains the options associated with the version
 // command.
 type VersionOptions struct {
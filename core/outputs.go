@@ -0,0 +1,229 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// StepOutputsFile is the file in the report dir of a step that the step
+// writes its outputs to, one key=value per line. Later steps refer to them
+// in their data as ${{ steps.<name>.outputs.<key> }}, so a step with
+// outputs needs a name: of its own when another step has the same name.
+// In the code of a script the value is single-quoted for the shell, so the
+// reference is a word of its own and not inside other quotes.
+const StepOutputsFile = "outputs.txt"
+
+// stepOutputsScriptKey is the data of a script step, the shell runs it
+const stepOutputsScriptKey = "code"
+
+var (
+	stepOutputKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	stepOutputRef = regexp.MustCompile(`\$\{\{\s*steps\.([^{}]+?)\.outputs\.([A-Za-z0-9_-]+)\s*\}\}`)
+)
+
+// ParseStepOutputs reads the key=value lines of an outputs file, empty
+// lines and lines starting with # are skipped
+func ParseStepOutputs(r io.Reader) (map[string]string, error) {
+	outputs := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !stepOutputKey.MatchString(key) {
+			return nil, fmt.Errorf("Invalid output on line %d, expected key=value: %s", n, line)
+		}
+		outputs[key] = strings.TrimSpace(parts[1])
+	}
+	return outputs, scanner.Err()
+}
+
+// StepOutputs are the outputs of the steps that ran so far, by step name.
+// The steps of a parallel group add theirs at the same time.
+type StepOutputs struct {
+	sync.Mutex
+	steps map[string]map[string]string
+	// duplicates are the names more than one step set outputs for
+	duplicates map[string]bool
+}
+
+// NewStepOutputs constructor
+func NewStepOutputs() *StepOutputs {
+	return &StepOutputs{
+		steps:      map[string]map[string]string{},
+		duplicates: map[string]bool{},
+	}
+}
+
+// Set the outputs of step. When an earlier step with the same name has
+// outputs too references to the name are an error, they would be ambiguous.
+func (o *StepOutputs) Set(step string, outputs map[string]string) {
+	o.Lock()
+	defer o.Unlock()
+	if _, ok := o.steps[step]; ok {
+		o.duplicates[step] = true
+	}
+	o.steps[step] = outputs
+}
+
+// Interpolate replaces the references to step outputs in s, referring to a
+// step that didn't run or an output it doesn't have is an error
+func (o *StepOutputs) Interpolate(s string) (string, error) {
+	return o.interpolate(s, nil)
+}
+
+// InterpolateShell is Interpolate for a shell script, the values are
+// single-quoted so quotes, $, ; or newlines in them stay part of the value
+func (o *StepOutputs) InterpolateShell(s string) (string, error) {
+	return o.interpolate(s, shellQuote)
+}
+
+func (o *StepOutputs) interpolate(s string, quote func(string) string) (string, error) {
+	if !strings.Contains(s, "${{") {
+		return s, nil
+	}
+	if o == nil {
+		o = NewStepOutputs()
+	}
+	o.Lock()
+	defer o.Unlock()
+
+	var err error
+	result := stepOutputRef.ReplaceAllStringFunc(s, func(ref string) string {
+		match := stepOutputRef.FindStringSubmatch(ref)
+		name, key := strings.TrimSpace(match[1]), match[2]
+		if o.duplicates[name] {
+			if err == nil {
+				err = fmt.Errorf("More than one step named %s has outputs, give the step a name: to refer to its outputs", name)
+			}
+			return ref
+		}
+		outputs, ok := o.steps[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("No outputs from a step named %s, it needs to run first", name)
+			}
+			return ref
+		}
+		value, ok := outputs[key]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("Step %s has no output %s", name, key)
+			}
+			return ref
+		}
+		if quote != nil {
+			return quote(value)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// shellQuote single-quotes s for sh, a ' in it ends the quotes for an
+// escaped one
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// InterpolateData replaces the references to step outputs in the data of a
+// step and its properties. The strings in lists and maps are replaced one
+// by one and their data encoded again, so the JSON stays valid. The code of
+// a script gets the values quoted, see InterpolateShell. The data and
+// properties are copies, both are nil if nothing refers to an output.
+func (o *StepOutputs) InterpolateData(data map[string]string, properties map[string]interface{}, script bool) (map[string]string, map[string]interface{}, error) {
+	changed := false
+	for _, v := range data {
+		if strings.Contains(v, "${{") {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil, nil, nil
+	}
+
+	newData := make(map[string]string, len(data))
+	newProperties := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		newProperties[k] = v
+	}
+	for k, v := range data {
+		if property, ok := properties[k]; ok && isStructuredProperty(property) {
+			interpolated, err := o.interpolateProperty(property)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %s", k, err)
+			}
+			newProperties[k] = interpolated
+			newData[k] = propertyToString(interpolated)
+			continue
+		}
+		interpolate := o.Interpolate
+		if script && k == stepOutputsScriptKey {
+			interpolate = o.InterpolateShell
+		}
+		value, err := interpolate(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", k, err)
+		}
+		newData[k] = value
+		if _, ok := properties[k].(string); ok {
+			newProperties[k] = value
+		}
+	}
+	return newData, newProperties, nil
+}
+
+// interpolateProperty replaces the references to step outputs in the
+// strings of a property, walking its lists and maps
+func (o *StepOutputs) interpolateProperty(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return o.Interpolate(v)
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			interpolated, err := o.interpolateProperty(item)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = interpolated
+		}
+		return l, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			interpolated, err := o.interpolateProperty(item)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = interpolated
+		}
+		return m, nil
+	}
+	return value, nil
+}
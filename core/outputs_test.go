@@ -0,0 +1,158 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type OutputsSuite struct {
+	*util.TestSuite
+}
+
+func TestOutputsSuite(t *testing.T) {
+	suiteTester := &OutputsSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *OutputsSuite) TestParseStepOutputs() {
+	outputs, err := ParseStepOutputs(strings.NewReader(`
+# written by the build step
+version=1.2.3
+image = registry.example.com/app:1.2.3
+url=http://example.com/?a=b
+empty=
+`))
+	s.NoError(err)
+	s.Equal(map[string]string{
+		"version": "1.2.3",
+		"image":   "registry.example.com/app:1.2.3",
+		"url":     "http://example.com/?a=b",
+		"empty":   "",
+	}, outputs)
+
+	_, err = ParseStepOutputs(strings.NewReader("version=1\nnot an output\n"))
+	s.Error(err)
+	s.Contains(err.Error(), "line 2")
+
+	_, err = ParseStepOutputs(strings.NewReader("a key=value\n"))
+	s.Error(err)
+}
+
+func (s *OutputsSuite) TestInterpolate() {
+	outputs := NewStepOutputs()
+	outputs.Set("build", map[string]string{"version": "1.2.3"})
+	outputs.Set("go test ./...", map[string]string{"coverage": "87"})
+
+	value, err := outputs.Interpolate("app:${{ steps.build.outputs.version }}")
+	s.NoError(err)
+	s.Equal("app:1.2.3", value)
+
+	value, err = outputs.Interpolate("${{steps.build.outputs.version}}-${{ steps.go test ./....outputs.coverage }}")
+	s.NoError(err)
+	s.Equal("1.2.3-87", value)
+
+	value, err = outputs.Interpolate("echo ${HOME} ${{ not a reference }}")
+	s.NoError(err)
+	s.Equal("echo ${HOME} ${{ not a reference }}", value)
+
+	_, err = outputs.Interpolate("${{ steps.deploy.outputs.url }}")
+	s.Error(err)
+
+	_, err = outputs.Interpolate("${{ steps.build.outputs.commit }}")
+	s.Error(err)
+
+	var none *StepOutputs
+	_, err = none.Interpolate("${{ steps.build.outputs.version }}")
+	s.Error(err)
+}
+
+func (s *OutputsSuite) TestInterpolateData() {
+	outputs := NewStepOutputs()
+	outputs.Set("build", map[string]string{"version": "1.2.3", "quoted": `say "hi"`})
+
+	data, properties, err := outputs.InterpolateData(map[string]string{"code": "make"}, nil, true)
+	s.NoError(err)
+	s.Nil(data)
+	s.Nil(properties)
+
+	data, properties, err = outputs.InterpolateData(map[string]string{
+		"tag":   "${{ steps.build.outputs.version }}",
+		"files": `["a","${{ steps.build.outputs.quoted }}"]`,
+		"args":  `{"VERSION":"${{ steps.build.outputs.version }}"}`,
+	}, map[string]interface{}{
+		"tag":   "${{ steps.build.outputs.version }}",
+		"files": []interface{}{"a", "${{ steps.build.outputs.quoted }}"},
+		"args":  map[string]interface{}{"VERSION": "${{ steps.build.outputs.version }}"},
+	}, false)
+	s.NoError(err)
+	s.Equal(map[string]string{
+		"tag":   "1.2.3",
+		"files": `["a","say \"hi\""]`,
+		"args":  `{"VERSION":"1.2.3"}`,
+	}, data)
+	s.Equal(map[string]interface{}{
+		"tag":   "1.2.3",
+		"files": []interface{}{"a", `say "hi"`},
+		"args":  map[string]interface{}{"VERSION": "1.2.3"},
+	}, properties)
+
+	_, _, err = outputs.InterpolateData(map[string]string{"tag": "${{ steps.build.outputs.commit }}"}, nil, false)
+	s.Error(err)
+	s.Contains(err.Error(), "tag:")
+}
+
+func (s *OutputsSuite) TestInterpolateShell() {
+	outputs := NewStepOutputs()
+	outputs.Set("build", map[string]string{
+		"version": "1.2.3",
+		"message": "it's $HOME; rm -rf /\n\"done\"",
+	})
+
+	value, err := outputs.InterpolateShell("deploy --version ${{ steps.build.outputs.version }} --message ${{ steps.build.outputs.message }}")
+	s.NoError(err)
+	s.Equal(`deploy --version '1.2.3' --message 'it'\''s $HOME; rm -rf /`+"\n"+`"done"'`, value)
+
+	// Only the code of a script is quoted
+	data, _, err := outputs.InterpolateData(map[string]string{
+		"code": "echo ${{ steps.build.outputs.version }}",
+		"name": "release ${{ steps.build.outputs.version }}",
+	}, nil, true)
+	s.NoError(err)
+	s.Equal("echo '1.2.3'", data["code"])
+	s.Equal("release 1.2.3", data["name"])
+
+	data, _, err = outputs.InterpolateData(map[string]string{"code": "echo ${{ steps.build.outputs.version }}"}, nil, false)
+	s.NoError(err)
+	s.Equal("echo 1.2.3", data["code"])
+}
+
+func (s *OutputsSuite) TestDuplicateNames() {
+	outputs := NewStepOutputs()
+	outputs.Set("script", map[string]string{"version": "1"})
+
+	value, err := outputs.Interpolate("${{ steps.script.outputs.version }}")
+	s.NoError(err)
+	s.Equal("1", value)
+
+	outputs.Set("script", map[string]string{"version": "2"})
+	_, err = outputs.Interpolate("${{ steps.script.outputs.version }}")
+	s.Error(err)
+	s.Contains(err.Error(), "name:")
+}
@@ -0,0 +1,119 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pborman/uuid"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+// ParallelStepID is the step name for a group of parallel steps
+const ParallelStepID = "parallel"
+
+// ParallelStep is a group of steps that run at the same time, each in its
+// own container. The runner takes care of running them, the group itself is
+// never executed and isn't reported as a step, its steps are.
+type ParallelStep struct {
+	*BaseStep
+	steps []Step
+}
+
+// NewParallelStep groups steps
+func NewParallelStep(stepConfig *StepConfig, steps []Step) *ParallelStep {
+	return &ParallelStep{
+		BaseStep: &BaseStep{
+			displayName: ParallelStepID,
+			env:         util.NewEnvironment(),
+			id:          ParallelStepID,
+			name:        ParallelStepID,
+			owner:       "wercker",
+			safeID:      fmt.Sprintf("%s-%s", ParallelStepID, uuid.NewRandom().String()),
+			version:     util.Version(),
+			cwd:         stepConfig.Cwd,
+		},
+		steps: steps,
+	}
+}
+
+// Steps in the group
+func (s *ParallelStep) Steps() []Step {
+	return s.steps
+}
+
+// Fetch all the steps in the group
+func (s *ParallelStep) Fetch() (string, error) {
+	for _, step := range s.steps {
+		if _, err := step.Fetch(); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// InitEnv noop, the steps in the group get their own
+func (s *ParallelStep) InitEnv(env *util.Environment) error {
+	return nil
+}
+
+// Execute is not supported, the runner runs the steps of the group
+func (s *ParallelStep) Execute(sessionCtx context.Context, sess *Session) (int, error) {
+	return 1, errors.New("Parallel steps can't be executed in a single session")
+}
+
+// CollectFile noop
+func (s *ParallelStep) CollectFile(containerID, path, name string, dst io.Writer) error {
+	return util.ErrEmptyTarball
+}
+
+// CollectArtifact noop
+func (s *ParallelStep) CollectArtifact(ctx context.Context, containerID string) (*Artifact, error) {
+	return nil, nil
+}
+
+// ReportPath noop
+func (s *ParallelStep) ReportPath(p ...string) string {
+	return ""
+}
+
+// ShouldSyncEnv before this step, default FALSE
+func (s *ParallelStep) ShouldSyncEnv() bool {
+	return false
+}
+
+// Clean all the steps in the group
+func (s *ParallelStep) Clean() {
+	for _, step := range s.steps {
+		step.Clean()
+	}
+}
+
+// FlattenSteps replaces parallel groups with their steps, this is the list
+// of steps that gets reported.
+func FlattenSteps(steps []Step) []Step {
+	flat := []Step{}
+	for _, step := range steps {
+		if group, ok := step.(*ParallelStep); ok {
+			flat = append(flat, group.Steps()...)
+			continue
+		}
+		flat = append(flat, step)
+	}
+	return flat
+}
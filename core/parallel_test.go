@@ -0,0 +1,96 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type ParallelSuite struct {
+	*util.TestSuite
+}
+
+func TestParallelSuite(t *testing.T) {
+	suiteTester := &ParallelSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func testStep(name string) *ExternalStep {
+	return &ExternalStep{BaseStep: NewBaseStep(BaseStepOptions{
+		DisplayName: name,
+		Name:        name,
+		SafeID:      name,
+	})}
+}
+
+func (s *ParallelSuite) TestFlattenSteps() {
+	lint := testStep("lint")
+	test := testStep("test")
+	build := testStep("build")
+	group := NewParallelStep(&StepConfig{ID: ParallelStepID}, []Step{lint, test})
+
+	s.Equal([]Step{build, lint, test}, FlattenSteps([]Step{build, group}))
+	s.Equal([]Step{}, FlattenSteps(nil))
+}
+
+func (s *ParallelSuite) TestForkPrefixesLogs() {
+	e := NewNormalizedEmitter()
+	logs := []*LogsArgs{}
+	e.AddListener(Logs, func(args *LogsArgs) {
+		logs = append(logs, args)
+	})
+	var finished *BuildStepFinishedArgs
+	e.AddListener(BuildStepFinished, func(args *BuildStepFinishedArgs) {
+		finished = args
+	})
+
+	step := testStep("lint")
+	fork := e.Fork("[lint] ")
+	fork.Emit(BuildStepStarted, &BuildStepStartedArgs{Step: step, Order: 4})
+	fork.Emit(Logs, &LogsArgs{Logs: "one\ntw"})
+	fork.Emit(Logs, &LogsArgs{Logs: "o\n"})
+	fork.Emit(Logs, &LogsArgs{Logs: "oops\n", Stream: "stderr"})
+	fork.Emit(Logs, &LogsArgs{Logs: "export SECRET=1", Hidden: true})
+	fork.Emit(Logs, &LogsArgs{Logs: "three"})
+	fork.Emit(BuildStepFinished, &BuildStepFinishedArgs{Successful: true})
+
+	actual := []string{}
+	for _, args := range logs {
+		s.Equal(step, args.Step)
+		s.Equal(4, args.Order)
+		actual = append(actual, args.Logs)
+	}
+	s.Equal([]string{
+		"[lint] one\n",
+		"[lint] two\n",
+		"[lint] oops\n",
+		"export SECRET=1",
+		"[lint] three\n",
+	}, actual)
+
+	s.Require().NotNil(finished)
+	s.Equal(step, finished.Step)
+	s.Equal(4, finished.Order)
+
+	// The emitter that was forked doesn't know about the step
+	logs = nil
+	e.Emit(Logs, &LogsArgs{Logs: "main\n"})
+	s.Require().Len(logs, 1)
+	s.Nil(logs[0].Step)
+	s.Equal("main\n", logs[0].Logs)
+}
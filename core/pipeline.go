@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/wercker/wercker/util"
 
@@ -63,6 +64,7 @@ type Pipeline interface {
 	SetupGuest(context.Context, *Session) error
 	ExportEnvironment(context.Context, *Session) error
 	SyncEnvironment(context.Context, *Session) error
+	ReadEnvironment(context.Context, *Session) ([][]string, error)
 
 	LogEnvironment()
 	DockerRepo() string
@@ -70,6 +72,10 @@ type Pipeline interface {
 	DockerMessage() string
 	//Docker() - returns true if the build requires a Remote Docker Daemon
 	Docker() bool
+	// Timeout is how long the steps may take altogether, zero for no limit
+	Timeout() time.Duration
+	// Transport to send the commands to the box with, see ExecTransport
+	Transport() string
 }
 
 // PipelineResult keeps track of the results of a build or deploy
@@ -78,22 +84,30 @@ type PipelineResult struct {
 	Success           bool
 	FailedStepName    string
 	FailedStepMessage string
+	// TimedOut is set if the failed step hit its timeout or the pipeline's
+	TimedOut bool
 }
 
-// ExportEnvironment for this pipeline result (used in after-steps)
-func (pr *PipelineResult) ExportEnvironment(sessionCtx context.Context, sess *Session) error {
+// Env returns the environment for this pipeline result
+func (pr *PipelineResult) Env() *util.Environment {
 	e := util.NewEnvironment()
 	result := "failed"
 	if pr.Success {
 		result = "passed"
+	} else if pr.TimedOut {
+		result = "timeout"
 	}
 	e.Add("WERCKER_RESULT", result)
 	if !pr.Success {
 		e.Add("WERCKER_FAILED_STEP_DISPLAY_NAME", pr.FailedStepName)
 		e.Add("WERCKER_FAILED_STEP_MESSAGE", pr.FailedStepMessage)
 	}
+	return e
+}
 
-	exit, _, err := sess.SendChecked(sessionCtx, e.Export()...)
+// ExportEnvironment for this pipeline result (used in after-steps)
+func (pr *PipelineResult) ExportEnvironment(sessionCtx context.Context, sess *Session) error {
+	exit, _, err := sess.SendChecked(sessionCtx, pr.Env().Export()...)
 	if err != nil {
 		return err
 	}
@@ -168,6 +182,11 @@ func (p *BasePipeline) Env() *util.Environment {
 
 // CommonEnv is shared by both builds and deploys
 func (p *BasePipeline) CommonEnv() [][]string {
+	// The dirs in the pipeline dir on the host are mounted read-only
+	serviceLogsDir := p.options.MntPath(ServiceLogsDir)
+	if p.options.DirectMount {
+		serviceLogsDir = p.options.GuestPath(ServiceLogsDir)
+	}
 	a := [][]string{
 		[]string{"WERCKER", "true"},
 		[]string{"WERCKER_ROOT", p.options.GuestPath("source")},
@@ -177,6 +196,7 @@ func (p *BasePipeline) CommonEnv() [][]string {
 		[]string{"WERCKER_OUTPUT_DIR", p.options.GuestPath("output")},
 		[]string{"WERCKER_PIPELINE_DIR", p.options.GuestPath()},
 		[]string{"WERCKER_REPORT_DIR", p.options.GuestPath("report")},
+		[]string{"WERCKER_SERVICE_LOGS_DIR", serviceLogsDir},
 		[]string{"WERCKER_APPLICATION_ID", p.options.ApplicationID},
 		[]string{"WERCKER_APPLICATION_NAME", p.options.ApplicationName},
 		[]string{"WERCKER_APPLICATION_OWNER_NAME", p.options.ApplicationOwnerName},
@@ -197,7 +217,8 @@ func (p *BasePipeline) SetupGuest(sessionCtx context.Context, sess *Session) err
 
 	cmds := []string{}
 
-	if !p.options.DirectMount {
+	// A snapshot already has the source and cache as the steps left them
+	if !p.options.DirectMount && p.options.Resume == nil {
 		cmds = append(cmds,
 			// Make sure our guest path exists
 			fmt.Sprintf(`mkdir -p "%s"`, p.options.GuestPath()),
@@ -268,6 +289,18 @@ func (p *BasePipeline) LogEnvironment() {
 func (p *BasePipeline) SyncEnvironment(sessionCtx context.Context, sess *Session) error {
 	p.logger.Debugln("Syncing environment")
 
+	env, err := p.ReadEnvironment(sessionCtx, sess)
+	if err != nil {
+		return err
+	}
+	p.env.Update(env)
+
+	return nil
+}
+
+// ReadEnvironment fetches the current environment from sess. This requires
+// the `env` command to be available on the container.
+func (p *BasePipeline) ReadEnvironment(sessionCtx context.Context, sess *Session) ([][]string, error) {
 	sess.HideLogs()
 	defer sess.ShowLogs()
 
@@ -275,17 +308,18 @@ func (p *BasePipeline) SyncEnvironment(sessionCtx context.Context, sess *Session
 	// inside the values.
 	exit, output, err := sess.SendChecked(sessionCtx, "set +e", "env --null", "set -e")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if exit != 0 {
-		return fmt.Errorf("Unable to sync environment, exit code: %d", exit)
+		return nil, fmt.Errorf("Unable to sync environment, exit code: %d", exit)
 	}
 
 	// Concat every output line into a single string, then split on the null byte
 	full := strings.Join(output, "")
 	lines := strings.Split(full, "\x00")
 
+	env := [][]string{}
 	for _, line := range lines {
 		if line == "" {
 			continue
@@ -298,16 +332,23 @@ func (p *BasePipeline) SyncEnvironment(sessionCtx context.Context, sess *Session
 			continue
 		}
 
-		key := s[0]
-		value := s[1]
-
-		p.env.Add(key, value)
+		env = append(env, s)
 	}
 
-	return nil
+	return env, nil
 }
 
 //Docker - returns true if the build requires a Remote Docker Daemon
 func (p *BasePipeline) Docker() bool {
 	return p.config.Docker
 }
+
+// Timeout of the steps from the pipeline config
+func (p *BasePipeline) Timeout() time.Duration {
+	return p.config.Timeout
+}
+
+// Transport from the pipeline config
+func (p *BasePipeline) Transport() string {
+	return p.config.Transport
+}
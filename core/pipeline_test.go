@@ -91,3 +91,19 @@ func (s *PipelineSuite) TestIsBuildIDInvalid3() {
 
 	s.Equal(false, ok)
 }
+
+func (s *PipelineSuite) TestPipelineResultEnv() {
+	pr := &PipelineResult{Success: true}
+	s.Equal([][]string{{"WERCKER_RESULT", "passed"}}, pr.Env().Ordered())
+
+	pr = &PipelineResult{
+		FailedStepName:    "integration",
+		FailedStepMessage: "Step timed out after 1h0m0s",
+		TimedOut:          true,
+	}
+	s.Equal([][]string{
+		{"WERCKER_RESULT", "timeout"},
+		{"WERCKER_FAILED_STEP_DISPLAY_NAME", "integration"},
+		{"WERCKER_FAILED_STEP_MESSAGE", "Step timed out after 1h0m0s"},
+	}, pr.Env().Ordered())
+}
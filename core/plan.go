@@ -0,0 +1,294 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/wercker/wercker/util"
+)
+
+// HiddenValue replaces the values of hidden env vars in a plan
+const HiddenValue = "<hidden>"
+
+// Plan formats
+const (
+	PlanFormatText = "text"
+	PlanFormatJSON = "json"
+)
+
+// Plan is a pipeline resolved as far as we can without starting any
+// containers, see --plan
+type Plan struct {
+	Pipeline     string      `json:"pipeline"`
+	DeployTarget string      `json:"deployTarget,omitempty"`
+	StepsSection string      `json:"stepsSection"`
+	Box          *PlanBox    `json:"box"`
+	Services     []*PlanBox  `json:"services"`
+	Env          [][]string  `json:"env"`
+	Steps        []*PlanStep `json:"steps"`
+	AfterSteps   []*PlanStep `json:"afterSteps"`
+}
+
+// PlanBox is the box or a service
+type PlanBox struct {
+	Image string     `json:"image"`
+	Tag   string     `json:"tag,omitempty"`
+	Alias string     `json:"alias,omitempty"`
+	Env   [][]string `json:"env,omitempty"`
+}
+
+// PlanStep is a step with the version it resolves to, its data interpolated
+// with the pipeline environment and the environment it would run with
+type PlanStep struct {
+	Name     string            `json:"name"`
+	ID       string            `json:"id"`
+	Version  string            `json:"version,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	When     string            `json:"when,omitempty"`
+	Cwd      string            `json:"cwd,omitempty"`
+	Data     map[string]string `json:"data,omitempty"`
+	Env      [][]string        `json:"env,omitempty"`
+	Parallel []*PlanStep       `json:"parallel,omitempty"`
+}
+
+// resolvableStep is a step we can look up without fetching it
+type resolvableStep interface {
+	Resolve() (string, string, error)
+	Data() map[string]string
+}
+
+// configuredBox is a box that knows its config from the wercker.yml
+type configuredBox interface {
+	GetConfig() *BoxConfig
+}
+
+// NewPlan from a pipeline that had InitEnv called on it, stepsSection is
+// the section of the pipeline config the steps come from.
+func NewPlan(pipeline Pipeline, options *PipelineOptions, stepsSection string) (*Plan, error) {
+	env := pipeline.Env()
+	box := pipeline.Box()
+	plan := &Plan{
+		Pipeline:     options.Pipeline,
+		DeployTarget: options.DeployTarget,
+		StepsSection: stepsSection,
+		Box: &PlanBox{
+			Image: box.GetName(),
+			Tag:   box.GetTag(),
+		},
+		Services: []*PlanBox{},
+		Env:      planEnv(env),
+	}
+
+	for _, service := range pipeline.Services() {
+		planService := &PlanBox{
+			Image: service.GetName(),
+			Alias: service.GetServiceAlias(),
+		}
+		if configured, ok := service.(configuredBox); ok && configured.GetConfig() != nil {
+			keys := []string{}
+			for k := range configured.GetConfig().Env {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				value := maskHidden(env.Interpolate(configured.GetConfig().Env[k]), env)
+				planService.Env = append(planService.Env, []string{strings.ToUpper(k), value})
+			}
+		}
+		plan.Services = append(plan.Services, planService)
+	}
+
+	var err error
+	plan.Steps, err = planSteps(pipeline.Steps(), env)
+	if err != nil {
+		return nil, err
+	}
+	plan.AfterSteps, err = planSteps(pipeline.AfterSteps(), env)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func planSteps(steps []Step, env *util.Environment) ([]*PlanStep, error) {
+	planned := []*PlanStep{}
+	for _, step := range steps {
+		planStep := &PlanStep{
+			Name:    step.DisplayName(),
+			ID:      step.ID(),
+			Version: step.Version(),
+			When:    step.When(),
+			Cwd:     step.Cwd(),
+		}
+
+		if group, ok := step.(*ParallelStep); ok {
+			parallel, err := planSteps(group.Steps(), env)
+			if err != nil {
+				return nil, err
+			}
+			planStep.Parallel = parallel
+			planned = append(planned, planStep)
+			continue
+		}
+
+		// Only external steps are resolved, the internal ones talk to docker
+		// or registries to set themselves up
+		stepEnv := util.NewEnvironment()
+		stepEnv.Update(planEnv(env))
+		if resolvable, ok := step.(resolvableStep); ok {
+			version, url, err := resolvable.Resolve()
+			if err != nil {
+				return nil, err
+			}
+			planStep.Version = version
+			planStep.URL = url
+
+			planStep.Data = map[string]string{}
+			for k, v := range resolvable.Data() {
+				planStep.Data[k] = maskHidden(env.Interpolate(v), env)
+			}
+
+			if err := step.InitEnv(env); err != nil {
+				return nil, err
+			}
+			for _, pair := range step.Env().Ordered() {
+				stepEnv.Add(pair[0], maskHidden(pair[1], env))
+			}
+		}
+		planStep.Env = stepEnv.Ordered()
+		planned = append(planned, planStep)
+	}
+	return planned, nil
+}
+
+// planEnv is env with the values of the hidden env vars masked
+func planEnv(env *util.Environment) [][]string {
+	planned := util.NewEnvironment()
+	planned.Update(env.Ordered())
+	if env.Hidden != nil {
+		for _, key := range env.Hidden.Order {
+			planned.Add(key, HiddenValue)
+		}
+	}
+	return planned.Ordered()
+}
+
+// maskHidden replaces the values of hidden env vars in s
+func maskHidden(s string, env *util.Environment) string {
+	if env.Hidden == nil {
+		return s
+	}
+	for _, value := range env.Hidden.Map {
+		if value != "" {
+			s = strings.Replace(s, value, HiddenValue, -1)
+		}
+	}
+	return s
+}
+
+// Write the plan in format
+func (p *Plan) Write(w io.Writer, format string) error {
+	switch format {
+	case PlanFormatJSON:
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case PlanFormatText, "":
+		_, err := io.WriteString(w, p.String())
+		return err
+	}
+	return fmt.Errorf("Unknown plan format %q, expected %q or %q", format, PlanFormatText, PlanFormatJSON)
+}
+
+// String renders the plan as text
+func (p *Plan) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "pipeline: %s\n", p.Pipeline)
+	if p.DeployTarget != "" {
+		fmt.Fprintf(&b, "deploy target: %s\n", p.DeployTarget)
+	}
+	fmt.Fprintf(&b, "steps section: %s\n", p.StepsSection)
+	fmt.Fprintf(&b, "box: %s\n", p.Box.Image)
+	if len(p.Services) > 0 {
+		fmt.Fprintf(&b, "services:\n")
+		for _, service := range p.Services {
+			fmt.Fprintf(&b, "  - %s (%s)\n", service.Image, service.Alias)
+			writePlanEnv(&b, "      ", service.Env)
+		}
+	}
+	fmt.Fprintf(&b, "env:\n")
+	writePlanEnv(&b, "  ", p.Env)
+	fmt.Fprintf(&b, "steps:\n")
+	writePlanSteps(&b, "  ", p.Steps)
+	if len(p.AfterSteps) > 0 {
+		fmt.Fprintf(&b, "after-steps:\n")
+		writePlanSteps(&b, "  ", p.AfterSteps)
+	}
+	return b.String()
+}
+
+func writePlanSteps(b *bytes.Buffer, indent string, steps []*PlanStep) {
+	for _, step := range steps {
+		if step.Parallel != nil {
+			fmt.Fprintf(b, "%s- parallel:\n", indent)
+			writePlanSteps(b, indent+"    ", step.Parallel)
+			continue
+		}
+		fmt.Fprintf(b, "%s- %s (%s", indent, step.Name, step.ID)
+		if step.Version != "" {
+			fmt.Fprintf(b, "@%s", step.Version)
+		}
+		fmt.Fprintf(b, ")\n")
+		if step.URL != "" {
+			fmt.Fprintf(b, "%s    url: %s\n", indent, step.URL)
+		}
+		if step.When != "" {
+			fmt.Fprintf(b, "%s    when: %s\n", indent, step.When)
+		}
+		if step.Cwd != "" {
+			fmt.Fprintf(b, "%s    cwd: %s\n", indent, step.Cwd)
+		}
+		if len(step.Data) > 0 {
+			fmt.Fprintf(b, "%s    data:\n", indent)
+			keys := []string{}
+			for k := range step.Data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(b, "%s      %s: %s\n", indent, k, step.Data[k])
+			}
+		}
+		if len(step.Env) > 0 {
+			fmt.Fprintf(b, "%s    env:\n", indent)
+			writePlanEnv(b, indent+"      ", step.Env)
+		}
+	}
+}
+
+func writePlanEnv(b *bytes.Buffer, indent string, env [][]string) {
+	for _, pair := range env {
+		fmt.Fprintf(b, "%s%s=%s\n", indent, pair[0], pair[1])
+	}
+}
@@ -0,0 +1,126 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type PlanSuite struct {
+	*util.TestSuite
+}
+
+func TestPlanSuite(t *testing.T) {
+	suiteTester := &PlanSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func testPlanEnv() *util.Environment {
+	env := util.NewEnvironment("WERCKER_GIT_BRANCH=master", "TARGET=staging")
+	env.Hidden.Add("TOKEN", "s3cret")
+	return env
+}
+
+func (s *PlanSuite) TestPlanEnvMasksHidden() {
+	s.Equal([][]string{
+		{"WERCKER_GIT_BRANCH", "master"},
+		{"TARGET", "staging"},
+		{"TOKEN", HiddenValue},
+	}, planEnv(testPlanEnv()))
+	s.Equal("curl -H token:"+HiddenValue, maskHidden("curl -H token:s3cret", testPlanEnv()))
+}
+
+func (s *PlanSuite) TestPlanSteps() {
+	options := &PipelineOptions{GlobalOptions: &GlobalOptions{}}
+	script, err := NewStep(&StepConfig{
+		ID:   "script",
+		Name: "deploy",
+		Data: map[string]string{"code": "deploy --to $TARGET --token $TOKEN"},
+	}, options)
+	s.Require().NoError(err)
+	lint := testStep("lint")
+	group := NewParallelStep(&StepConfig{ID: ParallelStepID}, []Step{lint})
+
+	steps, err := planSteps([]Step{script, group}, testPlanEnv())
+	s.Require().NoError(err)
+	s.Require().Len(steps, 2)
+
+	s.Equal("deploy", steps[0].Name)
+	s.Equal("script", steps[0].ID)
+	s.Equal("", steps[0].URL)
+	s.Equal(map[string]string{"code": "deploy --to staging --token " + HiddenValue}, steps[0].Data)
+	s.Contains(steps[0].Env, []string{"TOKEN", HiddenValue})
+	s.Contains(steps[0].Env, []string{"WERCKER_STEP_NAME", "script"})
+
+	s.Require().Len(steps[1].Parallel, 1)
+	s.Equal("lint", steps[1].Parallel[0].Name)
+}
+
+func (s *PlanSuite) TestWrite() {
+	plan := &Plan{
+		Pipeline:     "deploy",
+		DeployTarget: "production",
+		StepsSection: "production",
+		Box:          &PlanBox{Image: "golang:1.9", Tag: "1.9"},
+		Services:     []*PlanBox{{Image: "mongo:latest", Alias: "mongo", Env: [][]string{{"MONGO_PORT", "27017"}}}},
+		Env:          [][]string{{"TOKEN", HiddenValue}},
+		Steps:        []*PlanStep{{Name: "deploy", ID: "script", Data: map[string]string{"code": "deploy"}}},
+	}
+
+	var text bytes.Buffer
+	s.Require().NoError(plan.Write(&text, PlanFormatText))
+	s.Equal(`pipeline: deploy
+deploy target: production
+steps section: production
+box: golang:1.9
+services:
+  - mongo:latest (mongo)
+      MONGO_PORT=27017
+env:
+  TOKEN=<hidden>
+steps:
+  - deploy (script)
+      data:
+        code: deploy
+`, text.String())
+
+	var b bytes.Buffer
+	s.Require().NoError(plan.Write(&b, PlanFormatJSON))
+	decoded := &Plan{}
+	s.Require().NoError(json.Unmarshal(b.Bytes(), decoded))
+	s.Equal(plan.StepsSection, decoded.StepsSection)
+	s.Equal(plan.Steps, decoded.Steps)
+
+	s.Error(plan.Write(&b, "yaml"))
+}
+
+func (s *PlanSuite) TestStepsFor() {
+	config := &PipelineConfig{
+		Steps:    RawStepsConfig{{StepConfig: &StepConfig{ID: "script"}}},
+		StepsMap: map[string][]*RawStepConfig{"production": {{StepConfig: &StepConfig{ID: "deploy"}}}},
+	}
+	steps, section := config.StepsFor("production")
+	s.Equal("production", section)
+	s.Equal("deploy", steps[0].ID)
+
+	steps, section = config.StepsFor("staging")
+	s.Equal("steps", section)
+	s.Equal("script", steps[0].ID)
+}
@@ -0,0 +1,286 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Profile records how long the phases of a run take, like pulling the box
+// or executing a step. The phases are spans started from the span of the
+// phase they are part of, starting with Root. Profiling is off when the
+// Profile is nil, the spans of a nil Profile are nil and record nothing.
+type Profile struct {
+	sync.Mutex
+	root  *ProfileSpan
+	lanes int
+	now   func() time.Time
+}
+
+// ProfileSpan is a phase of a run, Category is the kind of phase (pull,
+// execute, ...) and Name what it ran on (the image, the step, ...).
+type ProfileSpan struct {
+	Category string
+	Name     string
+	Started  time.Time
+	Finished time.Time
+	Children []*ProfileSpan
+	// Lane is the row a trace viewer draws the span in, a span that runs
+	// alongside one of its siblings gets a lane of its own
+	Lane    int
+	profile *Profile
+}
+
+// NewProfile starts profiling the run called name
+func NewProfile(name string) *Profile {
+	p := &Profile{now: time.Now}
+	p.root = &ProfileSpan{
+		Category: "run",
+		Name:     name,
+		Started:  p.now(),
+		profile:  p,
+	}
+	return p
+}
+
+// Root is the span of the whole run
+func (p *Profile) Root() *ProfileSpan {
+	if p == nil {
+		return nil
+	}
+	return p.root
+}
+
+// End stops profiling, the spans that are still going end with it
+func (p *Profile) End() {
+	p.Root().End()
+}
+
+// Start a span that is part of s
+func (s *ProfileSpan) Start(category, name string) *ProfileSpan {
+	if s == nil {
+		return nil
+	}
+	p := s.profile
+	p.Lock()
+	defer p.Unlock()
+	span := &ProfileSpan{
+		Category: category,
+		Name:     name,
+		Started:  p.now(),
+		Lane:     s.Lane,
+		profile:  p,
+	}
+	for _, sibling := range s.Children {
+		if sibling.Finished.IsZero() {
+			p.lanes++
+			span.Lane = p.lanes
+			break
+		}
+	}
+	s.Children = append(s.Children, span)
+	return span
+}
+
+// End the span, the spans started from it that are still going end with it
+func (s *ProfileSpan) End() {
+	if s == nil {
+		return
+	}
+	s.profile.Lock()
+	defer s.profile.Unlock()
+	s.end(s.profile.now())
+}
+
+func (s *ProfileSpan) end(t time.Time) {
+	if !s.Finished.IsZero() {
+		return
+	}
+	s.Finished = t
+	for _, child := range s.Children {
+		child.end(t)
+	}
+}
+
+// Duration of the span, zero until it ends
+func (s *ProfileSpan) Duration() time.Duration {
+	if s.Finished.IsZero() {
+		return 0
+	}
+	return s.Finished.Sub(s.Started)
+}
+
+// Self is the part of the span none of its children were going
+func (s *ProfileSpan) Self() time.Duration {
+	children := make([]*ProfileSpan, len(s.Children))
+	copy(children, s.Children)
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Started.Before(children[j].Started)
+	})
+	self := s.Duration()
+	covered := s.Started
+	for _, child := range children {
+		start := child.Started
+		if start.Before(covered) {
+			start = covered
+		}
+		if child.Finished.After(start) {
+			self -= child.Finished.Sub(start)
+			covered = child.Finished
+		}
+	}
+	return self
+}
+
+// CriticalPath is the chain of spans that decided when the run finished:
+// the last child of the root to finish, the last one to finish before that
+// one started and so on, each of them replaced by its own critical path.
+func (p *Profile) CriticalPath() []*ProfileSpan {
+	p.Lock()
+	defer p.Unlock()
+	return criticalPath(p.root)
+}
+
+func criticalPath(s *ProfileSpan) []*ProfileSpan {
+	if len(s.Children) == 0 {
+		return []*ProfileSpan{s}
+	}
+	children := make([]*ProfileSpan, len(s.Children))
+	copy(children, s.Children)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Finished.After(children[j].Finished)
+	})
+	path := []*ProfileSpan{}
+	until := s.Finished
+	for _, child := range children {
+		if child.Finished.After(until) {
+			continue
+		}
+		path = append(criticalPath(child), path...)
+		until = child.Started
+	}
+	return path
+}
+
+// spans is every span under s, depth first
+func (s *ProfileSpan) spans() []*ProfileSpan {
+	spans := []*ProfileSpan{}
+	for _, child := range s.Children {
+		spans = append(spans, child)
+		spans = append(spans, child.spans()...)
+	}
+	return spans
+}
+
+// WriteSummary writes a table of the phases that took time, slowest first.
+// The time of a phase leaves out the phases that are part of it, the ones
+// on the critical path are marked with a *.
+func (p *Profile) WriteSummary(w io.Writer) error {
+	p.Lock()
+	defer p.Unlock()
+	total := p.root.Duration()
+	critical := map[*ProfileSpan]bool{}
+	for _, span := range criticalPath(p.root) {
+		critical[span] = true
+	}
+	spans := []*ProfileSpan{}
+	for _, span := range p.root.spans() {
+		if span.Self() > 0 {
+			spans = append(spans, span)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Self() > spans[j].Self()
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  \tPHASE\tNAME\tTIME\tSHARE\n")
+	for _, span := range spans {
+		mark := ""
+		if critical[span] {
+			mark = "*"
+		}
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(span.Self()) / float64(total)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2fs\t%.1f%%\n", mark, span.Category, span.Name, span.Self().Seconds(), share)
+	}
+	return tw.Flush()
+}
+
+// TraceEvent is a complete ("X") event of the Chrome trace event format,
+// times are in microseconds since the run started
+type TraceEvent struct {
+	Name     string `json:"name"`
+	Category string `json:"cat,omitempty"`
+	Phase    string `json:"ph"`
+	Time     int64  `json:"ts"`
+	Duration int64  `json:"dur"`
+	PID      int    `json:"pid"`
+	TID      int    `json:"tid"`
+}
+
+// Trace is the Chrome trace of a run, it opens in chrome://tracing and
+// other trace viewers
+type Trace struct {
+	TraceEvents     []TraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// WriteTrace writes the spans as a Chrome trace
+func (p *Profile) WriteTrace(w io.Writer) error {
+	p.Lock()
+	defer p.Unlock()
+	started := p.root.Started
+	spans := append([]*ProfileSpan{p.root}, p.root.spans()...)
+	trace := &Trace{
+		TraceEvents:     []TraceEvent{},
+		DisplayTimeUnit: "ms",
+	}
+	for _, span := range spans {
+		trace.TraceEvents = append(trace.TraceEvents, TraceEvent{
+			Name:     span.Name,
+			Category: span.Category,
+			Phase:    "X",
+			Time:     int64(span.Started.Sub(started) / time.Microsecond),
+			Duration: int64(span.Duration() / time.Microsecond),
+			PID:      1,
+			TID:      span.Lane,
+		})
+	}
+	return json.NewEncoder(w).Encode(trace)
+}
+
+// WithProfileSpan gives us a new context with span as the phase that is
+// going, the phases started with the context are part of it
+func WithProfileSpan(ctx context.Context, span *ProfileSpan) context.Context {
+	return context.WithValue(ctx, "ProfileSpan", span)
+}
+
+// ProfileSpanFromContext gives us the span attached to the context, nil if
+// the run isn't profiled
+func ProfileSpanFromContext(ctx context.Context) *ProfileSpan {
+	span, _ := ctx.Value("ProfileSpan").(*ProfileSpan)
+	return span
+}
@@ -0,0 +1,158 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+type ProfileSuite struct {
+	*util.TestSuite
+}
+
+func TestProfileSuite(t *testing.T) {
+	suiteTester := &ProfileSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+// testProfile is a build with a parallel group of two steps, the clock is
+// moved to the second the spans start and end at
+func testProfile() *Profile {
+	started := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := started
+	at := func(second int) {
+		now = started.Add(time.Duration(second) * time.Second)
+	}
+	p := NewProfile("build")
+	p.now = func() time.Time { return now }
+	p.root.Started = started
+
+	code := p.Root().Start("code", "get code")
+	at(2)
+	code.End()
+	env := p.Root().Start("environment", "setup environment")
+	at(3)
+	pull := env.Start("pull", "golang:1.10")
+	at(7)
+	pull.End()
+	service := env.Start("service", "postgres")
+	at(9)
+	service.End()
+	at(10)
+	env.End()
+
+	group := p.Root().Start("parallel", "tests")
+	a := group.Start("step", "unit")
+	b := group.Start("step", "integration")
+	executeB := b.Start("execute", "integration")
+	at(11)
+	executeA := a.Start("execute", "unit")
+	at(24)
+	executeA.End()
+	at(25)
+	a.End()
+	at(29)
+	executeB.End()
+	at(30)
+	b.End()
+	group.End()
+
+	store := p.Root().Start("store", "store")
+	at(31)
+	store.End()
+	at(32)
+	p.End()
+	return p
+}
+
+func (s *ProfileSuite) TestNil() {
+	var p *Profile
+	span := p.Root().Start("step", "test")
+	s.Nil(span)
+	span.End()
+	p.End()
+	s.Nil(ProfileSpanFromContext(context.Background()))
+}
+
+func (s *ProfileSuite) TestSpans() {
+	p := testProfile()
+	s.Equal(32*time.Second, p.Root().Duration())
+
+	env := p.Root().Children[1]
+	s.Equal(8*time.Second, env.Duration())
+	s.Equal(2*time.Second, env.Self())
+
+	group := p.Root().Children[2]
+	s.Equal(time.Duration(0), group.Self())
+	s.Equal(0, group.Children[0].Lane)
+	s.Equal(1, group.Children[1].Lane)
+	s.Equal(1, group.Children[1].Children[0].Lane)
+}
+
+func (s *ProfileSuite) TestEndChildren() {
+	p := NewProfile("build")
+	step := p.Root().Start("step", "test")
+	execute := step.Start("execute", "test")
+	p.End()
+	s.False(execute.Finished.IsZero())
+	s.Equal(p.Root().Finished, execute.Finished)
+}
+
+func (s *ProfileSuite) TestCriticalPath() {
+	names := []string{}
+	for _, span := range testProfile().CriticalPath() {
+		names = append(names, span.Category+" "+span.Name)
+	}
+	s.Equal([]string{
+		"code get code",
+		"pull golang:1.10",
+		"service postgres",
+		"execute integration",
+		"store store",
+	}, names)
+}
+
+func (s *ProfileSuite) TestWriteSummary() {
+	var b bytes.Buffer
+	err := testProfile().WriteSummary(&b)
+	s.Require().NoError(err)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	s.Require().Len(lines, 10)
+	s.Equal([]string{"PHASE", "NAME", "TIME", "SHARE"}, strings.Fields(lines[0]))
+	s.Equal([]string{"*", "execute", "integration", "19.00s", "59.4%"}, strings.Fields(lines[1]))
+	s.Equal([]string{"execute", "unit", "13.00s", "40.6%"}, strings.Fields(lines[2]))
+	s.Equal([]string{"*", "pull", "golang:1.10", "4.00s", "12.5%"}, strings.Fields(lines[3]))
+	s.Equal([]string{"*", "store", "store", "1.00s", "3.1%"}, strings.Fields(lines[9]))
+}
+
+func (s *ProfileSuite) TestWriteTrace() {
+	var b bytes.Buffer
+	err := testProfile().WriteTrace(&b)
+	s.Require().NoError(err)
+	trace := &Trace{}
+	s.Require().NoError(json.Unmarshal(b.Bytes(), trace))
+	s.Equal("ms", trace.DisplayTimeUnit)
+	s.Len(trace.TraceEvents, 11)
+	s.Equal(TraceEvent{Name: "build", Category: "run", Phase: "X", Time: 0, Duration: 32000000, PID: 1}, trace.TraceEvents[0])
+	s.Equal(TraceEvent{Name: "integration", Category: "execute", Phase: "X", Time: 10000000, Duration: 19000000, PID: 1, TID: 1}, trace.TraceEvents[9])
+}
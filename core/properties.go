@@ -0,0 +1,196 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wercker/wercker/steps"
+	"gopkg.in/yaml.v2"
+)
+
+// normalizeProperty turns the maps the yaml parser hands us into plain
+// map[string]interface{} so step properties can be encoded as JSON and
+// checked against the declared types.
+func normalizeProperty(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		m := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			m[fmt.Sprintf("%v", item.Key)] = normalizeProperty(item.Value)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprintf("%v", k)] = normalizeProperty(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = normalizeProperty(item)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// propertyToString is the string form of a step property, this is what
+// ends up in StepConfig.Data. Lists and maps are encoded as JSON.
+func propertyToString(value interface{}) string {
+	switch v := value.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	default:
+		return ifaceToString(v)
+	}
+}
+
+// isStructuredProperty tells us whether value is a list or a map
+func isStructuredProperty(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+// PropertyEnvKey returns the environment variable name for a step property,
+// WERCKER_<STEP>_<PROPERTY> with dashes turned into underscores.
+func PropertyEnvKey(stepName, property string) string {
+	key := fmt.Sprintf("WERCKER_%s_%s", stepName, property)
+	key = strings.Replace(key, "-", "_", -1)
+	return strings.ToUpper(key)
+}
+
+// envName makes a map key usable as part of a variable name
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// PropertyEnv encodes a step property as environment variables. Scalars are
+// a single variable, lists and maps get the JSON under the base key plus an
+// entry per item so they are usable from plain shell:
+//
+//   files: [a.txt, b.txt]   WERCKER_STEP_FILES=["a.txt","b.txt"]
+//                           WERCKER_STEP_FILES_COUNT=2
+//                           WERCKER_STEP_FILES_0=a.txt
+//                           WERCKER_STEP_FILES_1=b.txt
+//
+//   args: {GO_VERSION: 1.9} WERCKER_STEP_ARGS={"GO_VERSION":1.9}
+//                           WERCKER_STEP_ARGS_KEYS=GO_VERSION
+//                           WERCKER_STEP_ARGS_GO_VERSION=1.9
+//
+// Nested lists and maps are only indexed one level deep, their items are JSON.
+func PropertyEnv(key string, value interface{}) [][]string {
+	env := [][]string{[]string{key, propertyToString(value)}}
+	switch v := value.(type) {
+	case []interface{}:
+		env = append(env, []string{key + "_COUNT", strconv.Itoa(len(v))})
+		for i, item := range v {
+			env = append(env, []string{fmt.Sprintf("%s_%d", key, i), propertyToString(item)})
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		env = append(env, []string{key + "_KEYS", strings.Join(names, " ")})
+		for _, name := range names {
+			env = append(env, []string{key + "_" + envName(name), propertyToString(v[name])})
+		}
+	}
+	return env
+}
+
+// ValidateProperties checks the values in properties against the types
+// declared in the step.yml. Properties the step doesn't declare are left
+// alone, as are undeclared or unknown types.
+func (sc *StepDesc) ValidateProperties(properties map[string]interface{}) error {
+	if sc == nil {
+		return nil
+	}
+	var errs []string
+	for _, p := range sc.Properties {
+		value, ok := properties[p.Name]
+		if !ok {
+			continue
+		}
+		if err := steps.CheckPropertyValue(p.Type, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", p.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid properties for step %s: %s", sc.Name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// PropertyList returns a list property as strings, the second return value is
+// false if the property wasn't given as a list so callers can fall back to
+// their own formats (space separated etc) in Data.
+func (c *StepConfig) PropertyList(name string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	if value, ok := c.Properties[name].([]interface{}); ok {
+		l := make([]string, len(value))
+		for i, item := range value {
+			l[i] = propertyToString(item)
+		}
+		return l, true
+	}
+	return nil, false
+}
+
+// PropertyMap returns a map property as strings, see PropertyList.
+func (c *StepConfig) PropertyMap(name string) (map[string]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	if value, ok := c.Properties[name].(map[string]interface{}); ok {
+		m := make(map[string]string, len(value))
+		for k, item := range value {
+			m[k] = propertyToString(item)
+		}
+		return m, true
+	}
+	return nil, false
+}
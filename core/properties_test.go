@@ -0,0 +1,109 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type PropertiesSuite struct {
+	*util.TestSuite
+}
+
+func TestPropertiesSuite(t *testing.T) {
+	suiteTester := &PropertiesSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *PropertiesSuite) TestPropertyToString() {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{"string input", "string input"},
+		{1234, "1234"},
+		{true, "true"},
+		{1.5, "1.5"},
+		{nil, ""},
+		{[]interface{}{"a", 1}, `["a",1]`},
+		{map[string]interface{}{"b": []interface{}{}, "a": "x"}, `{"a":"x","b":[]}`},
+	}
+
+	for _, test := range tests {
+		s.Equal(test.expected, propertyToString(test.input))
+	}
+}
+
+func (s *PropertiesSuite) TestPropertyEnvScalar() {
+	key := PropertyEnvKey("my-step", "dry-run")
+	s.Equal("WERCKER_MY_STEP_DRY_RUN", key)
+	s.Equal([][]string{{key, "true"}}, PropertyEnv(key, true))
+}
+
+func (s *PropertiesSuite) TestPropertyEnvList() {
+	env := PropertyEnv("WERCKER_STEP_FILES", []interface{}{"a.txt", "b.txt"})
+	s.Equal([][]string{
+		{"WERCKER_STEP_FILES", `["a.txt","b.txt"]`},
+		{"WERCKER_STEP_FILES_COUNT", "2"},
+		{"WERCKER_STEP_FILES_0", "a.txt"},
+		{"WERCKER_STEP_FILES_1", "b.txt"},
+	}, env)
+}
+
+func (s *PropertiesSuite) TestPropertyEnvMap() {
+	env := PropertyEnv("WERCKER_STEP_ARGS", map[string]interface{}{
+		"go-version": 1.9,
+		"TAGS":       []interface{}{"netgo"},
+	})
+	s.Equal([][]string{
+		{"WERCKER_STEP_ARGS", `{"TAGS":["netgo"],"go-version":1.9}`},
+		{"WERCKER_STEP_ARGS_KEYS", "TAGS go-version"},
+		{"WERCKER_STEP_ARGS_TAGS", `["netgo"]`},
+		{"WERCKER_STEP_ARGS_GO_VERSION", "1.9"},
+	}, env)
+}
+
+func (s *PropertiesSuite) TestValidateProperties() {
+	desc := &StepDesc{
+		Name: "typed",
+		Properties: []StepDescProperty{
+			{Name: "files", Type: "array"},
+			{Name: "args", Type: "object"},
+			{Name: "verbose", Type: "bool"},
+			{Name: "message"},
+		},
+	}
+
+	s.Nil(desc.ValidateProperties(map[string]interface{}{
+		"files":      []interface{}{"a.txt"},
+		"args":       map[string]interface{}{"A": "1"},
+		"verbose":    "true",
+		"message":    "hello",
+		"undeclared": []interface{}{},
+	}))
+
+	err := desc.ValidateProperties(map[string]interface{}{
+		"files":   "a.txt",
+		"verbose": []interface{}{true},
+	})
+	s.Require().NotNil(err)
+	s.Equal(`Invalid properties for step typed: files: expected a list, got "a.txt", verbose: expected a boolean, got a list`, err.Error())
+
+	var missing *StepDesc
+	s.Nil(missing.ValidateProperties(map[string]interface{}{"files": "a.txt"}))
+}
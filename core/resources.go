@@ -0,0 +1,208 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResourcesConfig is the `resources:` of a box or service, the limits of its
+// container
+type ResourcesConfig struct {
+	Memory     int64
+	MemorySwap int64
+	CPUs       float64
+	PidsLimit  int64
+	ShmSize    int64
+	Ulimits    []UlimitConfig
+	// Tmpfs has the mount options of each tmpfs mount by path
+	Tmpfs map[string]string
+}
+
+// UlimitConfig is a limit of the ulimits, the hard limit is the maximum the
+// soft limit can be raised to
+type UlimitConfig struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
+// UnmarshalYAML reads the resources, the sizes with a suffix are converted
+// to bytes
+func (r *ResourcesConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	m := map[string]interface{}{}
+	if err := unmarshal(&m); err != nil {
+		return fmt.Errorf("resources should be a map of limits")
+	}
+	*r = ResourcesConfig{}
+	for key, item := range m {
+		var err error
+		switch key {
+		case "memory":
+			r.Memory, err = parseByteSize(key, item)
+		case "memory-swap":
+			if n, ok := item.(int); ok && n == -1 {
+				r.MemorySwap = -1
+				break
+			}
+			r.MemorySwap, err = parseByteSize(key, item)
+		case "shm-size":
+			r.ShmSize, err = parseByteSize(key, item)
+		case "cpus":
+			r.CPUs, err = strconv.ParseFloat(fmt.Sprint(item), 64)
+			if err != nil || r.CPUs <= 0 {
+				err = fmt.Errorf("resources.cpus should be a positive number, got %v", item)
+			}
+		case "pids-limit":
+			n, ok := item.(int)
+			if !ok || n < 1 {
+				err = fmt.Errorf("resources.pids-limit should be a positive number, got %v", item)
+			}
+			r.PidsLimit = int64(n)
+		case "ulimits":
+			r.Ulimits, err = parseUlimits(item)
+		case "tmpfs":
+			r.Tmpfs, err = parseTmpfs(item)
+		default:
+			err = fmt.Errorf("Unknown resource %s, expected memory, memory-swap, cpus, pids-limit, shm-size, ulimits or tmpfs", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if r.MemorySwap > 0 && r.MemorySwap < r.Memory {
+		return fmt.Errorf("resources.memory-swap includes the memory, it should be at least %d, got %d", r.Memory, r.MemorySwap)
+	}
+	if r.MemorySwap != 0 && r.Memory == 0 {
+		return fmt.Errorf("resources.memory-swap needs resources.memory to be set")
+	}
+	return nil
+}
+
+// CPUQuota is the microseconds of CPU time the container gets every
+// CPUPeriod, 0 if it isn't limited
+func (r *ResourcesConfig) CPUQuota() int64 {
+	return int64(r.CPUs * float64(CPUPeriod))
+}
+
+// CPUPeriod is the period the CPU quota of resources is in, in microseconds
+const CPUPeriod = 100000
+
+var byteSizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgt]?)i?b?$`)
+
+var byteSizeUnits = map[string]float64{"": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40}
+
+// parseByteSize reads a number of bytes, or a size like 512m
+func parseByteSize(key string, item interface{}) (int64, error) {
+	if n, ok := item.(int); ok {
+		if n <= 0 {
+			return 0, fmt.Errorf("resources.%s should be positive, got %d", key, n)
+		}
+		return int64(n), nil
+	}
+	match := byteSizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(fmt.Sprint(item))))
+	if match == nil {
+		return 0, fmt.Errorf("resources.%s should be a size like 512m, got %v", key, item)
+	}
+	size, _ := strconv.ParseFloat(match[1], 64)
+	size *= byteSizeUnits[match[2]]
+	if size < 1 {
+		return 0, fmt.Errorf("resources.%s should be positive, got %v", key, item)
+	}
+	return int64(size), nil
+}
+
+// parseUlimits reads the ulimits by name, a number sets both the soft and
+// the hard limit, otherwise they are a map or soft:hard
+func parseUlimits(item interface{}) ([]UlimitConfig, error) {
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("resources.ulimits should be a map of limits by name")
+	}
+	ulimits := []UlimitConfig{}
+	for name, value := range m {
+		ulimit := UlimitConfig{Name: fmt.Sprint(name)}
+		invalid := fmt.Errorf("resources.ulimits.%s should be a number, soft:hard or a map with soft and hard, got %v", ulimit.Name, value)
+		switch v := value.(type) {
+		case int:
+			ulimit.Soft, ulimit.Hard = int64(v), int64(v)
+		case string:
+			parts := strings.Split(v, ":")
+			if len(parts) != 2 {
+				return nil, invalid
+			}
+			soft, err := strconv.ParseInt(parts[0], 10, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			hard, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			ulimit.Soft, ulimit.Hard = soft, hard
+		case map[interface{}]interface{}:
+			soft, ok := v["soft"].(int)
+			if !ok {
+				return nil, invalid
+			}
+			hard, ok := v["hard"].(int)
+			if !ok || len(v) != 2 {
+				return nil, invalid
+			}
+			ulimit.Soft, ulimit.Hard = int64(soft), int64(hard)
+		default:
+			return nil, invalid
+		}
+		if ulimit.Soft > ulimit.Hard {
+			return nil, fmt.Errorf("resources.ulimits.%s soft limit %d is over the hard limit %d", ulimit.Name, ulimit.Soft, ulimit.Hard)
+		}
+		ulimits = append(ulimits, ulimit)
+	}
+	sort.Slice(ulimits, func(i, j int) bool {
+		return ulimits[i].Name < ulimits[j].Name
+	})
+	return ulimits, nil
+}
+
+// parseTmpfs reads the tmpfs mounts, a path with the mount options after a
+// colon
+func parseTmpfs(item interface{}) (map[string]string, error) {
+	var mounts []interface{}
+	switch v := item.(type) {
+	case string:
+		mounts = []interface{}{v}
+	case []interface{}:
+		mounts = v
+	default:
+		return nil, fmt.Errorf("resources.tmpfs should be a list of paths")
+	}
+	tmpfs := map[string]string{}
+	for _, mount := range mounts {
+		parts := strings.SplitN(fmt.Sprint(mount), ":", 2)
+		if !strings.HasPrefix(parts[0], "/") {
+			return nil, fmt.Errorf("resources.tmpfs should have absolute paths, got %v", mount)
+		}
+		options := ""
+		if len(parts) == 2 {
+			options = parts[1]
+		}
+		tmpfs[parts[0]] = options
+	}
+	return tmpfs, nil
+}
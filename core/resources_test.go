@@ -0,0 +1,103 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type ResourcesSuite struct {
+	*util.TestSuite
+}
+
+func TestResourcesSuite(t *testing.T) {
+	suiteTester := &ResourcesSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *ResourcesSuite) TestConfig() {
+	config, err := ConfigFromYaml([]byte(`
+box:
+  id: golang
+  resources:
+    memory: 2g
+    memory-swap: -1
+    cpus: 1.5
+    pids-limit: 500
+    shm-size: 256MB
+    ulimits:
+      nproc: 4096
+      nofile: {soft: 1024, hard: 4096}
+      core: "0:1024"
+    tmpfs:
+      - /tmp
+      - /run:size=64m,mode=1777
+services:
+  - id: postgres:10
+    resources:
+      memory: 536870912
+      cpus: 1
+build:
+  steps:
+    - script:
+        code: make
+`))
+	s.Require().NoError(err)
+
+	s.Equal(&ResourcesConfig{
+		Memory:     2 << 30,
+		MemorySwap: -1,
+		CPUs:       1.5,
+		PidsLimit:  500,
+		ShmSize:    256 << 20,
+		Ulimits: []UlimitConfig{
+			{Name: "core", Soft: 0, Hard: 1024},
+			{Name: "nofile", Soft: 1024, Hard: 4096},
+			{Name: "nproc", Soft: 4096, Hard: 4096},
+		},
+		Tmpfs: map[string]string{
+			"/tmp": "",
+			"/run": "size=64m,mode=1777",
+		},
+	}, config.Box.Resources)
+	s.Equal(int64(150000), config.Box.Resources.CPUQuota())
+
+	s.Require().Len(config.Services, 1)
+	s.Equal(int64(512<<20), config.Services[0].Resources.Memory)
+	s.Equal(int64(100000), config.Services[0].Resources.CPUQuota())
+}
+
+func (s *ResourcesSuite) TestInvalidConfig() {
+	for _, resources := range []string{
+		"{memory: lots}",
+		"{memory: 0}",
+		"{memory: 1g, memory-swap: 512m}",
+		"{memory-swap: 1g}",
+		"{cpus: 0}",
+		"{cpus: all}",
+		"{pids-limit: -1}",
+		"{ulimits: {nofile: {soft: 4096, hard: 1024}}}",
+		"{ulimits: {nofile: many}}",
+		"{ulimits: [nofile]}",
+		"{tmpfs: [tmp]}",
+		"{disk: 10g}",
+	} {
+		_, err := ConfigFromYaml([]byte("box:\n  id: golang\n  resources: " + resources + "\n"))
+		s.Error(err, resources)
+	}
+}
@@ -0,0 +1,147 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RetryConfig is the retry policy in the `wercker:` settings of a step
+type RetryConfig struct {
+	Attempts  int
+	Delay     time.Duration
+	Backoff   float64
+	ExitCodes []int
+}
+
+// ParseRetry reads a RetryConfig from the value of the retry property
+func ParseRetry(value interface{}) (*RetryConfig, error) {
+	retry := &RetryConfig{Backoff: 1}
+	switch v := value.(type) {
+	case int:
+		retry.Attempts = v
+	case map[string]interface{}:
+		for key, item := range v {
+			var err error
+			switch key {
+			case "attempts":
+				n, ok := item.(int)
+				if !ok {
+					return nil, fmt.Errorf("retry.attempts should be a number, got %v", item)
+				}
+				retry.Attempts = n
+			case "delay":
+				retry.Delay, err = parseRetryDelay(item)
+			case "backoff":
+				retry.Backoff, err = parseRetryBackoff(item)
+			case "exit-codes":
+				retry.ExitCodes, err = parseRetryExitCodes(item)
+			default:
+				return nil, fmt.Errorf("Unknown retry setting %s, expected attempts, delay, backoff or exit-codes", key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("retry should be a number of attempts or a map, got %v", value)
+	}
+
+	if retry.Attempts < 1 {
+		return nil, fmt.Errorf("retry.attempts should be at least 1, got %d", retry.Attempts)
+	}
+	return retry, nil
+}
+
+func parseRetryDelay(item interface{}) (time.Duration, error) {
+	var delay time.Duration
+	switch v := item.(type) {
+	case int:
+		delay = time.Duration(v) * time.Second
+	case float64:
+		delay = time.Duration(v * float64(time.Second))
+	case string:
+		var err error
+		delay, err = time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("retry.delay should be a duration like 5s, got %s", v)
+		}
+	default:
+		return 0, fmt.Errorf("retry.delay should be a duration like 5s, got %v", item)
+	}
+	if delay < 0 {
+		return 0, fmt.Errorf("retry.delay can't be negative, got %s", delay)
+	}
+	return delay, nil
+}
+
+func parseRetryBackoff(item interface{}) (float64, error) {
+	var backoff float64
+	switch v := item.(type) {
+	case int:
+		backoff = float64(v)
+	case float64:
+		backoff = v
+	default:
+		return 0, fmt.Errorf("retry.backoff should be a number, got %v", item)
+	}
+	if backoff < 1 {
+		return 0, fmt.Errorf("retry.backoff should be at least 1, got %v", backoff)
+	}
+	return backoff, nil
+}
+
+func parseRetryExitCodes(item interface{}) ([]int, error) {
+	list, ok := item.([]interface{})
+	if !ok {
+		list = []interface{}{item}
+	}
+	codes := []int{}
+	for _, code := range list {
+		n, ok := code.(int)
+		if !ok {
+			return nil, fmt.Errorf("retry.exit-codes should be a list of numbers, got %v", item)
+		}
+		codes = append(codes, n)
+	}
+	return codes, nil
+}
+
+// ShouldRetry tells whether another attempt should be made after attempt,
+// counting from 1, failed with exitCode
+func (r *RetryConfig) ShouldRetry(attempt, exitCode int) bool {
+	if r == nil || attempt >= r.Attempts {
+		return false
+	}
+	if len(r.ExitCodes) == 0 {
+		return true
+	}
+	for _, code := range r.ExitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait is how long to wait after attempt, counting from 1, failed
+func (r *RetryConfig) Wait(attempt int) time.Duration {
+	if r == nil {
+		return 0
+	}
+	return time.Duration(float64(r.Delay) * math.Pow(r.Backoff, float64(attempt-1)))
+}
@@ -0,0 +1,87 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type RetrySuite struct {
+	*util.TestSuite
+}
+
+func TestRetrySuite(t *testing.T) {
+	suiteTester := &RetrySuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *RetrySuite) TestParseRetry() {
+	retry, err := ParseRetry(map[string]interface{}{
+		"attempts": 4,
+		"delay":    1.5,
+	})
+	s.Require().NoError(err)
+	s.Equal(&RetryConfig{Attempts: 4, Delay: 1500 * time.Millisecond, Backoff: 1}, retry)
+
+	retry, err = ParseRetry(map[string]interface{}{
+		"attempts":   2,
+		"exit-codes": 28,
+	})
+	s.Require().NoError(err)
+	s.Equal([]int{28}, retry.ExitCodes)
+
+	invalid := []interface{}{
+		"three",
+		-1,
+		map[string]interface{}{"delay": "5s"},
+		map[string]interface{}{"attempts": 2, "delay": "soon"},
+		map[string]interface{}{"attempts": 2, "backoff": 0.5},
+		map[string]interface{}{"attempts": 2, "exit-codes": []interface{}{"1"}},
+		map[string]interface{}{"attempts": 2, "tries": 3},
+	}
+	for _, value := range invalid {
+		_, err := ParseRetry(value)
+		s.Error(err, "%v", value)
+	}
+}
+
+func (s *RetrySuite) TestShouldRetry() {
+	var none *RetryConfig
+	s.False(none.ShouldRetry(1, 1))
+	s.Equal(time.Duration(0), none.Wait(1))
+
+	retry := &RetryConfig{Attempts: 3, Backoff: 1}
+	s.True(retry.ShouldRetry(1, 1))
+	s.True(retry.ShouldRetry(2, 137))
+	s.False(retry.ShouldRetry(3, 1))
+
+	retry.ExitCodes = []int{28}
+	s.True(retry.ShouldRetry(1, 28))
+	s.False(retry.ShouldRetry(1, 1))
+}
+
+func (s *RetrySuite) TestWait() {
+	retry := &RetryConfig{Attempts: 4, Delay: time.Second, Backoff: 2}
+	s.Equal(time.Second, retry.Wait(1))
+	s.Equal(2*time.Second, retry.Wait(2))
+	s.Equal(4*time.Second, retry.Wait(3))
+
+	retry.Backoff = 1
+	s.Equal(time.Second, retry.Wait(3))
+}
@@ -0,0 +1,783 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SchemaType is the JSON Schema "type" keyword, a single type is
+// marshalled as a plain string
+type SchemaType []string
+
+// MarshalJSON emits a string for a single type and a list otherwise
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Schema is the subset of JSON Schema we use to describe wercker.yml, it
+// is used both to validate configs and to export the schema for editors.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 SchemaType         `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinProperties        int                `json:"minProperties,omitempty"`
+	MaxProperties        int                `json:"maxProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// schemaFalse is used as AdditionalProperties to disallow unknown keys
+var schemaFalse = &Schema{}
+
+type schemaJSON Schema
+
+// MarshalJSON handles the boolean "false" schema
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s == schemaFalse {
+		return []byte("false"), nil
+	}
+	return json.Marshal((*schemaJSON)(s))
+}
+
+const schemaDefinitions = "#/definitions/"
+
+func schemaRef(name string) *Schema {
+	return &Schema{Ref: schemaDefinitions + name}
+}
+
+var (
+	// yaml happily turns numbers and bools into strings for us, so most
+	// string fields will accept any scalar
+	scalarSchema = &Schema{Type: SchemaType{"string", "number", "boolean"}}
+
+	boxAuthKeys = []string{
+		"username",
+		"password",
+		"registry",
+		"aws-region",
+		"aws-access-key",
+		"aws-secret-key",
+		"aws-registry-id",
+		"azure-client-id",
+		"azure-client-secret",
+		"azure-subscription-id",
+		"azure-tenant-id",
+		"azure-resource-group",
+		"azure-registry-name",
+		"azure-login-server",
+	}
+)
+
+func boxSchema() *Schema {
+	props := map[string]*Schema{
+		"id":         scalarSchema,
+		"name":       scalarSchema,
+		"tag":        scalarSchema,
+		"cmd":        scalarSchema,
+		"entrypoint": scalarSchema,
+		"url":        scalarSchema,
+		"volumes":    scalarSchema,
+		"env": &Schema{
+			Description:          "a map of environment variables",
+			Type:                 SchemaType{"object"},
+			AdditionalProperties: scalarSchema,
+		},
+		"ports": &Schema{
+			Description: "a list of ports",
+			Type:        SchemaType{"array"},
+			Items:       scalarSchema,
+		},
+		"healthcheck": &Schema{
+			Description: "how to tell the service is ready, with one of tcp, http or command",
+			Type:        SchemaType{"object"},
+			Properties: map[string]*Schema{
+				"tcp":      scalarSchema,
+				"http":     scalarSchema,
+				"command":  scalarSchema,
+				"interval": &Schema{Type: SchemaType{"string", "number"}},
+				"retries":  &Schema{Type: SchemaType{"integer"}},
+				"timeout":  &Schema{Type: SchemaType{"string", "number"}},
+			},
+			AdditionalProperties: schemaFalse,
+		},
+		"depends-on": &Schema{
+			Description: "a list of the services to wait for",
+			Type:        SchemaType{"array"},
+			Items:       scalarSchema,
+		},
+		"logs": &Schema{
+			Description: "show the output of the service",
+			Type:        SchemaType{"boolean"},
+		},
+		"build": &Schema{
+			Description: "build the image from a Dockerfile",
+			Type:        SchemaType{"object"},
+			Properties: map[string]*Schema{
+				"context":    scalarSchema,
+				"dockerfile": scalarSchema,
+				"target":     scalarSchema,
+				"args": &Schema{
+					Description:          "a map of build args",
+					Type:                 SchemaType{"object"},
+					AdditionalProperties: scalarSchema,
+				},
+			},
+			AdditionalProperties: schemaFalse,
+		},
+		"resources": &Schema{
+			Description: "limits for the container, like memory: 2g",
+			Type:        SchemaType{"object"},
+			Properties: map[string]*Schema{
+				"memory":      &Schema{Type: SchemaType{"string", "integer"}},
+				"memory-swap": &Schema{Type: SchemaType{"string", "integer"}},
+				"cpus":        &Schema{Type: SchemaType{"number"}},
+				"pids-limit":  &Schema{Type: SchemaType{"integer"}},
+				"shm-size":    &Schema{Type: SchemaType{"string", "integer"}},
+				"ulimits": &Schema{
+					Type: SchemaType{"object"},
+					AdditionalProperties: &Schema{
+						Type: SchemaType{"string", "integer", "object"},
+					},
+				},
+				"tmpfs": &Schema{
+					Type:  SchemaType{"string", "array"},
+					Items: scalarSchema,
+				},
+			},
+			AdditionalProperties: schemaFalse,
+		},
+	}
+	for _, k := range boxAuthKeys {
+		props[k] = scalarSchema
+	}
+	return &Schema{
+		Description: "a box",
+		OneOf: []*Schema{
+			&Schema{Description: "a box name", Type: SchemaType{"string"}},
+			&Schema{
+				Description:          "a box definition",
+				Type:                 SchemaType{"object"},
+				Properties:           props,
+				AdditionalProperties: schemaFalse,
+			},
+		},
+	}
+}
+
+func retrySchema() *Schema {
+	return &Schema{
+		Description: "how often to try the step",
+		OneOf: []*Schema{
+			&Schema{Description: "the number of attempts", Type: SchemaType{"integer"}},
+			&Schema{
+				Description: "a retry policy",
+				Type:        SchemaType{"object"},
+				Properties: map[string]*Schema{
+					"attempts":   &Schema{Type: SchemaType{"integer"}},
+					"delay":      &Schema{Type: SchemaType{"string", "number"}},
+					"backoff":    &Schema{Type: SchemaType{"number"}},
+					"exit-codes": &Schema{Type: SchemaType{"array"}, Items: &Schema{Type: SchemaType{"integer"}}},
+				},
+				AdditionalProperties: schemaFalse,
+			},
+		},
+	}
+}
+
+func timeoutSchema() *Schema {
+	return &Schema{
+		Description: "a number of minutes or a duration like 90s",
+		Type:        SchemaType{"number", "string"},
+	}
+}
+
+func cacheKeySchema() *Schema {
+	files := &Schema{
+		Description: "a list of globs",
+		Type:        SchemaType{"array"},
+		Items:       &Schema{Type: SchemaType{"string"}},
+	}
+	return &Schema{
+		Description: "what decides whether the step's outputs can be restored",
+		OneOf: []*Schema{
+			files,
+			&Schema{
+				Description: "globs and env vars",
+				Type:        SchemaType{"object"},
+				Properties: map[string]*Schema{
+					"files": files,
+					"env": &Schema{
+						Description: "a list of env var names",
+						Type:        SchemaType{"array"},
+						Items:       &Schema{Type: SchemaType{"string"}},
+					},
+				},
+				AdditionalProperties: schemaFalse,
+			},
+		},
+	}
+}
+
+func stepSchema() *Schema {
+	stepData := &Schema{
+		Description: "step properties",
+		Type:        SchemaType{"object"},
+		Properties: map[string]*Schema{
+			StepSettingsKey: &Schema{
+				Description: "how wercker runs the step",
+				Type:        SchemaType{"object"},
+				Properties: map[string]*Schema{
+					"retry":               retrySchema(),
+					"timeout":             timeoutSchema(),
+					"no-response-timeout": timeoutSchema(),
+					"cache-key":           cacheKeySchema(),
+					"outputs": &Schema{
+						Description: "a list of paths",
+						Type:        SchemaType{"array"},
+						Items:       &Schema{Type: SchemaType{"string"}},
+					},
+				},
+				AdditionalProperties: schemaFalse,
+			},
+		},
+		AdditionalProperties: &Schema{
+			Type: SchemaType{"string", "number", "boolean", "array", "object", "null"},
+		},
+	}
+	return &Schema{
+		Description: "a step",
+		OneOf: []*Schema{
+			&Schema{Description: "a step name", Type: SchemaType{"string"}},
+			&Schema{
+				Description:          "a step with properties",
+				Type:                 SchemaType{"object"},
+				MinProperties:        1,
+				MaxProperties:        1,
+				AdditionalProperties: stepData,
+			},
+			// The "script:\n  code: done wrong" style where the properties
+			// end up as siblings of the step name
+			&Schema{
+				Description:          "a step with properties",
+				Type:                 SchemaType{"object"},
+				MinProperties:        2,
+				AdditionalProperties: &Schema{Type: SchemaType{"string", "number", "boolean", "null"}},
+			},
+			&Schema{
+				Description: "a group of steps that run in parallel",
+				Type:        SchemaType{"object"},
+				Properties: map[string]*Schema{
+					"parallel": schemaRef("steps"),
+				},
+				MinProperties:        1,
+				AdditionalProperties: schemaFalse,
+			},
+		},
+	}
+}
+
+// WerckerSchema returns the JSON Schema for wercker.yml
+func WerckerSchema() *Schema {
+	return &Schema{
+		Schema:      "http://json-schema.org/draft-07/schema#",
+		Title:       "wercker.yml",
+		Description: "a wercker.yml",
+		Type:        SchemaType{"object"},
+		Properties: map[string]*Schema{
+			"box":                 schemaRef("box"),
+			"services":            schemaRef("services"),
+			"command-timeout":     &Schema{Type: SchemaType{"integer"}},
+			"no-response-timeout": &Schema{Type: SchemaType{"integer"}},
+			"source-dir":          scalarSchema,
+			"ignore-file":         scalarSchema,
+			"workflows":           schemaRef("workflows"),
+			"notifications":       schemaRef("notifications"),
+			"secrets": &Schema{
+				Description: "patterns of secrets to mask in the logs, besides the protected env vars",
+				Type:        SchemaType{"array"},
+				Items:       &Schema{Type: SchemaType{"string"}},
+			},
+			"secret-providers": &Schema{
+				Description: "a map of providers of the secrets the env refers to with secret://provider/path#key",
+				Type:        SchemaType{"object"},
+				AdditionalProperties: &Schema{
+					Description: "a secrets provider, its type is env-file, vault or pass",
+					Type:        SchemaType{"object"},
+					Properties: map[string]*Schema{
+						"type": scalarSchema,
+					},
+					AdditionalProperties: scalarSchema,
+				},
+			},
+		},
+		// Everything else is a pipeline
+		AdditionalProperties: schemaRef("pipeline"),
+		Definitions: map[string]*Schema{
+			"box": boxSchema(),
+			"services": &Schema{
+				Description: "a list of boxes",
+				Type:        SchemaType{"array"},
+				Items:       schemaRef("box"),
+			},
+			"step": stepSchema(),
+			"steps": &Schema{
+				Description: "a list of steps",
+				Type:        SchemaType{"array"},
+				Items:       schemaRef("step"),
+			},
+			"matrix": &Schema{
+				Description: "a list of pipeline variants",
+				Type:        SchemaType{"array"},
+				Items: &Schema{
+					Description: "a pipeline variant",
+					Type:        SchemaType{"object"},
+					Properties: map[string]*Schema{
+						"name":     scalarSchema,
+						"box":      schemaRef("box"),
+						"services": schemaRef("services"),
+						"env": &Schema{
+							Description:          "a map of environment variables",
+							Type:                 SchemaType{"object"},
+							AdditionalProperties: scalarSchema,
+						},
+					},
+					AdditionalProperties: schemaFalse,
+				},
+			},
+			"workflows": &Schema{
+				Description: "a list of workflows",
+				Type:        SchemaType{"array"},
+				Items: &Schema{
+					Description: "a workflow",
+					Type:        SchemaType{"object"},
+					Properties: map[string]*Schema{
+						"name": scalarSchema,
+						"pipelines": &Schema{
+							Description: "a list of workflow pipelines",
+							Type:        SchemaType{"array"},
+							Items: &Schema{
+								Description: "a workflow pipeline",
+								Type:        SchemaType{"object"},
+								Properties: map[string]*Schema{
+									"name":     scalarSchema,
+									"pipeline": scalarSchema,
+									"type":     scalarSchema,
+									"requires": &Schema{
+										Description: "a list of pipeline names",
+										Type:        SchemaType{"array"},
+										Items:       scalarSchema,
+									},
+								},
+								AdditionalProperties: schemaFalse,
+							},
+						},
+					},
+					AdditionalProperties: schemaFalse,
+				},
+			},
+			"notifications": &Schema{
+				Description: "where to send notifications about the build",
+				Type:        SchemaType{"object"},
+				Properties: map[string]*Schema{
+					"webhooks": &Schema{
+						Description: "a list of webhooks",
+						Type:        SchemaType{"array"},
+						Items: &Schema{
+							Description: "a webhook",
+							Type:        SchemaType{"object"},
+							Properties: map[string]*Schema{
+								"url":    scalarSchema,
+								"secret": scalarSchema,
+								"events": &Schema{
+									Description: "a list of build-started, step-finished or pipeline-finished",
+									Type:        SchemaType{"array"},
+									Items:       &Schema{Type: SchemaType{"string"}},
+								},
+								"headers": &Schema{
+									Description:          "a map of HTTP headers",
+									Type:                 SchemaType{"object"},
+									AdditionalProperties: scalarSchema,
+								},
+								"retry":    retrySchema(),
+								"template": scalarSchema,
+							},
+							AdditionalProperties: schemaFalse,
+						},
+					},
+				},
+				AdditionalProperties: schemaFalse,
+			},
+			"pipeline": &Schema{
+				Description: "a pipeline",
+				Type:        SchemaType{"object"},
+				Properties: map[string]*Schema{
+					"box":         schemaRef("box"),
+					"services":    schemaRef("services"),
+					"steps":       schemaRef("steps"),
+					"after-steps": schemaRef("steps"),
+					"base-path":   scalarSchema,
+					"docker":      &Schema{Type: SchemaType{"boolean"}},
+					"matrix":      schemaRef("matrix"),
+					"env": &Schema{
+						Description:          "a map of environment variables",
+						Type:                 SchemaType{"object"},
+						AdditionalProperties: scalarSchema,
+					},
+					"timeout": timeoutSchema(),
+					"transport": &Schema{
+						Description: "how commands are sent to the box, attach (the default) or exec",
+						Type:        SchemaType{"string"},
+					},
+				},
+				// Everything else is a deploy target
+				AdditionalProperties: schemaRef("steps"),
+			},
+		},
+	}
+}
+
+// ConfigError is a problem found when validating a wercker.yml, Line and
+// Column are 1-based and point at the offending key or list item.
+type ConfigError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// ValidateConfig checks the wercker.yml in file against WerckerSchema and
+// returns every problem it finds. The error is only set when the file
+// isn't valid yaml at all.
+func ValidateConfig(file []byte) ([]*ConfigError, error) {
+	var doc yaml.MapSlice
+	err := yaml.Unmarshal(file, &doc)
+	if err != nil {
+		// Not a map at the top level, see what it is for the error message
+		var other interface{}
+		if err2 := yaml.Unmarshal(file, &other); err2 != nil {
+			return nil, err2
+		}
+		v := newSchemaValidator(WerckerSchema(), file)
+		v.validate(v.root, other, nil)
+		return v.errors, nil
+	}
+	v := newSchemaValidator(WerckerSchema(), file)
+	if len(doc) == 0 {
+		v.fail(nil, "Your wercker.yml is empty.")
+		return v.errors, nil
+	}
+	v.validate(v.root, doc, nil)
+	return v.errors, nil
+}
+
+type schemaValidator struct {
+	root    *Schema
+	locator *yamlLocator
+	errors  []*ConfigError
+}
+
+func newSchemaValidator(root *Schema, file []byte) *schemaValidator {
+	return &schemaValidator{root: root, locator: newYamlLocator(file)}
+}
+
+func (v *schemaValidator) fail(path []string, format string, args ...interface{}) {
+	pos := v.locator.Position(path)
+	v.errors = append(v.errors, &ConfigError{
+		Path:    strings.Join(path, "."),
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *schemaValidator) resolve(s *Schema) *Schema {
+	if s.Ref == "" {
+		return s
+	}
+	return v.root.Definitions[strings.TrimPrefix(s.Ref, schemaDefinitions)]
+}
+
+// valid reports whether value validates against s without recording errors
+func (v *schemaValidator) valid(s *Schema, value interface{}) bool {
+	sub := &schemaValidator{root: v.root, locator: v.locator}
+	sub.validate(s, value, nil)
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) validate(s *Schema, value interface{}, path []string) {
+	s = v.resolve(s)
+
+	if len(s.OneOf) > 0 {
+		// An option that knows every key of a map wins, that is how a
+		// parallel group is told apart from a step with properties
+		for _, option := range s.OneOf {
+			option = v.resolve(option)
+			if v.matchesShape(option, value) && knowsKeys(option, value) {
+				v.validate(option, value, path)
+				return
+			}
+		}
+		for _, option := range s.OneOf {
+			option = v.resolve(option)
+			if v.matchesShape(option, value) {
+				v.validate(option, value, path)
+				return
+			}
+		}
+		v.fail(path, "should be %s, got %s", describeSchema(s), describeValue(value))
+		return
+	}
+
+	if !v.matchesShape(s, value) {
+		v.fail(path, "should be %s, got %s", describeSchema(s), describeValue(value))
+		return
+	}
+
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		for _, item := range value {
+			key := fmt.Sprintf("%v", item.Key)
+			itemPath := appendPath(path, key)
+			if prop, ok := s.Properties[key]; ok {
+				v.validate(prop, item.Value, itemPath)
+				continue
+			}
+
+			if s.AdditionalProperties == schemaFalse {
+				if suggestion := v.suggest(s, key, item.Value, 2); suggestion != "" {
+					v.fail(itemPath, "unknown key %q, did you mean %q?", key, suggestion)
+				} else {
+					v.fail(itemPath, "unknown key %q", key)
+				}
+				continue
+			}
+
+			if s.AdditionalProperties == nil {
+				continue
+			}
+
+			// Extra keys are allowed (pipelines, deploy targets) so only
+			// complain about near misses that look like typos of a known key
+			if suggestion := v.suggest(s, key, item.Value, 1); suggestion != "" {
+				v.fail(itemPath, "unknown key %q, did you mean %q?", key, suggestion)
+				continue
+			}
+			if !v.valid(s.AdditionalProperties, item.Value) {
+				if suggestion := v.suggest(s, key, nil, 2); suggestion != "" {
+					v.fail(itemPath, "unknown key %q, did you mean %q?", key, suggestion)
+					continue
+				}
+			}
+			v.validate(s.AdditionalProperties, item.Value, itemPath)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range value {
+			v.validate(s.Items, item, appendPath(path, strconv.Itoa(i)))
+		}
+	}
+}
+
+// matchesShape checks the type and size constraints of s but not its
+// children, it's used to pick between oneOf options.
+func (v *schemaValidator) matchesShape(s *Schema, value interface{}) bool {
+	if len(s.Type) > 0 && !matchesType(s.Type, value) {
+		return false
+	}
+	if m, ok := value.(yaml.MapSlice); ok {
+		if s.MinProperties > 0 && len(m) < s.MinProperties {
+			return false
+		}
+		if s.MaxProperties > 0 && len(m) > s.MaxProperties {
+			return false
+		}
+	}
+	return true
+}
+
+// knowsKeys reports whether value is a map with only keys s has properties
+// for
+func knowsKeys(s *Schema, value interface{}) bool {
+	m, ok := value.(yaml.MapSlice)
+	if !ok || len(s.Properties) == 0 {
+		return false
+	}
+	for _, item := range m {
+		if _, ok := s.Properties[fmt.Sprintf("%v", item.Key)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// suggest finds a known property that key is probably a typo of. With a
+// non-nil value the property must also accept the value, which keeps us from
+// flagging pipelines and deploy targets that just happen to have similar
+// names to known keys.
+func (v *schemaValidator) suggest(s *Schema, key string, value interface{}, maxDistance int) string {
+	normalized := strings.Replace(strings.ToLower(key), "_", "-", -1)
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if normalized == name {
+			return name
+		}
+	}
+	for _, name := range names {
+		if len(name) < 4 || levenshtein(normalized, name) > maxDistance {
+			continue
+		}
+		if value != nil && !v.valid(s.Properties[name], value) {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+func matchesType(types SchemaType, value interface{}) bool {
+	for _, t := range types {
+		switch t {
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "integer":
+			switch value.(type) {
+			case int, int64, uint64:
+				return true
+			}
+		case "number":
+			switch value.(type) {
+			case int, int64, uint64, float64:
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "object":
+			if _, ok := value.(yaml.MapSlice); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeSchema(s *Schema) string {
+	if s.Description != "" {
+		return s.Description
+	}
+	names := make([]string, len(s.Type))
+	for i, t := range s.Type {
+		switch t {
+		case "integer":
+			names[i] = "an integer"
+		case "object":
+			names[i] = "a map"
+		case "array":
+			names[i] = "a list"
+		default:
+			names[i] = "a " + t
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int, int64, uint64, float64:
+		return "a number"
+	case []interface{}:
+		return "a list"
+	case yaml.MapSlice:
+		return "a map"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
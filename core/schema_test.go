@@ -0,0 +1,200 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type SchemaSuite struct {
+	*util.TestSuite
+}
+
+func TestSchemaSuite(t *testing.T) {
+	suiteTester := &SchemaSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *SchemaSuite) TestValidConfigs() {
+	for _, f := range []string{"../tests/box_strings.yml", "../tests/box_structs.yml", "../wercker.yml"} {
+		b, err := ioutil.ReadFile(f)
+		s.Require().Nil(err)
+		errs, err := ValidateConfig(b)
+		s.Nil(err)
+		s.Empty(errs, f)
+	}
+}
+
+func (s *SchemaSuite) TestInvalidConfig() {
+	b, err := ioutil.ReadFile("../tests/schema_errors.yml")
+	s.Require().Nil(err)
+	errs, err := ValidateConfig(b)
+	s.Require().Nil(err)
+
+	expected := []string{
+		`2:1: no_response_timeout: unknown key "no_response_timeout", did you mean "no-response-timeout"?`,
+		`3:1: services: should be a list of boxes, got a map`,
+		`10:3: build.after_steps: unknown key "after_steps", did you mean "after-steps"?`,
+		`13:3: build.docker: should be a boolean, got a string`,
+		`18:5: deploy.box.usrname: unknown key "usrname", did you mean "username"?`,
+		`20:7: deploy.steps.0.script: should be step properties, got a string`,
+		`21:3: deploy.production: should be a list of steps, got a string`,
+	}
+	actual := []string{}
+	for _, e := range errs {
+		actual = append(actual, e.Error())
+	}
+	s.Equal(expected, actual)
+}
+
+func (s *SchemaSuite) TestParallelSteps() {
+	errs, err := ValidateConfig([]byte(`box: ubuntu
+build:
+  steps:
+    - parallel:
+        - script:
+            code: make lint
+        - "make-test"
+`))
+	s.Nil(err)
+	s.Empty(errs)
+
+	errs, err = ValidateConfig([]byte(`box: ubuntu
+build:
+  steps:
+    - parallel:
+        - script: make lint
+`))
+	s.Nil(err)
+	s.Require().Len(errs, 1)
+	s.Equal(`5:11: build.steps.0.parallel.0.script: should be step properties, got a string`, errs[0].Error())
+}
+
+func (s *SchemaSuite) TestStepRetry() {
+	errs, err := ValidateConfig([]byte(`box: ubuntu
+build:
+  steps:
+    - script:
+        code: npm install
+        wercker:
+          retry:
+            attempts: 3
+            delay: 5s
+            exit-codes: [1]
+    - script:
+        code: make
+        wercker:
+          retry: 2
+          timeout: 10m
+`))
+	s.Nil(err)
+	s.Empty(errs)
+
+	errs, err = ValidateConfig([]byte(`box: ubuntu
+build:
+  steps:
+    - script:
+        code: npm install
+        wercker:
+          retry:
+            attemps: 3
+          timout: 10m
+`))
+	s.Nil(err)
+	s.Require().Len(errs, 2)
+	s.Equal(`8:13: build.steps.0.script.wercker.retry.attemps: unknown key "attemps", did you mean "attempts"?`, errs[0].Error())
+	s.Equal(`9:11: build.steps.0.script.wercker.timout: unknown key "timout", did you mean "timeout"?`, errs[1].Error())
+}
+
+func (s *SchemaSuite) TestNotifications() {
+	errs, err := ValidateConfig([]byte(`box: ubuntu
+notifications:
+  webhooks:
+    - url: https://chat.example.com/hooks/$CHAT_HOOK
+      secret: $CHAT_SECRET
+      events: [pipeline-finished]
+      retry: 3
+      template: '{"text": "{{.Pipeline}}"}'
+build:
+  steps:
+    - script:
+        code: make
+`))
+	s.Nil(err)
+	s.Empty(errs)
+
+	errs, err = ValidateConfig([]byte(`box: ubuntu
+notifications:
+  webhooks:
+    - url: https://chat.example.com
+      header:
+        X-Team: ci
+build:
+  steps:
+    - script:
+        code: make
+`))
+	s.Nil(err)
+	s.Require().Len(errs, 1)
+	s.Equal(`5:7: notifications.webhooks.0.header: unknown key "header", did you mean "headers"?`, errs[0].Error())
+}
+
+func (s *SchemaSuite) TestInvalidYaml() {
+	_, err := ValidateConfig([]byte("box: [ubuntu\n"))
+	s.NotNil(err)
+}
+
+func (s *SchemaSuite) TestEmptyConfig() {
+	errs, err := ValidateConfig([]byte("# nothing here\n"))
+	s.Nil(err)
+	s.Require().Len(errs, 1)
+	s.Equal("Your wercker.yml is empty.", errs[0].Message)
+}
+
+func (s *SchemaSuite) TestLocator() {
+	l := newYamlLocator([]byte(`build:
+  steps:
+  - script:
+      name: "first"
+      code: |
+        echo "not: a key"
+  - "quoted-step"
+  - script:
+    code: legacy
+`))
+	s.Equal(yamlPosition{1, 1}, l.Position([]string{"build"}))
+	s.Equal(yamlPosition{3, 3}, l.Position([]string{"build", "steps", "0"}))
+	s.Equal(yamlPosition{4, 7}, l.Position([]string{"build", "steps", "0", "script", "name"}))
+	s.Equal(yamlPosition{5, 7}, l.Position([]string{"build", "steps", "0", "script", "code", "extra"}))
+	s.Equal(yamlPosition{7, 3}, l.Position([]string{"build", "steps", "1"}))
+	s.Equal(yamlPosition{9, 5}, l.Position([]string{"build", "steps", "2", "code"}))
+	s.Equal(yamlPosition{1, 1}, l.Position([]string{"missing"}))
+}
+
+func (s *SchemaSuite) TestSchemaJSON() {
+	b, err := json.Marshal(WerckerSchema())
+	s.Require().Nil(err)
+	var m map[string]interface{}
+	s.Require().Nil(json.Unmarshal(b, &m))
+	s.Equal("object", m["type"])
+	box := m["definitions"].(map[string]interface{})["box"].(map[string]interface{})
+	boxObject := box["oneOf"].([]interface{})[1].(map[string]interface{})
+	s.Equal(false, boxObject["additionalProperties"])
+}
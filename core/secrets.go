@@ -0,0 +1,212 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wercker/wercker/util"
+)
+
+const (
+	// SecretMask replaces the secrets in the logs
+	SecretMask = "****"
+
+	// minSecretLength is the length a value needs to be masked, shorter
+	// ones would mask all kinds of things that aren't secret
+	minSecretLength = 4
+)
+
+// SecretPattern is a regexp from the `secrets:` of wercker.yml, what it or
+// its groups match is masked in the logs
+type SecretPattern struct {
+	*regexp.Regexp
+}
+
+// UnmarshalYAML compiles the pattern
+func (p *SecretPattern) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return fmt.Errorf("Invalid secrets pattern %q: %s", s, err)
+	}
+	p.Regexp = re
+	return nil
+}
+
+// Secrets are the values and patterns masked in the logs. Besides the value
+// itself its base64 and url encoded forms are masked.
+type Secrets struct {
+	sync.RWMutex
+	values   map[string]bool
+	forms    []string // Longest first so a form containing another is masked as a whole
+	replacer *strings.Replacer
+	patterns []*SecretPattern
+}
+
+// NewSecrets constructor
+func NewSecrets() *Secrets {
+	return &Secrets{
+		values:   map[string]bool{},
+		replacer: strings.NewReplacer(),
+	}
+}
+
+// secretForms are the ways value may show up in the logs
+func secretForms(value string) []string {
+	forms := []string{
+		value,
+		base64.RawStdEncoding.EncodeToString([]byte(value)),
+		base64.RawURLEncoding.EncodeToString([]byte(value)),
+		url.QueryEscape(value),
+		url.PathEscape(value),
+	}
+	unique := []string{}
+	for _, form := range forms {
+		if !util.ContainsString(unique, form) {
+			unique = append(unique, form)
+		}
+	}
+	return unique
+}
+
+// Add masks values
+func (s *Secrets) Add(values ...string) {
+	s.Lock()
+	defer s.Unlock()
+	added := false
+	for _, value := range values {
+		if len(value) < minSecretLength || s.values[value] {
+			continue
+		}
+		s.values[value] = true
+		s.forms = append(s.forms, secretForms(value)...)
+		added = true
+	}
+	if !added {
+		return
+	}
+	sort.SliceStable(s.forms, func(i, j int) bool { return len(s.forms[i]) > len(s.forms[j]) })
+	pairs := []string{}
+	for _, form := range s.forms {
+		pairs = append(pairs, form, SecretMask)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+// AddEnvironment masks the values of the hidden env vars of env
+func (s *Secrets) AddEnvironment(env *util.Environment) {
+	if env == nil || env.Hidden == nil {
+		return
+	}
+	values := []string{}
+	for _, key := range env.Hidden.Order {
+		values = append(values, env.Hidden.Map[key])
+	}
+	s.Add(values...)
+}
+
+// AddPatterns masks what patterns match
+func (s *Secrets) AddPatterns(patterns ...*SecretPattern) {
+	s.Lock()
+	defer s.Unlock()
+	for _, pattern := range patterns {
+		known := false
+		for _, p := range s.patterns {
+			known = known || p.String() == pattern.String()
+		}
+		if !known {
+			s.patterns = append(s.patterns, pattern)
+		}
+	}
+}
+
+// Mask replaces the secrets in logs with SecretMask
+func (s *Secrets) Mask(logs string) string {
+	s.RLock()
+	defer s.RUnlock()
+	logs = s.replacer.Replace(logs)
+	for _, pattern := range s.patterns {
+		logs = maskPattern(pattern.Regexp, logs)
+	}
+	return logs
+}
+
+// maskPattern masks the matches of re in logs, or the groups of them if
+// it has any
+func maskPattern(re *regexp.Regexp, logs string) string {
+	var b bytes.Buffer
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(logs, -1) {
+		groups := [][]int{match[:2]}
+		if len(match) > 2 {
+			groups = nil
+			for i := 2; i < len(match); i += 2 {
+				groups = append(groups, match[i:i+2])
+			}
+		}
+		for _, group := range groups {
+			// Groups that didn't match or are part of one masked already
+			if group[0] < last || group[0] == group[1] {
+				continue
+			}
+			b.WriteString(logs[last:group[0]])
+			b.WriteString(SecretMask)
+			last = group[1]
+		}
+	}
+	b.WriteString(logs[last:])
+	return b.String()
+}
+
+// Hold is where to cut logs that were masked so the start of a secret that
+// is only partly logged so far isn't let through. The secret may be finished
+// by the next logs, so the part after the cut needs to be held back until
+// then. The patterns may match anything so the last line is held back if
+// there are any.
+func (s *Secrets) Hold(logs string) int {
+	s.RLock()
+	defer s.RUnlock()
+	cut := len(logs)
+	if len(s.patterns) > 0 {
+		cut = strings.LastIndexAny(logs, "\r\n") + 1
+	}
+	longest := 0
+	if len(s.forms) > 0 {
+		longest = len(s.forms[0])
+	}
+	start := len(logs) - longest + 1
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < cut; i++ {
+		for _, form := range s.forms {
+			if len(form) > len(logs)-i && strings.HasPrefix(form, logs[i:]) {
+				return i
+			}
+		}
+	}
+	return cut
+}
@@ -0,0 +1,169 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type SecretsSuite struct {
+	*util.TestSuite
+}
+
+func TestSecretsSuite(t *testing.T) {
+	suiteTester := &SecretsSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *SecretsSuite) TestMask() {
+	secrets := NewSecrets()
+	env := util.NewEnvironment("HOME=/root")
+	env.Hidden.Add("TOKEN", "s3cr3t/value")
+	env.Hidden.Add("SHORT", "abc")
+	secrets.AddEnvironment(env)
+
+	s.Equal("token ****!", secrets.Mask("token s3cr3t/value!"))
+	// base64 and url encoded
+	s.Equal("****=", secrets.Mask("czNjcjN0L3ZhbHVl="))
+	s.Equal("****", secrets.Mask("czNjcjN0L3ZhbHVl"))
+	s.Equal("?token=****", secrets.Mask("?token=s3cr3t%2Fvalue"))
+	// too short to mask
+	s.Equal("abc /root", secrets.Mask("abc /root"))
+}
+
+func (s *SecretsSuite) TestMaskLongestFirst() {
+	secrets := NewSecrets()
+	secrets.Add("secret", "secret-value")
+	s.Equal("**** and ****", secrets.Mask("secret-value and secret"))
+}
+
+func (s *SecretsSuite) TestPatterns() {
+	config, err := ConfigFromYaml([]byte(`box: ubuntu
+secrets:
+  - 'ghp_[A-Za-z0-9]{8}'
+  - 'password=(\S+)'
+build:
+  steps:
+    - script:
+        code: make
+`))
+	s.Require().NoError(err)
+	s.Require().Len(config.Secrets, 2)
+
+	secrets := NewSecrets()
+	secrets.AddPatterns(config.Secrets...)
+	secrets.AddPatterns(config.Secrets...)
+	s.Equal("token **** password=**** user=me", secrets.Mask("token ghp_abcd1234 password=hunter22 user=me"))
+
+	_, err = ConfigFromYaml([]byte("box: ubuntu\nsecrets: ['ghp_[a-z']\n"))
+	s.Require().Error(err)
+	s.Contains(err.Error(), `Invalid secrets pattern "ghp_[a-z"`)
+}
+
+func (s *SecretsSuite) TestHold() {
+	secrets := NewSecrets()
+	s.Equal(5, secrets.Hold("token"))
+
+	secrets.Add("s3cr3t")
+	s.Equal(6, secrets.Hold("token "))
+	s.Equal(6, secrets.Hold("token s3c"))
+	s.Equal(11, secrets.Hold("token **** "))
+
+	// the last line may still be matched by a pattern
+	config, err := ConfigFromYaml([]byte("box: ubuntu\nsecrets: ['key-\\d+']\n"))
+	s.Require().NoError(err)
+	secrets.AddPatterns(config.Secrets...)
+	s.Equal(6, secrets.Hold("line\r\nkey-"))
+}
+
+func (s *SecretsSuite) TestEmitterMasksSplitSecrets() {
+	e := NewNormalizedEmitter()
+	e.Secrets().Add("s3cr3t-value")
+	logs := []string{}
+	e.AddListener(Logs, func(args *LogsArgs) {
+		logs = append(logs, args.Logs)
+	})
+	var finished *BuildStepFinishedArgs
+	e.AddListener(BuildStepFinished, func(args *BuildStepFinishedArgs) {
+		finished = args
+	})
+
+	e.Emit(Logs, &LogsArgs{Logs: "token is s3cr"})
+	e.Emit(Logs, &LogsArgs{Logs: "3t-value\n"})
+	e.Emit(Logs, &LogsArgs{Logs: "export TOKEN=s3cr3t-value", Hidden: true})
+	e.Emit(Logs, &LogsArgs{Logs: "not s3cr"})
+	e.Emit(BuildStepFinished, &BuildStepFinishedArgs{Message: "bad token s3cr3t-value"})
+
+	s.Equal([]string{
+		"token is ",
+		"****\n",
+		"export TOKEN=****",
+		"not ",
+		"s3cr",
+	}, logs)
+	s.Require().NotNil(finished)
+	s.Equal("bad token ****", finished.Message)
+}
+
+func (s *SecretsSuite) TestForkMasksSplitSecrets() {
+	e := NewNormalizedEmitter()
+	e.Secrets().Add("s3cr3t-value")
+	logs := []string{}
+	e.AddListener(Logs, func(args *LogsArgs) {
+		logs = append(logs, args.Logs)
+	})
+
+	fork := e.Fork("[lint] ")
+	fork.Emit(Logs, &LogsArgs{Logs: "one s3cr3t-"})
+	fork.Emit(Logs, &LogsArgs{Logs: "value\ntwo s3"})
+	fork.Emit(BuildStepFinished, &BuildStepFinishedArgs{})
+
+	s.Equal([]string{
+		"[lint] one ****\n",
+		"[lint] two s3\n",
+	}, logs)
+}
+
+func (s *SecretsSuite) TestEmitterFlushesHeldLogsWithTheirStep() {
+	e := NewNormalizedEmitter()
+	e.Secrets().Add("s3cr3t-value")
+	logs := []*LogsArgs{}
+	e.AddListener(Logs, func(args *LogsArgs) {
+		logs = append(logs, args)
+	})
+	step := testStep("test")
+
+	e.Emit(Logs, &LogsArgs{Logs: "setup s3"})
+	e.Emit(BuildStepStarted, &BuildStepStartedArgs{Step: step, Order: 2})
+	e.Emit(Logs, &LogsArgs{Logs: "out s3cr"})
+	e.Emit(Logs, &LogsArgs{Logs: "err s3cr3t-", Stream: "stderr"})
+	e.Emit(Logs, &LogsArgs{Logs: "value\n", Stream: "stderr"})
+	e.Emit(BuildStepFinished, &BuildStepFinishedArgs{})
+
+	s.Require().Len(logs, 6)
+	s.Equal("setup ", logs[0].Logs)
+	s.Equal("s3", logs[1].Logs, "held back before the step, flushed before it starts")
+	s.Nil(logs[1].Step)
+	s.Equal("out ", logs[2].Logs)
+	s.Equal("err ", logs[3].Logs)
+	s.Equal("****\n", logs[4].Logs)
+	s.Equal("s3cr", logs[5].Logs)
+	s.Equal(step, logs[5].Step)
+	s.Equal(2, logs[5].Order)
+	s.Equal("stdout", logs[5].Stream)
+}
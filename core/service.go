@@ -15,11 +15,18 @@
 package core
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/fsouza/go-dockerclient"
 	"github.com/wercker/wercker/util"
 	"golang.org/x/net/context"
 )
 
+// ServiceLogsDir is the dir in the pipeline dir with the output of the
+// services that show their logs, in a <alias>.log file each
+const ServiceLogsDir = "services"
+
 // ServiceBox interface to services
 type ServiceBox interface {
 	Run(context.Context, *util.Environment, []string) (*docker.Container, error)
@@ -27,4 +34,56 @@ type ServiceBox interface {
 	GetID() string
 	GetName() string
 	GetServiceAlias() string
+	// DependsOn are the aliases of the services to wait for before this
+	// one starts
+	DependsOn() []string
+	// WaitUntilReady blocks until the healthcheck of the running service
+	// passes, right away if it doesn't have one
+	WaitUntilReady(context.Context) error
+}
+
+// SortServices orders services so each one comes after the services it
+// depends on, otherwise they keep their order
+func SortServices(services []ServiceBox) ([]ServiceBox, error) {
+	byAlias := map[string]ServiceBox{}
+	for _, service := range services {
+		byAlias[service.GetServiceAlias()] = service
+	}
+	for _, service := range services {
+		for _, dep := range service.DependsOn() {
+			if _, ok := byAlias[dep]; !ok {
+				return nil, fmt.Errorf("Service %s depends on %s, which isn't one of the services", service.GetServiceAlias(), dep)
+			}
+		}
+	}
+
+	sorted := []ServiceBox{}
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(service ServiceBox, path []string) error
+	visit = func(service ServiceBox, path []string) error {
+		alias := service.GetServiceAlias()
+		path = append(path, alias)
+		if done[alias] {
+			return nil
+		}
+		if visiting[alias] {
+			return fmt.Errorf("Services can't depend on each other: %s", strings.Join(path, " -> "))
+		}
+		visiting[alias] = true
+		for _, dep := range service.DependsOn() {
+			if err := visit(byAlias[dep], path); err != nil {
+				return err
+			}
+		}
+		done[alias] = true
+		sorted = append(sorted, service)
+		return nil
+	}
+	for _, service := range services {
+		if err := visit(service, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
 }
@@ -16,9 +16,11 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pborman/uuid"
@@ -65,6 +67,23 @@ type Transport interface {
 	Attach(context.Context, io.Reader, io.Writer, io.Writer) (context.Context, error)
 }
 
+// CommandTransport is a Transport that runs the commands itself rather than
+// writing them to a shell, giving every batch of commands its own exit code
+// and separate stdout and stderr. The environment and working dir carry
+// over from one batch to the next like they would in a shell.
+type CommandTransport interface {
+	Transport
+	Exec(ctx context.Context, commands []string, stdout, stderr io.Writer) (int, error)
+}
+
+const (
+	// AttachTransport sends the commands to a shell attached to the box,
+	// the default
+	AttachTransport = "attach"
+	// ExecTransport runs the commands with a CommandTransport
+	ExecTransport = "exec"
+)
+
 // Session is our way to interact with the docker container
 type Session struct {
 	options    *PipelineOptions
@@ -137,6 +156,15 @@ func (s *Session) Send(sessionCtx context.Context, forceHidden bool, commands ..
 		// Pass
 	}
 
+	// Like a shell would, finish these commands before any that are sent
+	// after them, only their exit code is ignored
+	if t, ok := s.transport.(CommandTransport); ok {
+		s.emitCommands(e, forceHidden, commands)
+		out := s.newExecOutput(e)
+		_, err := t.Exec(sessionCtx, commands, out.Stream("stdout"), out.Stream("stderr"))
+		return err
+	}
+
 	for i := range commands {
 		command := commands[i] + "\n"
 		select {
@@ -163,6 +191,15 @@ var randomSentinel = func() string {
 	return uuid.NewRandom().String()
 }
 
+var (
+	// ErrCommandTimeout is returned by SendChecked when a command didn't
+	// finish within the command-timeout
+	ErrCommandTimeout = errors.New("Command timed out")
+	// ErrNoResponse is returned by SendChecked when a command didn't write
+	// anything for longer than the no-response-timeout
+	ErrNoResponse = errors.New("Command timed out after no response")
+)
+
 // CommandResult exists so that we can make a channel of them
 type CommandResult struct {
 	exit int
@@ -241,10 +278,15 @@ func (s *Session) SendChecked(sessionCtx context.Context, commands ...string) (i
 	if err != nil {
 		return -1, []string{}, err
 	}
+	if t, ok := s.transport.(CommandTransport); ok {
+		return s.execChecked(sessionCtx, e, t, commands...)
+	}
 	recv := []string{}
 	sentinel := randomSentinel()
 
-	sendCtx, _ := context.WithTimeout(sessionCtx, time.Duration(s.options.CommandTimeout)*time.Millisecond)
+	commandTimeout := timeoutFromContext(sessionCtx, "CommandTimeout", s.options.CommandTimeout)
+	noResponse := timeoutFromContext(sessionCtx, "NoResponseTimeout", s.options.NoResponseTimeout)
+	sendCtx, _ := context.WithTimeout(sessionCtx, commandTimeout)
 
 	commandComplete := make(chan CommandResult)
 
@@ -285,9 +327,9 @@ func (s *Session) SendChecked(sessionCtx context.Context, commands ...string) (i
 			select {
 			case <-noResponseTimeout:
 				continue
-			case <-time.After(time.Duration(s.options.NoResponseTimeout) * time.Millisecond):
+			case <-time.After(noResponse):
 				stopReading <- struct{}{}
-				errChan <- fmt.Errorf("Command timed out after no response")
+				errChan <- ErrNoResponse
 				return
 			}
 		}
@@ -337,9 +379,117 @@ func (s *Session) SendChecked(sessionCtx context.Context, commands ...string) (i
 	r := <-commandComplete
 	// Pretty up the error messages
 	if r.err == context.DeadlineExceeded {
-		r.err = fmt.Errorf("Command timed out")
+		r.err = ErrCommandTimeout
 	} else if r.err == context.Canceled {
 		r.err = fmt.Errorf("Command cancelled due to error")
 	}
 	return r.exit, r.recv, r.err
 }
+
+// emitCommands emits commands as stdin like Send does when it writes them
+func (s *Session) emitCommands(e *NormalizedEmitter, forceHidden bool, commands []string) {
+	for _, command := range commands {
+		e.Emit(Logs, &LogsArgs{
+			Hidden: s.logsHidden || forceHidden,
+			Stream: "stdin",
+			Logs:   command + "\n",
+		})
+	}
+}
+
+// execChecked is SendChecked for a CommandTransport, which tells us the exit
+// code so there is no sentinel to look for
+func (s *Session) execChecked(sessionCtx context.Context, e *NormalizedEmitter, t CommandTransport, commands ...string) (int, []string, error) {
+	commandTimeout := timeoutFromContext(sessionCtx, "CommandTimeout", s.options.CommandTimeout)
+	noResponse := timeoutFromContext(sessionCtx, "NoResponseTimeout", s.options.NoResponseTimeout)
+	sendCtx, cancel := context.WithTimeout(sessionCtx, commandTimeout)
+	defer cancel()
+
+	s.emitCommands(e, false, commands)
+	out := s.newExecOutput(e)
+	commandComplete := make(chan CommandResult, 1)
+	go func() {
+		exit, err := t.Exec(sendCtx, commands, out.Stream("stdout"), out.Stream("stderr"))
+		commandComplete <- CommandResult{exit: exit, err: err}
+	}()
+
+	var r CommandResult
+	for done := false; !done; {
+		select {
+		case r = <-commandComplete:
+			if r.err == nil && r.exit != 0 {
+				r.err = fmt.Errorf("Command exited with exit code: %d", r.exit)
+			}
+			done = true
+		case <-out.activity:
+			// Reset the no-response timeout
+		case <-time.After(noResponse):
+			r = CommandResult{exit: -1, err: ErrNoResponse}
+			done = true
+		case <-sendCtx.Done():
+			r = CommandResult{exit: -1, err: sendCtx.Err()}
+			done = true
+		}
+	}
+
+	// Pretty up the error messages
+	if r.err == context.DeadlineExceeded {
+		r.err = ErrCommandTimeout
+	} else if r.err == context.Canceled {
+		r.err = fmt.Errorf("Command cancelled due to error")
+	}
+	return r.exit, out.Recv(), r.err
+}
+
+// execOutput emits what the commands run by a CommandTransport write and
+// keeps it for SendChecked
+type execOutput struct {
+	sync.Mutex
+	session  *Session
+	emitter  *NormalizedEmitter
+	recv     []string
+	activity chan struct{}
+}
+
+func (s *Session) newExecOutput(e *NormalizedEmitter) *execOutput {
+	return &execOutput{
+		session:  s,
+		emitter:  e,
+		recv:     []string{},
+		activity: make(chan struct{}, 1),
+	}
+}
+
+// Stream is the writer for the stdout or stderr of the commands
+func (o *execOutput) Stream(stream string) io.Writer {
+	return &execStream{output: o, stream: stream}
+}
+
+// Recv is what the commands wrote so far
+func (o *execOutput) Recv() []string {
+	o.Lock()
+	defer o.Unlock()
+	return append([]string{}, o.recv...)
+}
+
+type execStream struct {
+	output *execOutput
+	stream string
+}
+
+func (w *execStream) Write(p []byte) (int, error) {
+	o := w.output
+	o.Lock()
+	defer o.Unlock()
+	o.recv = append(o.recv, string(p))
+	o.emitter.Emit(Logs, &LogsArgs{
+		Hidden: o.session.logsHidden,
+		Stream: w.stream,
+		Logs:   string(p),
+	})
+	select {
+	case o.activity <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
@@ -19,6 +19,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 	"github.com/wercker/wercker/util"
@@ -274,3 +275,130 @@ func (s *SessionSuite) TestSmartSplitLines() {
 	uselessFound, _ := checkLine(uselessLines[0], sentinel)
 	s.Equal(false, uselessFound)
 }
+
+// FakeCommandTransport answers every Exec with the next of its responses
+type FakeCommandTransport struct {
+	FakeTransport
+	commands  [][]string
+	responses []fakeExecResponse
+}
+
+type fakeExecResponse struct {
+	exit   int
+	stdout string
+	stderr string
+	wait   time.Duration
+	err    error
+}
+
+func (t *FakeCommandTransport) Exec(ctx context.Context, commands []string, stdout, stderr io.Writer) (int, error) {
+	t.commands = append(t.commands, commands)
+	r := t.responses[0]
+	t.responses = t.responses[1:]
+	if r.stdout != "" {
+		stdout.Write([]byte(r.stdout))
+	}
+	if r.stderr != "" {
+		stderr.Write([]byte(r.stderr))
+	}
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case <-time.After(r.wait):
+	}
+	return r.exit, r.err
+}
+
+func FakeCommandSession(s *util.TestSuite, opts *PipelineOptions, responses ...fakeExecResponse) (context.Context, *Session, *FakeCommandTransport, *[]*LogsArgs) {
+	if opts == nil {
+		opts = fakeSessionOptions()
+	}
+	transport := &FakeCommandTransport{responses: responses}
+	e := NewNormalizedEmitter()
+	logs := []*LogsArgs{}
+	e.AddListener(Logs, func(args *LogsArgs) {
+		logs = append(logs, args)
+	})
+	session := NewSession(opts, transport)
+	sessionCtx, err := session.Attach(WithEmitter(context.Background(), e))
+	s.Nil(err)
+	return sessionCtx, session, transport, &logs
+}
+
+func (s *SessionSuite) TestSendCheckedExec() {
+	sessionCtx, session, transport, logs := FakeCommandSession(s.TestSuite, nil,
+		fakeExecResponse{exit: 0, stdout: "foo\n"},
+		fakeExecResponse{exit: 3, stdout: "bar\n", stderr: "baz\n"},
+	)
+
+	exit, recv, err := session.SendChecked(sessionCtx, "export FOO=foo", "echo $FOO")
+	s.Nil(err)
+	s.Equal(0, exit)
+	s.Equal([]string{"foo\n"}, recv)
+	s.Equal([][]string{{"export FOO=foo", "echo $FOO"}}, transport.commands)
+
+	exit, recv, err = session.SendChecked(sessionCtx, "lala")
+	s.NotNil(err)
+	s.Equal(3, exit)
+	s.Equal([]string{"bar\n", "baz\n"}, recv)
+
+	streams := []string{}
+	for _, args := range *logs {
+		streams = append(streams, args.Stream)
+	}
+	s.Equal([]string{"stdin", "stdin", "stdout", "stdin", "stdout", "stderr"}, streams)
+}
+
+func (s *SessionSuite) TestSendExec() {
+	sessionCtx, session, transport, logs := FakeCommandSession(s.TestSuite, nil,
+		fakeExecResponse{exit: 1, stdout: "started\n", wait: 50 * time.Millisecond},
+		fakeExecResponse{exit: 0, stdout: "foo\n"},
+		fakeExecResponse{exit: -1, err: fmt.Errorf("exec failed")},
+	)
+
+	// Send waits for its commands like the shell would, so they are done
+	// before the ones SendChecked sends next
+	err := session.Send(sessionCtx, false, "set +e", "./server &")
+	s.Nil(err)
+	exit, recv, err := session.SendChecked(sessionCtx, "echo foo")
+	s.Nil(err)
+	s.Equal(0, exit)
+	s.Equal([]string{"foo\n"}, recv)
+	s.Equal([][]string{{"set +e", "./server &"}, {"echo foo"}}, transport.commands)
+
+	streams := []string{}
+	for _, args := range *logs {
+		streams = append(streams, args.Stream)
+	}
+	s.Equal([]string{"stdin", "stdin", "stdout", "stdin", "stdout"}, streams)
+
+	err = session.Send(sessionCtx, false, "lala")
+	s.Error(err, "exec failed")
+}
+
+func (s *SessionSuite) TestSendCheckedExecCommandTimeout() {
+	opts := fakeSessionOptions()
+	opts.CommandTimeout = 10
+	sessionCtx, session, _, _ := FakeCommandSession(s.TestSuite, opts,
+		fakeExecResponse{exit: 0, stdout: "foo\n", wait: time.Second},
+	)
+
+	exit, recv, err := session.SendChecked(sessionCtx, "sleep 1")
+	s.Equal(ErrCommandTimeout, err)
+	s.Equal(-1, exit)
+	s.Equal(1, len(recv))
+}
+
+func (s *SessionSuite) TestSendCheckedExecNoResponseTimeout() {
+	opts := fakeSessionOptions()
+	opts.CommandTimeout = 1000
+	opts.NoResponseTimeout = 10
+	sessionCtx, session, _, _ := FakeCommandSession(s.TestSuite, opts,
+		fakeExecResponse{exit: 0, wait: time.Second},
+	)
+
+	exit, recv, err := session.SendChecked(sessionCtx, "sleep 1")
+	s.Equal(ErrNoResponse, err)
+	s.Equal(-1, exit)
+	s.Equal(0, len(recv))
+}
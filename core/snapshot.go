@@ -0,0 +1,232 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ResumeFailed is the --resume-from value to resume from the step that
+// failed in the last run
+const ResumeFailed = "failed"
+
+// StepSnapshot is the box committed after a step passed, together with the
+// environment the next step starts with
+type StepSnapshot struct {
+	Index   int        `json:"index"`
+	Step    string     `json:"step"`
+	Image   string     `json:"image"`
+	Env     [][]string `json:"env"`
+	Created time.Time  `json:"created"`
+}
+
+// SnapshotSet holds the snapshots of the last run of a pipeline, Index is
+// the position of a step in Steps.
+type SnapshotSet struct {
+	Pipeline   string          `json:"pipeline"`
+	RunID      string          `json:"runId"`
+	Steps      []string        `json:"steps"`
+	FailedStep int             `json:"failedStep"`
+	Snapshots  []*StepSnapshot `json:"snapshots"`
+	Updated    time.Time       `json:"updated"`
+}
+
+// NewSnapshotSet starts a set for a run of a pipeline with steps
+func NewSnapshotSet(pipeline, runID string, steps []string) *SnapshotSet {
+	return &SnapshotSet{
+		Pipeline:   pipeline,
+		RunID:      runID,
+		Steps:      steps,
+		FailedStep: -1,
+		Updated:    time.Now(),
+	}
+}
+
+// Add a snapshot, the ones from the same step or later are replaced and
+// returned so their images can be removed.
+func (s *SnapshotSet) Add(snapshot *StepSnapshot) []*StepSnapshot {
+	replaced := s.Truncate(snapshot.Index)
+	s.Snapshots = append(s.Snapshots, snapshot)
+	s.Updated = time.Now()
+	return replaced
+}
+
+// Truncate drops the snapshots from step index on and returns them
+func (s *SnapshotSet) Truncate(index int) []*StepSnapshot {
+	kept := []*StepSnapshot{}
+	dropped := []*StepSnapshot{}
+	for _, snapshot := range s.Snapshots {
+		if snapshot.Index < index {
+			kept = append(kept, snapshot)
+		} else {
+			dropped = append(dropped, snapshot)
+		}
+	}
+	s.Snapshots = kept
+	return dropped
+}
+
+// ResumePoint finds the latest snapshot before the step to resume from,
+// from is the name of a step or ResumeFailed. The run resumes with the step
+// after the snapshot.
+func (s *SnapshotSet) ResumePoint(from string) (*StepSnapshot, error) {
+	index := -1
+	if from == ResumeFailed {
+		if s.FailedStep < 0 {
+			return nil, fmt.Errorf("The last run of pipeline %s didn't fail, pick a step to resume from", s.Pipeline)
+		}
+		index = s.FailedStep
+	} else {
+		for i, name := range s.Steps {
+			if name != from {
+				continue
+			}
+			if index >= 0 {
+				return nil, fmt.Errorf("More than one step is called %s, give the steps a name to resume from them", from)
+			}
+			index = i
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("No step called %s in the last run of pipeline %s", from, s.Pipeline)
+		}
+	}
+
+	var latest *StepSnapshot
+	for _, snapshot := range s.Snapshots {
+		if snapshot.Index < index && (latest == nil || snapshot.Index > latest.Index) {
+			latest = snapshot
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("No snapshot to resume from before step %s", s.Steps[index])
+	}
+	return latest, nil
+}
+
+// CheckSteps makes sure the steps that went into snapshot are still the
+// same, steps are the names of the steps of the pipeline we are running.
+func (s *SnapshotSet) CheckSteps(snapshot *StepSnapshot, steps []string) error {
+	if snapshot.Index >= len(steps) || snapshot.Index >= len(s.Steps) {
+		return fmt.Errorf("The steps of pipeline %s changed since the snapshot was taken", s.Pipeline)
+	}
+	for i := 0; i <= snapshot.Index; i++ {
+		if steps[i] != s.Steps[i] {
+			return fmt.Errorf("The steps of pipeline %s changed since the snapshot was taken, expected step %d to be %s but it is %s", s.Pipeline, i, s.Steps[i], steps[i])
+		}
+	}
+	return nil
+}
+
+// SnapshotStore keeps the snapshot sets of a project as JSON files in the
+// working dir, one per pipeline.
+type SnapshotStore struct {
+	dir string
+}
+
+// NewSnapshotStore for the project we are running
+func NewSnapshotStore(options *PipelineOptions) *SnapshotStore {
+	return &SnapshotStore{dir: options.WorkingPath("snapshots", SnapshotName(options.ApplicationID))}
+}
+
+// SnapshotName makes s usable as a docker repository or tag
+func SnapshotName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// SnapshotRepository is the repository the snapshots of the project are
+// committed to
+func SnapshotRepository(options *PipelineOptions) string {
+	return fmt.Sprintf("wercker-snapshot-%s", SnapshotName(options.ApplicationID))
+}
+
+func (s *SnapshotStore) path(pipeline string) string {
+	return filepath.Join(s.dir, SnapshotName(pipeline)+".json")
+}
+
+// Load the set of pipeline, nil if there is none
+func (s *SnapshotStore) Load(pipeline string) (*SnapshotSet, error) {
+	b, err := ioutil.ReadFile(s.path(pipeline))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	set := &SnapshotSet{}
+	if err := json.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Save the set, replacing the one of the same pipeline
+func (s *SnapshotStore) Save(set *SnapshotSet) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(set.Pipeline), b, 0644)
+}
+
+// Remove the set of pipeline
+func (s *SnapshotStore) Remove(pipeline string) error {
+	err := os.Remove(s.path(pipeline))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Expired returns the sets that haven't been updated since before cutoff
+func (s *SnapshotStore) Expired(cutoff time.Time) ([]*SnapshotSet, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	expired := []*SnapshotSet{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		set, err := s.Load(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if set != nil && set.Updated.Before(cutoff) {
+			expired = append(expired, set)
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,132 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type SnapshotSuite struct {
+	*util.TestSuite
+}
+
+func TestSnapshotSuite(t *testing.T) {
+	suiteTester := &SnapshotSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func testSnapshotSet() *SnapshotSet {
+	set := NewSnapshotSet("build", "run1", []string{"wercker-init", "setup", "test", "test", "package"})
+	set.Add(&StepSnapshot{Index: 0, Image: "init"})
+	set.Add(&StepSnapshot{Index: 1, Image: "setup"})
+	set.Add(&StepSnapshot{Index: 2, Image: "test"})
+	return set
+}
+
+func (s *SnapshotSuite) TestAddReplaces() {
+	set := testSnapshotSet()
+	replaced := set.Add(&StepSnapshot{Index: 1, Image: "setup2"})
+	s.Len(replaced, 2)
+	s.Equal("setup", replaced[0].Image)
+	s.Equal("test", replaced[1].Image)
+	s.Len(set.Snapshots, 2)
+	s.Equal("setup2", set.Snapshots[1].Image)
+}
+
+func (s *SnapshotSuite) TestResumePoint() {
+	set := testSnapshotSet()
+
+	snapshot, err := set.ResumePoint("package")
+	s.Require().NoError(err)
+	s.Equal("test", snapshot.Image)
+
+	snapshot, err = set.ResumePoint("setup")
+	s.Require().NoError(err)
+	s.Equal("init", snapshot.Image)
+
+	_, err = set.ResumePoint(ResumeFailed)
+	s.Error(err)
+	set.FailedStep = 2
+	snapshot, err = set.ResumePoint(ResumeFailed)
+	s.Require().NoError(err)
+	s.Equal("setup", snapshot.Image)
+
+	_, err = set.ResumePoint("test")
+	s.Error(err, "ambiguous step name")
+	_, err = set.ResumePoint("deploy")
+	s.Error(err)
+	_, err = set.ResumePoint("wercker-init")
+	s.Error(err, "nothing before the first step")
+}
+
+func (s *SnapshotSuite) TestCheckSteps() {
+	set := testSnapshotSet()
+	snapshot := set.Snapshots[1]
+	s.NoError(set.CheckSteps(snapshot, []string{"wercker-init", "setup", "lint"}))
+	s.Error(set.CheckSteps(snapshot, []string{"wercker-init", "install", "test"}))
+	s.Error(set.CheckSteps(snapshot, []string{"wercker-init"}))
+}
+
+func (s *SnapshotSuite) TestStore() {
+	options := &PipelineOptions{
+		GlobalOptions: &GlobalOptions{},
+		ApplicationID: "Wercker/My App",
+		WorkingDir:    s.WorkingDir(),
+	}
+	store := NewSnapshotStore(options)
+	s.Equal(filepath.Join(s.WorkingDir(), "snapshots", "wercker-my-app"), store.dir)
+	s.Equal("wercker-snapshot-wercker-my-app", SnapshotRepository(options))
+
+	set, err := store.Load("build")
+	s.Require().NoError(err)
+	s.Nil(set)
+
+	old := testSnapshotSet()
+	old.Pipeline = "deploy"
+	old.Updated = time.Now().Add(-time.Hour)
+	s.Require().NoError(store.Save(old))
+	s.Require().NoError(store.Save(testSnapshotSet()))
+
+	set, err = store.Load("build")
+	s.Require().NoError(err)
+	s.Equal("run1", set.RunID)
+	s.Equal(-1, set.FailedStep)
+	s.Len(set.Snapshots, 3)
+
+	expired, err := store.Expired(time.Now().Add(-time.Minute))
+	s.Require().NoError(err)
+	s.Require().Len(expired, 1)
+	s.Equal("deploy", expired[0].Pipeline)
+
+	s.Require().NoError(store.Remove("deploy"))
+	s.NoError(store.Remove("deploy"))
+	expired, err = store.Expired(time.Now())
+	s.Require().NoError(err)
+	s.Len(expired, 1)
+}
+
+func (s *SnapshotSuite) TestResumeImpliesSnapshotSteps() {
+	options := DefaultTestPipelineOptions(s.TestSuite, nil)
+	s.False(options.SnapshotSteps)
+
+	options = DefaultTestPipelineOptions(s.TestSuite, map[string]interface{}{"resume-from": "failed"})
+	s.True(options.SnapshotSteps)
+	s.Equal("failed", options.ResumeFrom)
+}
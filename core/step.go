@@ -15,6 +15,7 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -91,6 +92,11 @@ type Step interface {
 	Version() string
 	ShouldSyncEnv() bool
 	Checkpoint() string
+	When() string
+	Retry() *RetryConfig
+	Timeout() *TimeoutConfig
+	CacheKey() *CacheKeyConfig
+	Outputs() []string
 
 	// Actual methods
 	Fetch() (string, error)
@@ -116,6 +122,11 @@ type BaseStepOptions struct {
 	Version     string
 	Cwd         string
 	Checkpoint  string
+	When        string
+	Retry       *RetryConfig
+	Timeout     *TimeoutConfig
+	CacheKey    *CacheKeyConfig
+	Outputs     []string
 }
 
 // BaseStep type for extending
@@ -129,6 +140,11 @@ type BaseStep struct {
 	version     string
 	cwd         string
 	checkpoint  string
+	when        string
+	retry       *RetryConfig
+	timeout     *TimeoutConfig
+	cacheKey    *CacheKeyConfig
+	outputs     []string
 }
 
 func NewBaseStep(args BaseStepOptions) *BaseStep {
@@ -142,6 +158,11 @@ func NewBaseStep(args BaseStepOptions) *BaseStep {
 		version:     args.Version,
 		cwd:         args.Cwd,
 		checkpoint:  args.Checkpoint,
+		when:        args.When,
+		retry:       args.Retry,
+		timeout:     args.Timeout,
+		cacheKey:    args.CacheKey,
+		outputs:     args.Outputs,
 	}
 }
 
@@ -190,6 +211,31 @@ func (s *BaseStep) Checkpoint() string {
 	return s.checkpoint
 }
 
+// When getter, the condition under which the step should run
+func (s *BaseStep) When() string {
+	return s.when
+}
+
+// Retry getter, nil if the step isn't retried
+func (s *BaseStep) Retry() *RetryConfig {
+	return s.retry
+}
+
+// Timeout getter, nil if the step uses the global timeouts
+func (s *BaseStep) Timeout() *TimeoutConfig {
+	return s.timeout
+}
+
+// CacheKey getter, nil if the step always runs
+func (s *BaseStep) CacheKey() *CacheKeyConfig {
+	return s.cacheKey
+}
+
+// Outputs getter, the paths restored when the step is cached
+func (s *BaseStep) Outputs() []string {
+	return s.outputs
+}
+
 func (s *BaseStep) Clean() {
 
 }
@@ -197,11 +243,14 @@ func (s *BaseStep) Clean() {
 // ExternalStep is the holder of the Step methods.
 type ExternalStep struct {
 	*BaseStep
-	url      string
-	data     map[string]string
-	stepDesc *StepDesc
-	logger   *util.LogEntry
-	options  *PipelineOptions
+	url        string
+	data       map[string]string
+	properties map[string]interface{}
+	stepDesc   *StepDesc
+	logger     *util.LogEntry
+	options    *PipelineOptions
+	// pinned is the entry of the step in wercker.lock, see pin
+	pinned *LockedStep
 }
 
 // NewStep sets up the basic parts of a Step.
@@ -221,6 +270,15 @@ func NewStep(stepConfig *StepConfig, options *PipelineOptions) (*ExternalStep, e
 	stepID := stepConfig.ID
 	data := stepConfig.Data
 
+	// Steps that didn't come from a wercker.yml only have the string form
+	properties := stepConfig.Properties
+	if properties == nil {
+		properties = make(map[string]interface{}, len(data))
+		for k, v := range data {
+			properties[k] = v
+		}
+	}
+
 	// Check for urls
 	_, err := fmt.Sscanf(stepID, "%s %q", &identifier, &url)
 	if err != nil {
@@ -278,11 +336,17 @@ func NewStep(stepConfig *StepConfig, options *PipelineOptions) (*ExternalStep, e
 			version:     version,
 			cwd:         stepConfig.Cwd,
 			checkpoint:  stepConfig.Checkpoint,
+			when:        stepConfig.When,
+			retry:       stepConfig.Retry,
+			timeout:     stepConfig.Timeout,
+			cacheKey:    stepConfig.CacheKey,
+			outputs:     stepConfig.Outputs,
 		},
-		options: options,
-		data:    data,
-		url:     url,
-		logger:  logger,
+		options:    options,
+		data:       data,
+		properties: properties,
+		url:        url,
+		logger:     logger,
 	}, nil
 }
 
@@ -348,6 +412,11 @@ func (s *ExternalStep) Fetch() (string, error) {
 		return s.FetchScript()
 	}
 
+	pinned, err := s.pin()
+	if err != nil {
+		return "", err
+	}
+
 	stepPath := filepath.Join(s.options.StepPath(), s.CachedName())
 	stepExists, err := util.Exists(stepPath)
 	if err != nil {
@@ -358,23 +427,8 @@ func (s *ExternalStep) Fetch() (string, error) {
 		// If we don't have a url already
 		if s.url == "" {
 			// Grab the info about the step from the api
-
-			// TODO(termie): probably don't need these in global options?
-			var client api.StepRegistry
-			if s.options.GlobalOptions.StepRegistryURL == "" {
-				apiOptions := api.APIOptions{
-					BaseURL: s.options.GlobalOptions.BaseURL,
-				}
-				// NOTE(kokaz): this client doesn't contain any auth token
-				client = api.NewAPIClient(&apiOptions)
-			} else {
-				client = api.NewWerckerStepRegistry(s.options.GlobalOptions.StepRegistryURL)
-			}
-			stepInfo, err := client.GetStepVersion(s.Owner(), s.Name(), s.Version())
+			stepInfo, err := s.getStepVersion()
 			if err != nil {
-				if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 404 {
-					return "", fmt.Errorf("The step \"%s\" was not found", s.ID())
-				}
 				return "", err
 			}
 
@@ -402,9 +456,22 @@ func (s *ExternalStep) Fetch() (string, error) {
 			if err != nil {
 				return "", err
 			}
+			var body io.Reader = resp.Body
+
+			// A locked step has to match the checksum in wercker.lock
+			if pinned != nil {
+				tarball, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					return "", err
+				}
+				if err := pinned.Verify(tarball); err != nil {
+					return "", err
+				}
+				body = bytes.NewReader(tarball)
+			}
 
 			// Assuming we have a gzip'd tarball at this point
-			err = util.Untargzip(stepPath, resp.Body)
+			err = util.Untargzip(stepPath, body)
 			if err != nil {
 				return "", err
 			}
@@ -430,6 +497,121 @@ func (s *ExternalStep) Fetch() (string, error) {
 	return hostStepPath, nil
 }
 
+// getStepVersion looks the step up in the step registry
+func (s *ExternalStep) getStepVersion() (*api.APIStepVersion, error) {
+	// TODO(termie): probably don't need these in global options?
+	var client api.StepRegistry
+	if s.options.GlobalOptions.StepRegistryURL == "" {
+		apiOptions := api.APIOptions{
+			BaseURL: s.options.GlobalOptions.BaseURL,
+		}
+		// NOTE(kokaz): this client doesn't contain any auth token
+		client = api.NewAPIClient(&apiOptions)
+	} else {
+		client = api.NewWerckerStepRegistry(s.options.GlobalOptions.StepRegistryURL)
+	}
+	stepInfo, err := client.GetStepVersion(s.Owner(), s.Name(), s.Version())
+	if err != nil {
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 404 {
+			return nil, fmt.Errorf("The step \"%s\" was not found", s.ID())
+		}
+		return nil, err
+	}
+	return stepInfo, nil
+}
+
+// Resolve returns the version and tarball url the step resolves to without
+// fetching it. The step.yml is loaded if the step is in the step cache
+// already, so InitEnv knows about its defaults.
+func (s *ExternalStep) Resolve() (string, string, error) {
+	if s.IsScript() {
+		return s.version, "", nil
+	}
+
+	if _, err := s.pin(); err != nil {
+		return "", "", err
+	}
+
+	desc, err := ReadStepDesc(filepath.Join(s.options.StepPath(), s.CachedName(), "step.yml"))
+	if err == nil {
+		s.stepDesc = desc
+	}
+
+	if s.url != "" {
+		return s.version, s.url, nil
+	}
+	stepInfo, err := s.getStepVersion()
+	if err != nil {
+		return "", "", err
+	}
+	version := stepInfo.Version
+	if version == "" {
+		version = s.version
+	}
+	return version, stepInfo.TarballURL, nil
+}
+
+// LockName is the step in wercker.lock, its id with the version in the
+// wercker.yml if it has one
+func (s *ExternalStep) LockName() string {
+	name := fmt.Sprintf("%s/%s", s.owner, s.name)
+	if s.version != "*" {
+		name = fmt.Sprintf("%s@%s", name, s.version)
+	}
+	return name
+}
+
+// Lockable tells whether wercker.lock can pin the step, script steps and
+// steps with a url of their own can't be
+func (s *ExternalStep) Lockable() bool {
+	return !s.IsScript() && (s.url == "" || s.pinned != nil)
+}
+
+// pin makes the step use the version and tarball wercker.lock has for it.
+// Steps with a url of their own aren't locked, neither is anything without
+// a wercker.lock.
+func (s *ExternalStep) pin() (*LockedStep, error) {
+	if s.pinned != nil || s.url != "" || s.options.Lockfile == nil {
+		return s.pinned, nil
+	}
+	name := s.LockName()
+	locked, ok := s.options.Lockfile.Steps[name]
+	if !ok {
+		if s.options.FrozenLockfile {
+			return nil, fmt.Errorf("The step %s isn't in %s, run wercker lock to add it", name, LockfileName)
+		}
+		s.logger.Warnln("The step", name, "isn't in", LockfileName+", run wercker lock to pin it")
+		return nil, nil
+	}
+	s.pinned = locked
+	s.version = locked.Version
+	s.url = locked.URL
+	return locked, nil
+}
+
+// Data is the data of the step from the wercker.yml
+func (s *ExternalStep) Data() map[string]string {
+	return s.data
+}
+
+// InterpolateOutputs replaces the references to the outputs of earlier
+// steps in the data of the step, a script step gets its run.sh rewritten.
+func (s *ExternalStep) InterpolateOutputs(outputs *StepOutputs) error {
+	data, properties, err := outputs.InterpolateData(s.data, s.properties, s.IsScript())
+	if err != nil {
+		return fmt.Errorf("Invalid data for step %s, %s", s.DisplayName(), err)
+	}
+	if data == nil {
+		return nil
+	}
+	s.data, s.properties = data, properties
+	if s.IsScript() {
+		_, err := s.FetchScript()
+		return err
+	}
+	return nil
+}
+
 // SetupGuest ensures that the guest is ready to run a Step.
 func (s *ExternalStep) SetupGuest(sessionCtx context.Context, sess *Session) error {
 	defer s.LocalSymlink()
@@ -499,17 +681,20 @@ func (s *ExternalStep) InitEnv(env *util.Environment) error {
 		[]string{"WERCKER_STEP_NAME", s.name},
 		[]string{"WERCKER_REPORT_NUMBERS_FILE", s.ReportPath("numbers.ini")},
 		[]string{"WERCKER_REPORT_MESSAGE_FILE", s.ReportPath("message.txt")},
+		[]string{"WERCKER_REPORT_OUTPUTS_FILE", s.ReportPath(StepOutputsFile)},
 		[]string{"WERCKER_REPORT_ARTIFACTS_DIR", s.ReportPath("artifacts")},
 	}
 	s.Env().Update(a)
 
+	if err := s.stepDesc.ValidateProperties(s.properties); err != nil {
+		return err
+	}
+
 	defaults := s.stepDesc.Defaults()
 
 	for k, defaultValue := range defaults {
 		value, ok := s.data[k]
-		key := fmt.Sprintf("WERCKER_%s_%s", s.name, k)
-		key = strings.Replace(key, "-", "_", -1)
-		key = strings.ToUpper(key)
+		key := PropertyEnvKey(s.name, k)
 		if !ok {
 			s.Env().Add(key, defaultValue)
 		} else {
@@ -521,9 +706,11 @@ func (s *ExternalStep) InitEnv(env *util.Environment) error {
 		if k == "code" || k == "name" {
 			continue
 		}
-		key := fmt.Sprintf("WERCKER_%s_%s", s.name, k)
-		key = strings.Replace(key, "-", "_", -1)
-		key = strings.ToUpper(key)
+		key := PropertyEnvKey(s.name, k)
+		if property, ok := s.properties[k]; ok && isStructuredProperty(property) {
+			s.Env().Update(PropertyEnv(key, property))
+			continue
+		}
 		s.Env().Add(key, value)
 	}
 
@@ -0,0 +1,251 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/wercker/wercker/util"
+)
+
+// CacheKeyConfig is the files and env vars that decide whether a step can be
+// skipped and its outputs restored from the last run with the same inputs
+type CacheKeyConfig struct {
+	Files []string
+	Env   []string
+}
+
+// ParseCacheKey reads a CacheKeyConfig from the value of the cache-key
+// property
+func ParseCacheKey(value interface{}) (*CacheKeyConfig, error) {
+	cacheKey := &CacheKeyConfig{}
+	switch v := value.(type) {
+	case string, []interface{}:
+		files, err := parseStringList("cache-key", v)
+		if err != nil {
+			return nil, err
+		}
+		cacheKey.Files = files
+	case map[string]interface{}:
+		for key, item := range v {
+			var err error
+			switch key {
+			case "files":
+				cacheKey.Files, err = parseStringList("cache-key.files", item)
+			case "env":
+				cacheKey.Env, err = parseStringList("cache-key.env", item)
+			default:
+				return nil, fmt.Errorf("Unknown cache-key setting %s, expected files or env", key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cache-key should be a list of files or a map, got %v", value)
+	}
+
+	if len(cacheKey.Files) == 0 && len(cacheKey.Env) == 0 {
+		return nil, fmt.Errorf("cache-key should have files or env")
+	}
+	return cacheKey, nil
+}
+
+// ParseOutputs reads the paths of the outputs property
+func ParseOutputs(value interface{}) ([]string, error) {
+	return parseStringList("outputs", value)
+}
+
+// parseStringList reads a string or a list of strings
+func parseStringList(name string, value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	items := []string{}
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("%s should be a list of strings, got %v", name, value)
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+// dataStep is a step that has data to put in its cache key
+type dataStep interface {
+	Data() map[string]string
+}
+
+// StepCacheKey hashes what decides the outputs of step: the step itself
+// with its data and outputs, the contents of the files matching its
+// cache-key in dir and the values of its cache-key env vars in env. Empty
+// if the step doesn't have a cache-key.
+func StepCacheKey(step Step, dir string, env *util.Environment) (string, error) {
+	cacheKey := step.CacheKey()
+	if cacheKey == nil {
+		return "", nil
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "step %s %s %s\n", step.ID(), step.Version(), step.Cwd())
+	if s, ok := step.(dataStep); ok {
+		data := s.Data()
+		keys := []string{}
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "data %s=%q\n", k, data[k])
+		}
+	}
+	for _, output := range step.Outputs() {
+		fmt.Fprintf(h, "output %s\n", output)
+	}
+
+	files := []string{}
+	for _, pattern := range cacheKey.Files {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return "", fmt.Errorf("Invalid cache-key pattern %s: %s", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	seen := map[string]bool{}
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		if err := hashFile(h, dir, file); err != nil {
+			return "", err
+		}
+	}
+
+	for _, name := range cacheKey.Env {
+		fmt.Fprintf(h, "env %s=%q\n", name, env.GetInclHidden(name))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile adds the path of file relative to dir and its contents to h,
+// directories add every file in them
+func hashFile(h io.Writer, dir, file string) error {
+	return filepath.Walk(file, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(h, "file %s %d\n", filepath.ToSlash(rel), info.Size())
+		_, err = io.Copy(h, f)
+		return err
+	})
+}
+
+// StepCacheEntry is what is stored for a cache key, Outputs are the paths
+// in the container that were collected and Values the key/value outputs
+// the step wrote, see StepOutputsFile.
+type StepCacheEntry struct {
+	Key     string            `json:"key"`
+	Step    string            `json:"step"`
+	Outputs []string          `json:"outputs"`
+	Values  map[string]string `json:"values,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+// StepCacheStore keeps the outputs of steps in the working dir, a directory
+// per cache key with an entry.json and a tarball per output.
+type StepCacheStore struct {
+	dir string
+}
+
+// NewStepCacheStore for the project we are running
+func NewStepCacheStore(options *PipelineOptions) *StepCacheStore {
+	return &StepCacheStore{dir: options.WorkingPath("step-cache", SnapshotName(options.ApplicationID))}
+}
+
+func (s *StepCacheStore) path(key string) string {
+	return filepath.Join(s.dir, key)
+}
+
+// OutputPath is the tarball of output i in dir, which is either a
+// directory from TempDir or where the entry of a cache key is stored
+func (s *StepCacheStore) OutputPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("output-%d.tar", i))
+}
+
+// Load the entry for key and the directory it is stored in, nil if there
+// is none
+func (s *StepCacheStore) Load(key string) (*StepCacheEntry, string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.path(key), "entry.json"))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	entry := &StepCacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, "", err
+	}
+	return entry, s.path(key), nil
+}
+
+// TempDir to collect the outputs of a step in before they are saved
+func (s *StepCacheStore) TempDir() (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(s.dir, "tmp-")
+}
+
+// Save entry together with the outputs collected in dir, replacing what was
+// stored for the same key
+func (s *StepCacheStore) Save(entry *StepCacheEntry, dir string) error {
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "entry.json"), b, 0644); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.path(entry.Key)); err != nil {
+		return err
+	}
+	return os.Rename(dir, s.path(entry.Key))
+}
@@ -0,0 +1,151 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type StepCacheSuite struct {
+	*util.TestSuite
+}
+
+func TestStepCacheSuite(t *testing.T) {
+	suiteTester := &StepCacheSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *StepCacheSuite) TestParseCacheKey() {
+	cacheKey, err := ParseCacheKey([]interface{}{"package.json", "yarn.lock"})
+	s.Require().NoError(err)
+	s.Equal(&CacheKeyConfig{Files: []string{"package.json", "yarn.lock"}}, cacheKey)
+
+	cacheKey, err = ParseCacheKey(map[string]interface{}{
+		"files": "go.sum",
+		"env":   []interface{}{"GO_VERSION"},
+	})
+	s.Require().NoError(err)
+	s.Equal(&CacheKeyConfig{Files: []string{"go.sum"}, Env: []string{"GO_VERSION"}}, cacheKey)
+
+	invalid := []interface{}{
+		3,
+		[]interface{}{},
+		[]interface{}{"go.sum", 2},
+		map[string]interface{}{},
+		map[string]interface{}{"paths": []interface{}{"go.sum"}},
+	}
+	for _, value := range invalid {
+		_, err := ParseCacheKey(value)
+		s.Error(err, "%v", value)
+	}
+}
+
+func (s *StepCacheSuite) writeFile(dir, name, content string) {
+	p := filepath.Join(dir, name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(p), 0755))
+	s.Require().NoError(ioutil.WriteFile(p, []byte(content), 0644))
+}
+
+func (s *StepCacheSuite) TestStepCacheKey() {
+	dir := s.WorkingDir()
+	s.writeFile(dir, "package.json", `{"name": "app"}`)
+	s.writeFile(dir, "vendor/a/a.go", "package a")
+	options := &PipelineOptions{GlobalOptions: &GlobalOptions{}}
+	env := util.NewEnvironment("NODE_VERSION=8")
+	newStep := func(code string, cacheKey *CacheKeyConfig) Step {
+		step, err := NewStep(&StepConfig{
+			ID:       "script",
+			Data:     map[string]string{"code": code},
+			CacheKey: cacheKey,
+			Outputs:  []string{"node_modules"},
+		}, options)
+		s.Require().NoError(err)
+		return step
+	}
+	cacheKey := &CacheKeyConfig{Files: []string{"*.json", "vendor"}, Env: []string{"NODE_VERSION"}}
+
+	key, err := StepCacheKey(newStep("npm install", nil), dir, env)
+	s.Require().NoError(err)
+	s.Equal("", key)
+
+	key, err = StepCacheKey(newStep("npm install", cacheKey), dir, env)
+	s.Require().NoError(err)
+	s.Len(key, 64)
+	same, err := StepCacheKey(newStep("npm install", cacheKey), dir, env)
+	s.Require().NoError(err)
+	s.Equal(key, same)
+
+	changed := []func() (string, error){
+		func() (string, error) { return StepCacheKey(newStep("npm ci", cacheKey), dir, env) },
+		func() (string, error) {
+			return StepCacheKey(newStep("npm install", cacheKey), dir, util.NewEnvironment("NODE_VERSION=10"))
+		},
+		func() (string, error) {
+			s.writeFile(dir, "vendor/a/a.go", "package b")
+			return StepCacheKey(newStep("npm install", cacheKey), dir, env)
+		},
+		func() (string, error) {
+			s.writeFile(dir, "other.json", "{}")
+			return StepCacheKey(newStep("npm install", cacheKey), dir, env)
+		},
+	}
+	for i, f := range changed {
+		other, err := f()
+		s.Require().NoError(err)
+		s.NotEqual(key, other, "change %d", i)
+		key = other
+	}
+}
+
+func (s *StepCacheSuite) TestStore() {
+	options := &PipelineOptions{
+		GlobalOptions: &GlobalOptions{},
+		ApplicationID: "wercker/app",
+		WorkingDir:    s.WorkingDir(),
+	}
+	store := NewStepCacheStore(options)
+	s.Equal(filepath.Join(s.WorkingDir(), "step-cache", "wercker-app"), store.dir)
+
+	entry, _, err := store.Load("abc")
+	s.Require().NoError(err)
+	s.Nil(entry)
+
+	for _, content := range []string{"old", "new"} {
+		dir, err := store.TempDir()
+		s.Require().NoError(err)
+		s.Require().NoError(ioutil.WriteFile(store.OutputPath(dir, 0), []byte(content), 0644))
+		s.Require().NoError(store.Save(&StepCacheEntry{
+			Key:     "abc",
+			Step:    "npm install",
+			Outputs: []string{"/pipeline/source/node_modules"},
+			Created: time.Now(),
+		}, dir))
+	}
+
+	entry, dir, err := store.Load("abc")
+	s.Require().NoError(err)
+	s.Equal("npm install", entry.Step)
+	s.Equal([]string{"/pipeline/source/node_modules"}, entry.Outputs)
+	b, err := ioutil.ReadFile(store.OutputPath(dir, 0))
+	s.Require().NoError(err)
+	s.Equal("new", string(b))
+}
@@ -0,0 +1,75 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TimeoutConfig is the timeout and no-response-timeout in the `wercker:`
+// settings of a step, either is zero if the step uses the global setting
+type TimeoutConfig struct {
+	Step       time.Duration
+	NoResponse time.Duration
+}
+
+// ParseTimeout reads the `timeout:` or `no-response-timeout:` of a step or
+// pipeline, either a number of minutes like the global command-timeout or
+// a duration like 90s
+func ParseTimeout(value interface{}) (time.Duration, error) {
+	var timeout time.Duration
+	switch v := value.(type) {
+	case int:
+		timeout = time.Duration(v) * time.Minute
+	case float64:
+		timeout = time.Duration(v * float64(time.Minute))
+	case string:
+		var err error
+		timeout, err = time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("timeout should be a number of minutes or a duration like 90s, got %s", v)
+		}
+	default:
+		return 0, fmt.Errorf("timeout should be a number of minutes or a duration like 90s, got %v", value)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout should be positive, got %s", timeout)
+	}
+	return timeout, nil
+}
+
+// WithCommandTimeout overrides the command-timeout for the commands sent
+// with ctx, see Session.SendChecked
+func WithCommandTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, "CommandTimeout", timeout)
+}
+
+// WithNoResponseTimeout overrides the no-response-timeout for the commands
+// sent with ctx, see Session.SendChecked
+func WithNoResponseTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, "NoResponseTimeout", timeout)
+}
+
+// timeoutFromContext is the timeout set on ctx under key or fallback, in
+// milliseconds like the options, if there isn't one
+func timeoutFromContext(ctx context.Context, key string, fallback int) time.Duration {
+	if timeout, ok := ctx.Value(key).(time.Duration); ok {
+		return timeout
+	}
+	return time.Duration(fallback) * time.Millisecond
+}
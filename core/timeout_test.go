@@ -0,0 +1,62 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+type TimeoutSuite struct {
+	*util.TestSuite
+}
+
+func TestTimeoutSuite(t *testing.T) {
+	suiteTester := &TimeoutSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *TimeoutSuite) TestParseTimeout() {
+	valid := map[interface{}]time.Duration{
+		60:      time.Hour,
+		0.5:     30 * time.Second,
+		"90s":   90 * time.Second,
+		"1h30m": 90 * time.Minute,
+	}
+	for value, expected := range valid {
+		timeout, err := ParseTimeout(value)
+		s.NoError(err, "%v", value)
+		s.Equal(expected, timeout, "%v", value)
+	}
+
+	for _, value := range []interface{}{0, -5, "-1m", "soon", true} {
+		_, err := ParseTimeout(value)
+		s.Error(err, "%v", value)
+	}
+}
+
+func (s *TimeoutSuite) TestTimeoutFromContext() {
+	ctx := context.Background()
+	s.Equal(25*time.Minute, timeoutFromContext(ctx, "CommandTimeout", 25*60*1000))
+
+	ctx = WithCommandTimeout(ctx, time.Hour)
+	ctx = WithNoResponseTimeout(ctx, 2*time.Minute)
+	s.Equal(time.Hour, timeoutFromContext(ctx, "CommandTimeout", 25*60*1000))
+	s.Equal(2*time.Minute, timeoutFromContext(ctx, "NoResponseTimeout", 5*60*1000))
+}
@@ -0,0 +1,337 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wercker/wercker/util"
+)
+
+// WhenExpression is a parsed `when:` condition on a step. The language is
+// deliberately small, everything is a string and the operators are:
+//
+//   VAR                      value of VAR in the pipeline environment ($VAR also works)
+//   "text" or 'text'         string literal
+//   a == b, a != b           string comparison
+//   a =~ "re", a !~ "re"     regular expression match
+//   !a, a && b, a || b       boolean logic, grouped with ( )
+//
+// A value is true unless it is empty, "false" or "0". For example:
+//
+//   WERCKER_GIT_BRANCH == "master" && WERCKER_RESULT == "failed"
+type WhenExpression struct {
+	source string
+	root   whenNode
+}
+
+// ParseWhen parses a `when:` condition
+func ParseWhen(s string) (*WhenExpression, error) {
+	p := &whenParser{source: s}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+	}
+	return &WhenExpression{source: s, root: root}, nil
+}
+
+// EvalWhen parses s and evaluates it against env
+func EvalWhen(s string, env *util.Environment) (bool, error) {
+	w, err := ParseWhen(s)
+	if err != nil {
+		return false, err
+	}
+	return w.Eval(env), nil
+}
+
+// Eval evaluates the condition against env, hidden values are included
+func (w *WhenExpression) Eval(env *util.Environment) bool {
+	return truthy(w.root.eval(env))
+}
+
+func (w *WhenExpression) String() string {
+	return w.source
+}
+
+func truthy(s string) bool {
+	return s != "" && s != "false" && s != "0"
+}
+
+func fromBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+type whenNode interface {
+	eval(env *util.Environment) string
+}
+
+type whenLiteral string
+
+func (n whenLiteral) eval(env *util.Environment) string {
+	return string(n)
+}
+
+type whenVar string
+
+func (n whenVar) eval(env *util.Environment) string {
+	if env == nil {
+		return ""
+	}
+	return env.GetInclHidden(string(n))
+}
+
+type whenNot struct {
+	node whenNode
+}
+
+func (n *whenNot) eval(env *util.Environment) string {
+	return fromBool(!truthy(n.node.eval(env)))
+}
+
+type whenBinary struct {
+	op          string
+	left, right whenNode
+	re          *regexp.Regexp
+}
+
+func (n *whenBinary) eval(env *util.Environment) string {
+	switch n.op {
+	case "&&":
+		return fromBool(truthy(n.left.eval(env)) && truthy(n.right.eval(env)))
+	case "||":
+		return fromBool(truthy(n.left.eval(env)) || truthy(n.right.eval(env)))
+	case "==":
+		return fromBool(n.left.eval(env) == n.right.eval(env))
+	case "!=":
+		return fromBool(n.left.eval(env) != n.right.eval(env))
+	case "=~":
+		return fromBool(n.re.MatchString(n.left.eval(env)))
+	case "!~":
+		return fromBool(!n.re.MatchString(n.left.eval(env)))
+	}
+	return "false"
+}
+
+type whenToken struct {
+	kind string // "ident", "string" or "op"
+	text string
+	pos  int
+}
+
+type whenParser struct {
+	source string
+	tokens []*whenToken
+	cur    int
+}
+
+func isIdentChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+func (p *whenParser) tokenize() error {
+	s := p.source
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && c == '"' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			p.tokens = append(p.tokens, &whenToken{kind: "string", text: b.String(), pos: start})
+		case c == '$' || isIdentChar(c, true):
+			start := i
+			if c == '$' {
+				i++
+			}
+			braced := i < len(s) && s[i] == '{'
+			if braced {
+				i++
+			}
+			nameStart := i
+			for i < len(s) && isIdentChar(s[i], i == nameStart) {
+				i++
+			}
+			name := s[nameStart:i]
+			if braced {
+				if i >= len(s) || s[i] != '}' {
+					return fmt.Errorf("unterminated ${ at position %d", start+1)
+				}
+				i++
+			}
+			if name == "" {
+				return fmt.Errorf("expected a variable name at position %d", start+1)
+			}
+			p.tokens = append(p.tokens, &whenToken{kind: "ident", text: name, pos: start})
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "=~", "!~", "!", "(", ")"} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q at position %d", string(c), i+1)
+			}
+			p.tokens = append(p.tokens, &whenToken{kind: "op", text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (p *whenParser) peek() *whenToken {
+	if p.cur < len(p.tokens) {
+		return p.tokens[p.cur]
+	}
+	return nil
+}
+
+func (p *whenParser) acceptOp(ops ...string) *whenToken {
+	tok := p.peek()
+	if tok == nil || tok.kind != "op" {
+		return nil
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.cur++
+			return tok
+		}
+	}
+	return nil
+}
+
+func (p *whenParser) parseOr() (whenNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") != nil {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &whenBinary{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *whenParser) parseAnd() (whenNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") != nil {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &whenBinary{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *whenParser) parseUnary() (whenNode, error) {
+	if p.acceptOp("!") != nil {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &whenNot{node: node}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whenParser) parseComparison() (whenNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.acceptOp("==", "!=", "=~", "!~")
+	if tok == nil {
+		return left, nil
+	}
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	n := &whenBinary{op: tok.text, left: left, right: right}
+	if tok.text == "=~" || tok.text == "!~" {
+		pattern, ok := right.(whenLiteral)
+		if !ok {
+			return nil, fmt.Errorf("%s needs a string pattern at position %d", tok.text, tok.pos+1)
+		}
+		n.re, err = regexp.Compile(string(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern at position %d: %s", tok.pos+1, err)
+		}
+	}
+	return n, nil
+}
+
+func (p *whenParser) parsePrimary() (whenNode, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	p.cur++
+	switch {
+	case tok.kind == "string":
+		return whenLiteral(tok.text), nil
+	case tok.kind == "ident" && (tok.text == "true" || tok.text == "false"):
+		return whenLiteral(tok.text), nil
+	case tok.kind == "ident":
+		return whenVar(tok.text), nil
+	case tok.text == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.acceptOp(")") == nil {
+			return nil, fmt.Errorf("missing ) for ( at position %d", tok.pos+1)
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+}
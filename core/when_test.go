@@ -0,0 +1,87 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type WhenSuite struct {
+	*util.TestSuite
+}
+
+func TestWhenSuite(t *testing.T) {
+	suiteTester := &WhenSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *WhenSuite) TestEval() {
+	env := util.NewEnvironment(
+		"WERCKER_GIT_BRANCH=master",
+		"WERCKER_RESULT=failed",
+		"DEPLOY=true",
+		"SKIP=0",
+	)
+	env.Hidden.Add("SECRET", "hunter2")
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`WERCKER_GIT_BRANCH == "master" && WERCKER_RESULT == "failed"`, true},
+		{`WERCKER_GIT_BRANCH == "master" && WERCKER_RESULT == "passed"`, false},
+		{`WERCKER_GIT_BRANCH != 'master' || WERCKER_RESULT == "failed"`, true},
+		{`$WERCKER_GIT_BRANCH == "master"`, true},
+		{`${WERCKER_GIT_BRANCH} == "master"`, true},
+		{`DEPLOY`, true},
+		{`SKIP`, false},
+		{`MISSING`, false},
+		{`!MISSING`, true},
+		{`!(DEPLOY && WERCKER_RESULT == "failed")`, false},
+		{`WERCKER_GIT_BRANCH =~ "^(master|release/.*)$"`, true},
+		{`WERCKER_GIT_BRANCH !~ "^feature/"`, true},
+		{`SECRET == "hunter2"`, true},
+		{`true && !false`, true},
+		{`"say \"hi\"" == 'say "hi"'`, true},
+	}
+
+	for _, test := range tests {
+		result, err := EvalWhen(test.expr, env)
+		s.Nil(err, test.expr)
+		s.Equal(test.expected, result, test.expr)
+	}
+}
+
+func (s *WhenSuite) TestParseErrors() {
+	tests := []string{
+		``,
+		`A ==`,
+		`A == "b`,
+		`(A == "b"`,
+		`A == "b")`,
+		`A = "b"`,
+		`A =~ B`,
+		`A =~ "("`,
+		`A B`,
+	}
+
+	for _, test := range tests {
+		_, err := ParseWhen(test)
+		s.NotNil(err, test)
+	}
+}
@@ -0,0 +1,110 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Workflow pipeline types, builds get BUILD=true and deploys DEPLOY=true in
+// their environment just like `wercker build` and `wercker deploy`
+const (
+	WorkflowTypeBuild  = "build"
+	WorkflowTypeDeploy = "deploy"
+)
+
+// FindWorkflow returns the workflow called name. An empty name is fine if
+// the config only has one workflow.
+func (c *Config) FindWorkflow(name string) (*WorkflowConfig, error) {
+	if len(c.Workflows) == 0 {
+		return nil, fmt.Errorf("No workflows defined in wercker.yml")
+	}
+	if name == "" {
+		if len(c.Workflows) == 1 {
+			return c.Workflows[0], nil
+		}
+		names := make([]string, len(c.Workflows))
+		for i, w := range c.Workflows {
+			names[i] = w.Name
+		}
+		return nil, fmt.Errorf("Multiple workflows defined, pick one of: %s", strings.Join(names, ", "))
+	}
+	for _, w := range c.Workflows {
+		if w.Name == name {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("No workflow named %s", name)
+}
+
+// Validate makes sure the workflow is a DAG of pipelines that exist in config
+func (w *WorkflowConfig) Validate(config *Config) error {
+	if len(w.Pipelines) == 0 {
+		return fmt.Errorf("Workflow %s has no pipelines", w.Name)
+	}
+
+	nodes := map[string]*WorkflowPipelineConfig{}
+	for _, node := range w.Pipelines {
+		if node.Name == "" {
+			return fmt.Errorf("Workflow %s has a pipeline without a name", w.Name)
+		}
+		if _, ok := nodes[node.Name]; ok {
+			return fmt.Errorf("Workflow %s has more than one pipeline named %s", w.Name, node.Name)
+		}
+		nodes[node.Name] = node
+
+		if _, ok := config.PipelinesMap[node.PipelineName()]; !ok {
+			return fmt.Errorf("Workflow %s: no pipeline named %s", w.Name, node.PipelineName())
+		}
+		if node.Type != "" && node.Type != WorkflowTypeBuild && node.Type != WorkflowTypeDeploy {
+			return fmt.Errorf("Workflow %s: pipeline %s has an invalid type %q, expected %q or %q", w.Name, node.Name, node.Type, WorkflowTypeBuild, WorkflowTypeDeploy)
+		}
+	}
+
+	for _, node := range w.Pipelines {
+		for _, req := range node.Requires {
+			if _, ok := nodes[req]; !ok {
+				return fmt.Errorf("Workflow %s: pipeline %s requires %s, which is not in the workflow", w.Name, node.Name, req)
+			}
+		}
+	}
+
+	// Walk the graph looking for cycles, 1 is in progress and 2 is done
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("Workflow %s has a cycle: %s", w.Name, strings.Join(appendPath(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, req := range nodes[name].Requires {
+			if err := visit(req, appendPath(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for _, node := range w.Pipelines {
+		if err := visit(node.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
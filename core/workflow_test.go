@@ -0,0 +1,149 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type WorkflowSuite struct {
+	*util.TestSuite
+}
+
+func TestWorkflowSuite(t *testing.T) {
+	suiteTester := &WorkflowSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+var workflowYaml = `
+box: golang
+build:
+  steps:
+    - script:
+        code: go build
+test:
+  steps:
+    - script:
+        code: go test
+deploy-production:
+  steps:
+    - script:
+        code: ./deploy
+workflows:
+  - name: release
+    pipelines:
+      - name: build
+      - name: test
+        requires:
+          - build
+      - name: deploy
+        pipeline: deploy-production
+        type: deploy
+        requires: [test]
+  - name: quick
+    pipelines:
+      - name: build
+`
+
+func (s *WorkflowSuite) TestParse() {
+	config, err := ConfigFromYaml([]byte(workflowYaml))
+	s.Require().Nil(err)
+	s.Require().Len(config.Workflows, 2)
+	_, ok := config.PipelinesMap["workflows"]
+	s.False(ok)
+
+	release := config.Workflows[0]
+	s.Equal("release", release.Name)
+	s.Require().Len(release.Pipelines, 3)
+	s.Equal("build", release.Pipelines[0].PipelineName())
+	s.Equal([]string{"build"}, release.Pipelines[1].Requires)
+	s.Equal("deploy-production", release.Pipelines[2].PipelineName())
+	s.Equal(WorkflowTypeDeploy, release.Pipelines[2].Type)
+	s.Nil(release.Validate(config))
+
+	errs, err := ValidateConfig([]byte(workflowYaml))
+	s.Nil(err)
+	s.Empty(errs)
+}
+
+func (s *WorkflowSuite) TestFindWorkflow() {
+	config, err := ConfigFromYaml([]byte(workflowYaml))
+	s.Require().Nil(err)
+
+	w, err := config.FindWorkflow("quick")
+	s.Nil(err)
+	s.Equal("quick", w.Name)
+
+	_, err = config.FindWorkflow("")
+	s.Equal("Multiple workflows defined, pick one of: release, quick", err.Error())
+
+	_, err = config.FindWorkflow("nightly")
+	s.Equal("No workflow named nightly", err.Error())
+
+	config.Workflows = config.Workflows[1:]
+	w, err = config.FindWorkflow("")
+	s.Nil(err)
+	s.Equal("quick", w.Name)
+}
+
+func (s *WorkflowSuite) TestValidate() {
+	config, err := ConfigFromYaml([]byte(workflowYaml))
+	s.Require().Nil(err)
+
+	tests := []struct {
+		pipelines []*WorkflowPipelineConfig
+		expected  string
+	}{
+		{
+			[]*WorkflowPipelineConfig{},
+			"Workflow broken has no pipelines",
+		},
+		{
+			[]*WorkflowPipelineConfig{{Name: "build"}, {Name: "build"}},
+			"Workflow broken has more than one pipeline named build",
+		},
+		{
+			[]*WorkflowPipelineConfig{{Name: "lint"}},
+			"Workflow broken: no pipeline named lint",
+		},
+		{
+			[]*WorkflowPipelineConfig{{Name: "build", Type: "dev"}},
+			`Workflow broken: pipeline build has an invalid type "dev", expected "build" or "deploy"`,
+		},
+		{
+			[]*WorkflowPipelineConfig{{Name: "test", Requires: []string{"build"}}},
+			"Workflow broken: pipeline test requires build, which is not in the workflow",
+		},
+		{
+			[]*WorkflowPipelineConfig{
+				{Name: "build", Requires: []string{"deploy"}},
+				{Name: "test", Requires: []string{"build"}},
+				{Name: "deploy", Pipeline: "deploy-production", Requires: []string{"test"}},
+			},
+			"Workflow broken has a cycle: build -> deploy -> test -> build",
+		},
+	}
+
+	for _, test := range tests {
+		w := &WorkflowConfig{Name: "broken", Pipelines: test.pipelines}
+		err := w.Validate(config)
+		if s.NotNil(err) {
+			s.Equal(test.expected, err.Error())
+		}
+	}
+}
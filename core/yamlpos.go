@@ -0,0 +1,220 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// yamlPosition is a 1-based line and column in a yaml document
+type yamlPosition struct {
+	Line   int
+	Column int
+}
+
+// yamlLocator maps dotted paths (build.steps.0.script) to the position
+// of the key or sequence item that introduces them. The yaml library we use
+// does not expose node positions so this does a light pass over the
+// indentation structure of the document, which is all wercker.yml files
+// ever really use. Flow collections and multi-line plain scalars are not
+// descended into, lookups for anything inside them resolve to the nearest
+// ancestor we know about.
+type yamlLocator struct {
+	positions map[string]yamlPosition
+}
+
+type yamlFrame struct {
+	indent int
+	seq    bool
+	path   []string
+	index  int
+}
+
+// newYamlLocator indexes the document in data
+func newYamlLocator(data []byte) *yamlLocator {
+	l := &yamlLocator{positions: map[string]yamlPosition{}}
+
+	var stack []*yamlFrame
+	var pending []string
+	pendingIndent := -1
+	pendingStrict := false
+	blockIndent := -1
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		raw = strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(trimmed)
+
+		// Block scalars (| and >) swallow everything indented deeper than
+		// the line that started them
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == "---" || trimmed == "..." {
+			stack = nil
+			pending = nil
+			continue
+		}
+
+		dash := isSeqItem(trimmed)
+
+		// Open the container for a key (or item) that had no inline value
+		if pending != nil {
+			if indent > pendingIndent || (!pendingStrict && indent == pendingIndent && dash) {
+				stack = append(stack, &yamlFrame{indent: indent, seq: dash, path: pending})
+			}
+			pending = nil
+		}
+
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.indent > indent || (top.indent == indent && top.seq && !dash) {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			break
+		}
+		if len(stack) == 0 {
+			stack = append(stack, &yamlFrame{indent: indent, seq: dash})
+		}
+
+		content := trimmed
+		col := indent
+		for {
+			top := stack[len(stack)-1]
+			if isSeqItem(content) {
+				if !top.seq {
+					break
+				}
+				itemPath := appendPath(top.path, strconv.Itoa(top.index))
+				itemIndent := col
+				top.index++
+				l.record(itemPath, lineNo, col+1)
+
+				rest := strings.TrimLeft(content[1:], " ")
+				if rest == "" || strings.HasPrefix(rest, "#") {
+					pending = itemPath
+					pendingIndent = itemIndent
+					pendingStrict = true
+					break
+				}
+				col += len(content) - len(rest)
+				content = rest
+				if isSeqItem(content) {
+					stack = append(stack, &yamlFrame{indent: col, seq: true, path: itemPath})
+					continue
+				}
+				if isBlockIndicator(content) {
+					blockIndent = itemIndent
+					break
+				}
+				if _, _, ok := splitYamlKey(content); ok {
+					stack = append(stack, &yamlFrame{indent: col, path: itemPath})
+					continue
+				}
+				break
+			}
+
+			key, value, ok := splitYamlKey(content)
+			if !ok || top.seq {
+				break
+			}
+			keyPath := appendPath(top.path, key)
+			l.record(keyPath, lineNo, col+1)
+			if value == "" || strings.HasPrefix(value, "#") {
+				pending = keyPath
+				pendingIndent = col
+				pendingStrict = false
+			} else if isBlockIndicator(value) {
+				blockIndent = col
+			}
+			break
+		}
+	}
+	return l
+}
+
+func (l *yamlLocator) record(path []string, line, column int) {
+	k := strings.Join(path, ".")
+	if _, ok := l.positions[k]; !ok {
+		l.positions[k] = yamlPosition{Line: line, Column: column}
+	}
+}
+
+// Position returns the position for path, falling back to the closest
+// ancestor we know about and finally to the start of the document.
+func (l *yamlLocator) Position(path []string) yamlPosition {
+	for i := len(path); i > 0; i-- {
+		if pos, ok := l.positions[strings.Join(path[:i], ".")]; ok {
+			return pos
+		}
+	}
+	return yamlPosition{Line: 1, Column: 1}
+}
+
+func appendPath(path []string, elem string) []string {
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, elem)
+}
+
+func isSeqItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+func isBlockIndicator(s string) bool {
+	return strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">")
+}
+
+// splitYamlKey splits "key: value" into its parts, handling quoted keys and
+// ignoring colons that are not followed by whitespace (urls, images, etc).
+func splitYamlKey(s string) (string, string, bool) {
+	if s == "" || strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[") {
+		return "", "", false
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key := s[1 : end+1]
+		rest := strings.TrimLeft(s[end+2:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest[1:]), true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i > 0 && s[i-1] == ' ' {
+			return "", "", false
+		}
+		if s[i] != ':' {
+			continue
+		}
+		if i == len(s)-1 || s[i+1] == ' ' || s[i+1] == '\t' {
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
@@ -15,9 +15,11 @@
 package dockerlocal
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 
+	"github.com/docker/docker/api/types"
 	"github.com/wercker/wercker/core"
 	"github.com/wercker/wercker/util"
 	"golang.org/x/net/context"
@@ -129,3 +131,8 @@ func (fc *DockerFileCollector) Collect(ctx context.Context, path string) (*util.
 	}
 	return util.NewArchive(reader, func() { reader.Close() }), nil
 }
+
+// Restore extracts an archive made by Collect into dir in the container
+func (fc *DockerFileCollector) Restore(ctx context.Context, dir string, archive io.Reader) error {
+	return fc.client.CopyToContainer(ctx, fc.containerID, dir, archive, types.CopyToContainerOptions{})
+}
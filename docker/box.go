@@ -21,6 +21,7 @@ import (
 	"math"
 	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 
@@ -55,11 +56,18 @@ type DockerBox struct {
 	image           *docker.Image
 	volumes         []string
 	dockerEnvVar    []string
+	forked          bool
+	forkImages      []*docker.Image
+	snapshot        bool
 }
 
 // NewDockerBox from a name and other references
 func NewDockerBox(boxConfig *core.BoxConfig, options *core.PipelineOptions, dockerOptions *Options) (*DockerBox, error) {
 	name := boxConfig.ID
+	// Built boxes are tagged when they are built
+	if boxConfig.Build != nil && name == "" {
+		name = BuiltBoxRepository
+	}
 
 	if strings.Contains(name, "@") {
 		return nil, fmt.Errorf("Invalid box name, '@' is not allowed in docker repositories")
@@ -122,6 +130,18 @@ func NewDockerBox(boxConfig *core.BoxConfig, options *core.PipelineOptions, dock
 	}, nil
 }
 
+// UseSnapshot makes the box start from a local snapshot image instead of
+// the image of the box
+func (b *DockerBox) UseSnapshot(image string) {
+	b.Name = image
+	b.snapshot = true
+}
+
+// GetConfig returns the config of the box from the wercker.yml
+func (b *DockerBox) GetConfig() *core.BoxConfig {
+	return b.config
+}
+
 // GetName gets the box name
 func (b *DockerBox) GetName() string {
 	return b.Name
@@ -189,15 +209,55 @@ func (b *DockerBox) binds(env *util.Environment) ([]string, error) {
 	return binds, nil
 }
 
-// RunServices runs the services associated with this box
+// RunServices runs the services associated with this box, the services
+// they depend on first, and waits until they are ready
 func (b *DockerBox) RunServices(ctx context.Context, env *util.Environment) error {
 	linkedEnvVars := []string{}
 	// TODO(termie): terrible hack, sorry world
 	ctxWithServiceCount := context.WithValue(ctx, "ServiceCount", len(b.services))
 
-	for _, service := range b.services {
+	services, err := core.SortServices(b.services)
+	if err != nil {
+		return err
+	}
+	// The box is already local and on the network, unless there is an
+	// image with nc and wget to run the healthchecks in
+	healthcheckImage := b.dockerOptions.HealthcheckImage
+	if healthcheckImage == "" && b.image != nil {
+		healthcheckImage = b.image.ID
+	}
+	byAlias := map[string]core.ServiceBox{}
+	ready := map[string]bool{}
+	waitUntilReady := func(service core.ServiceBox) error {
+		alias := service.GetServiceAlias()
+		if ready[alias] {
+			return nil
+		}
+		if s, ok := service.(interface {
+			setHealthcheckImage(string)
+		}); ok {
+			s.setHealthcheckImage(healthcheckImage)
+		}
+		span := core.ProfileSpanFromContext(ctx).Start("ready", alias)
+		defer span.End()
+		if err := service.WaitUntilReady(ctx); err != nil {
+			return err
+		}
+		ready[alias] = true
+		return nil
+	}
+
+	for _, service := range services {
+		for _, dep := range service.DependsOn() {
+			if err := waitUntilReady(byAlias[dep]); err != nil {
+				return err
+			}
+		}
+		byAlias[service.GetServiceAlias()] = service
 		b.logger.Debugln("Startinq service:", service.GetName())
+		span := core.ProfileSpanFromContext(ctx).Start("service", service.GetName())
 		_, err := service.Run(ctxWithServiceCount, env, linkedEnvVars)
+		span.End()
 		if err != nil {
 			return err
 		}
@@ -207,6 +267,11 @@ func (b *DockerBox) RunServices(ctx context.Context, env *util.Environment) erro
 		}
 		linkedEnvVars = append(linkedEnvVars, svcEnvVar...)
 	}
+	for _, service := range services {
+		if err := waitUntilReady(service); err != nil {
+			return err
+		}
+	}
 	b.dockerEnvVar = linkedEnvVars
 	b.logger.Debugln(b.dockerEnvVar)
 	return nil
@@ -351,6 +416,18 @@ func (b *DockerBox) Run(ctx context.Context, env *util.Environment, rddURI strin
 	}
 	b.logger.Debugln("Starting base box:", b.Name)
 
+	container, err := b.startContainer(env, env.Interpolate(b.Name), b.getContainerName(), dockerNetworkName)
+	if err != nil {
+		return nil, err
+	}
+
+	b.container = container
+	return container, nil
+}
+
+// startContainer creates and starts a container from image with the
+// settings of the box
+func (b *DockerBox) startContainer(env *util.Environment, image, name, dockerNetworkName string) (*docker.Container, error) {
 	// TODO(termie): maybe move the container manipulation outside of here?
 	client := b.client
 
@@ -358,6 +435,7 @@ func (b *DockerBox) Run(ctx context.Context, env *util.Environment, rddURI strin
 	myEnv := dockerEnv(b.config.Env, env)
 	myEnv = append(myEnv, b.dockerEnvVar...)
 
+	var err error
 	var entrypoint []string
 	if b.entrypoint != "" {
 		entrypoint, err = shlex.Split(b.entrypoint)
@@ -394,10 +472,13 @@ func (b *DockerBox) Run(ctx context.Context, env *util.Environment, rddURI strin
 		PortBindings: portBindings(portsToBind),
 		DNS:          b.dockerOptions.DNS,
 		NetworkMode:  dockerNetworkName,
+		CPUPeriod:    b.dockerOptions.CPUPeriod,
+		CPUQuota:     b.dockerOptions.CPUQuota,
 	}
+	applyResources(hostConfig, b.config)
 
 	conf := &docker.Config{
-		Image:           env.Interpolate(b.Name),
+		Image:           image,
 		Tty:             false,
 		OpenStdin:       true,
 		Cmd:             cmd,
@@ -412,7 +493,7 @@ func (b *DockerBox) Run(ctx context.Context, env *util.Environment, rddURI strin
 		// Volumes: volumes,
 	}
 
-	if b.dockerOptions.Memory != 0 {
+	if b.dockerOptions.Memory != 0 && !limitsMemory(b.config) {
 		mem := b.dockerOptions.Memory
 		if len(b.services) > 0 {
 			mem = int64(float64(mem) * 0.75)
@@ -429,7 +510,7 @@ func (b *DockerBox) Run(ctx context.Context, env *util.Environment, rddURI strin
 	// Make and start the container
 	container, err := client.CreateContainer(
 		docker.CreateContainerOptions{
-			Name:       b.getContainerName(),
+			Name:       name,
 			Config:     conf,
 			HostConfig: hostConfig,
 		})
@@ -444,13 +525,89 @@ func (b *DockerBox) Run(ctx context.Context, env *util.Environment, rddURI strin
 	if err != nil {
 		return nil, err
 	}
-
-	b.container = container
 	return container, nil
 }
 
+// Fork commits the box container and starts a container per name from it.
+// Services aren't started again, the forks can reach the ones of the box.
+func (b *DockerBox) Fork(ctx context.Context, env *util.Environment, names []string) ([]core.Box, error) {
+	dockerNetworkName, err := b.GetDockerNetworkName()
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := b.client.CommitContainer(docker.CommitContainerOptions{
+		Container: b.container.ID,
+		Message:   "Fork",
+		Author:    "wercker",
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.forkImages = append(b.forkImages, image)
+
+	forks := []core.Box{}
+	for _, name := range names {
+		fork := &DockerBox{
+			Name:            image.ID,
+			ShortName:       b.ShortName,
+			networkDisabled: b.networkDisabled,
+			client:          b.client,
+			options:         b.options,
+			dockerOptions:   b.dockerOptions,
+			config:          b.config,
+			cmd:             b.cmd,
+			repository:      b.repository,
+			tag:             b.tag,
+			logger:          b.logger.WithField("Fork", name),
+			entrypoint:      b.entrypoint,
+			image:           image,
+			volumes:         b.volumes,
+			dockerEnvVar:    b.dockerEnvVar,
+			forked:          true,
+		}
+		container, err := fork.startContainer(env, image.ID, fmt.Sprintf("%s-%s", b.getContainerName(), name), dockerNetworkName)
+		if err != nil {
+			for _, fork := range forks {
+				fork.Stop()
+				fork.Clean()
+			}
+			return nil, err
+		}
+		fork.container = container
+		forks = append(forks, fork)
+	}
+	return forks, nil
+}
+
+// CopyFrom copies dir from the container of box on top of dir in ours
+func (b *DockerBox) CopyFrom(box core.Box, dir string) error {
+	r, w := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		uploaded <- b.client.UploadToContainer(b.container.ID, docker.UploadToContainerOptions{
+			InputStream: r,
+			Path:        path.Dir(dir),
+		})
+		// Don't leave the download hanging if the upload failed
+		r.Close()
+	}()
+	err := b.client.DownloadFromContainer(box.GetID(), docker.DownloadFromContainerOptions{
+		OutputStream: w,
+		Path:         dir,
+	})
+	w.CloseWithError(err)
+	if uploadErr := <-uploaded; err == nil {
+		err = uploadErr
+	}
+	return err
+}
+
 // Clean up the containers
 func (b *DockerBox) Clean() error {
+	if b.forked {
+		return b.cleanFork()
+	}
 	defer b.CleanDockerNetwork()
 	containers := []string{}
 	if b.container != nil {
@@ -489,9 +646,28 @@ func (b *DockerBox) Clean() error {
 		}
 	}
 
+	for _, image := range b.forkImages {
+		b.logger.WithField("Image", image.ID).Debugln("Removing image:", image.ID)
+		client.RemoveImage(image.ID)
+	}
+
 	return nil
 }
 
+// cleanFork only removes the container, the network and services belong to
+// the box that was forked
+func (b *DockerBox) cleanFork() error {
+	if b.container == nil {
+		return nil
+	}
+	b.logger.WithField("Container", b.container.ID).Debugln("Removing container:", b.container.ID)
+	return b.client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:            b.container.ID,
+		RemoveVolumes: true,
+		Force:         true,
+	})
+}
+
 // Restart stops and starts the box
 func (b *DockerBox) Restart() (*docker.Container, error) {
 	// TODO(termie): maybe move the container manipulation outside of here?
@@ -503,6 +679,15 @@ func (b *DockerBox) Restart() (*docker.Container, error) {
 	return b.container, nil
 }
 
+// KillProcesses sends signal to the processes in the box, the shell the
+// session talks to keeps running
+func (b *DockerBox) KillProcesses(signal string) error {
+	if b.container == nil {
+		return nil
+	}
+	return b.client.KillProcesses(b.container.ID, signal, ioutil.Discard)
+}
+
 // AddService needed by this Box
 func (b *DockerBox) AddService(service core.ServiceBox) {
 	b.services = append(b.services, service)
@@ -547,6 +732,26 @@ func (b *DockerBox) Fetch(ctx context.Context, env *util.Environment) (*docker.I
 	if err != nil {
 		return nil, err
 	}
+
+	// Snapshots only exist locally
+	if b.snapshot {
+		image, err := client.InspectImage(b.Name)
+		if err != nil {
+			return nil, err
+		}
+		b.image = image
+		return image, nil
+	}
+
+	if b.config.Build != nil && b.options.Checkpoint == "" {
+		return b.build(ctx, env)
+	}
+
+	digest, err := b.lockedDigest(env.Interpolate(b.Name))
+	if err != nil {
+		return nil, err
+	}
+
 	repo := env.Interpolate(b.repository)
 
 	b.config.Auth.Interpolate(env)
@@ -579,6 +784,9 @@ func (b *DockerBox) Fetch(ctx context.Context, env *util.Environment) (*docker.I
 		if err != nil {
 			return nil, err
 		}
+		if err := b.checkLockedDigest(image, env.Interpolate(b.Name), digest); err != nil {
+			return nil, err
+		}
 		b.image = image
 		return image, nil
 	}
@@ -596,6 +804,11 @@ func (b *DockerBox) Fetch(ctx context.Context, env *util.Environment) (*docker.I
 		Repository:    b.repository,
 		Tag:           env.Interpolate(b.tag),
 	}
+	// A locked image is pulled by its digest and tagged, so the tag is the
+	// locked image for everything that uses it
+	if digest != "" {
+		options.Tag = digest
+	}
 	authConfig := docker.AuthConfiguration{
 		Username: authenticator.Username(),
 		Password: authenticator.Password(),
@@ -604,10 +817,23 @@ func (b *DockerBox) Fetch(ctx context.Context, env *util.Environment) (*docker.I
 	if err != nil {
 		return nil, err
 	}
+	if digest != "" {
+		err = client.TagImage(fmt.Sprintf("%s@%s", b.repository, digest), docker.TagImageOptions{
+			Repo:  b.repository,
+			Tag:   env.Interpolate(b.tag),
+			Force: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 	image, err := client.InspectImage(env.Interpolate(b.Name))
 	if err != nil {
 		return nil, err
 	}
+	if err := b.checkLockedDigest(image, env.Interpolate(b.Name), digest); err != nil {
+		return nil, err
+	}
 	b.image = image
 
 	return nil, err
@@ -0,0 +1,120 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+// BuiltBoxRepository is the repository of the images built for boxes
+// without an id
+const BuiltBoxRepository = "wercker-box"
+
+// builtBoxTagLength is how much of the hash of the build context the tag of
+// a built image has
+const builtBoxTagLength = 16
+
+// build builds the image of a box with a build config, unless there is an
+// image for its build context already. The image is tagged with the hash
+// of the context.
+func (b *DockerBox) build(ctx context.Context, env *util.Environment) (*docker.Image, error) {
+	e, err := core.EmitterFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f := &util.Formatter{ShowColors: b.options.GlobalOptions.ShowColors}
+
+	config := b.config.Build
+	dir := env.Interpolate(config.Context)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(b.options.ProjectPath, dir)
+	}
+	target := env.Interpolate(config.Target)
+	args := map[string]string{}
+	buildArgs := map[string]*string{}
+	for k, v := range config.Args {
+		value := env.Interpolate(v)
+		args[k] = value
+		buildArgs[k] = &value
+	}
+
+	buildContext, err := core.NewBuildContext(dir, env.Interpolate(config.Dockerfile))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := buildContext.Hash(target, args)
+	if err != nil {
+		return nil, err
+	}
+	b.tag = hash[:builtBoxTagLength]
+	b.Name = fmt.Sprintf("%s:%s", b.repository, b.tag)
+
+	image, err := b.client.InspectImage(b.Name)
+	if err == nil {
+		b.logger.Debugln("Using", b.Name, "built from", dir, "before")
+		b.image = image
+		return image, nil
+	}
+	if err != docker.ErrNoSuchImage {
+		return nil, err
+	}
+
+	b.logger.Println(f.Info("Building "+b.Name, filepath.Join(dir, buildContext.Dockerfile)))
+	officialClient, err := NewOfficialDockerClient(b.dockerOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream the context to docker as it is tarred up
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(buildContext.WriteTar(w))
+	}()
+	defer r.Close()
+
+	buildOptions := types.ImageBuildOptions{
+		Dockerfile:  filepath.ToSlash(buildContext.Dockerfile),
+		Tags:        []string{b.Name},
+		BuildArgs:   buildArgs,
+		Target:      target,
+		Remove:      b.options.ShouldRemove,
+		ForceRemove: b.options.ShouldRemove,
+		PullParent:  !b.dockerOptions.Local, // always pull images unless docker-local is specified
+	}
+	imageBuildResponse, err := officialClient.ImageBuild(ctx, r, buildOptions)
+	if err != nil {
+		return nil, err
+	}
+	err = EmitStatus(e, imageBuildResponse.Body, b.options)
+	imageBuildResponse.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to build %s: %s", b.Name, err)
+	}
+
+	image, err = b.client.InspectImage(b.Name)
+	if err != nil {
+		return nil, err
+	}
+	b.image = image
+	return image, nil
+}
@@ -25,6 +25,7 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -86,11 +87,17 @@ func NewDockerScratchPushStep(stepConfig *core.StepConfig, options *core.Pipelin
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
+		Retry:       stepConfig.Retry,
+		Timeout:     stepConfig.Timeout,
+		CacheKey:    stepConfig.CacheKey,
+		Outputs:     stepConfig.Outputs,
 	})
 
 	dockerPushStep := &DockerPushStep{
 		BaseStep:      baseStep,
 		data:          stepConfig.Data,
+		config:        stepConfig,
 		dockerOptions: dockerOptions,
 		options:       options,
 		logger:        util.RootLogger().WithField("Logger", "DockerScratchPushStep"),
@@ -103,8 +110,8 @@ func NewDockerScratchPushStep(stepConfig *core.StepConfig, options *core.Pipelin
 func (s *DockerScratchPushStep) Execute(ctx context.Context, sess *core.Session) (int, error) {
 	// This is clearly only relevant to docker so we're going to dig into the
 	// transport internals a little bit to get the container ID
-	dt := sess.Transport().(*DockerTransport)
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport)
+	containerID := dt.ContainerID()
 
 	_, err := s.CollectArtifact(ctx, containerID)
 	if err != nil {
@@ -412,6 +419,8 @@ type DockerPushStep struct {
 	// if image is set then this image is tagged and pushed (equivalent to "docker push")
 	// if image is not set then the pipeline container is committed, tagged and pushed (classic behaviour)
 	image string
+	// config is where the list and map forms of ports, tag, volumes, env and labels come from
+	config *core.StepConfig
 }
 
 // NewDockerPushStep is a special step for doing docker pushes
@@ -433,11 +442,17 @@ func NewDockerPushStep(stepConfig *core.StepConfig, options *core.PipelineOption
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
+		Retry:       stepConfig.Retry,
+		Timeout:     stepConfig.Timeout,
+		CacheKey:    stepConfig.CacheKey,
+		Outputs:     stepConfig.Outputs,
 	})
 
 	return &DockerPushStep{
 		BaseStep:      baseStep,
 		data:          stepConfig.Data,
+		config:        stepConfig,
 		logger:        util.RootLogger().WithField("Logger", "DockerPushStep"),
 		options:       options,
 		dockerOptions: dockerOptions,
@@ -458,7 +473,10 @@ func (s *DockerPushStep) configure(env *util.Environment) error {
 	}
 
 	if tags, ok := s.data["tag"]; ok {
-		splitTags := util.SplitSpaceOrComma(tags)
+		splitTags, isList := s.config.PropertyList("tag")
+		if !isList {
+			splitTags = util.SplitSpaceOrComma(tags)
+		}
 		interpolatedTags := make([]string, len(splitTags))
 		for i, tag := range splitTags {
 			interpolatedTags[i] = env.Interpolate(tag)
@@ -475,8 +493,14 @@ func (s *DockerPushStep) configure(env *util.Environment) error {
 	}
 
 	if ports, ok := s.data["ports"]; ok {
-		iPorts := env.Interpolate(ports)
-		parts := util.SplitSpaceOrComma(iPorts)
+		parts, isList := s.config.PropertyList("ports")
+		if isList {
+			for i, part := range parts {
+				parts[i] = env.Interpolate(part)
+			}
+		} else {
+			parts = util.SplitSpaceOrComma(env.Interpolate(ports))
+		}
 		portset := make(nat.PortSet)
 		for _, portAndProto := range parts {
 			portAndProto = strings.TrimSpace(portAndProto) // The number can end with /tcp or /udp. If omitted,/tcp will be used.
@@ -499,8 +523,14 @@ func (s *DockerPushStep) configure(env *util.Environment) error {
 	}
 
 	if volumes, ok := s.data["volumes"]; ok {
-		iVolumes := env.Interpolate(volumes)
-		parts := util.SplitSpaceOrComma(iVolumes)
+		parts, isList := s.config.PropertyList("volumes")
+		if isList {
+			for i, part := range parts {
+				parts[i] = env.Interpolate(part)
+			}
+		} else {
+			parts = util.SplitSpaceOrComma(env.Interpolate(volumes))
+		}
 		volumemap := make(map[string]struct{})
 		for _, volume := range parts {
 			volume = strings.TrimSpace(volume)
@@ -527,7 +557,24 @@ func (s *DockerPushStep) configure(env *util.Environment) error {
 		}
 	}
 
-	if envi, ok := s.data["env"]; ok {
+	if envMap, ok := s.config.PropertyMap("env"); ok {
+		names := make([]string, 0, len(envMap))
+		for name := range envMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		interpolatedEnv := make([]string, len(names))
+		for i, name := range names {
+			interpolatedEnv[i] = env.Interpolate(fmt.Sprintf("%s=%s", name, envMap[name]))
+		}
+		s.env = interpolatedEnv
+	} else if envList, ok := s.config.PropertyList("env"); ok {
+		interpolatedEnv := make([]string, len(envList))
+		for i, envVar := range envList {
+			interpolatedEnv[i] = env.Interpolate(envVar)
+		}
+		s.env = interpolatedEnv
+	} else if envi, ok := s.data["env"]; ok {
 		parsedEnv, err := shlex.Split(envi)
 
 		if err == nil {
@@ -543,7 +590,13 @@ func (s *DockerPushStep) configure(env *util.Environment) error {
 		s.stopSignal = env.Interpolate(stopsignal)
 	}
 
-	if labels, ok := s.data["labels"]; ok {
+	if labels, ok := s.config.PropertyMap("labels"); ok {
+		labelMap := make(map[string]string)
+		for k, v := range labels {
+			labelMap[env.Interpolate(k)] = env.Interpolate(v)
+		}
+		s.labels = labelMap
+	} else if labels, ok := s.data["labels"]; ok {
 		parsedLabels, err := shlex.Split(labels)
 		if err == nil {
 			labelMap := make(map[string]string)
@@ -769,8 +822,8 @@ func (s *DockerPushStep) Execute(ctx context.Context, sess *core.Session) (int,
 
 	// This is clearly only relevant to docker so we're going to dig into the
 	// transport internals a little bit to get the container ID
-	dt := sess.Transport().(*DockerTransport)
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport)
+	containerID := dt.ContainerID()
 
 	s.tags = s.buildTags()
 
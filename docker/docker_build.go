@@ -68,6 +68,11 @@ func NewDockerBuildStep(stepConfig *core.StepConfig, options *core.PipelineOptio
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
+		Retry:       stepConfig.Retry,
+		Timeout:     stepConfig.Timeout,
+		CacheKey:    stepConfig.CacheKey,
+		Outputs:     stepConfig.Outputs,
 	})
 
 	return &DockerBuildStep{
@@ -174,8 +179,8 @@ func (s *DockerBuildStep) Execute(ctx context.Context, sess *core.Session) (int,
 
 	// This is clearly only relevant to docker so we're going to dig into the
 	// transport internals a little bit to get the container ID
-	dt := sess.Transport().(*DockerTransport) //              TODO Change this to use code which doesn't use fsouza client
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport) //              TODO Change this to use code which doesn't use fsouza client
+	containerID := dt.ContainerID()
 
 	// Extract the /pipeline/source directory from the running pipeline container
 	// and save it as a tarfile currentSource.tar
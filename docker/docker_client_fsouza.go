@@ -225,3 +225,19 @@ func (c *DockerClient) ExecOne(containerID string, cmd []string, output io.Write
 
 	return nil
 }
+
+// KillProcesses sends a signal to all the processes in the container except
+// for PID 1 and the shell it started, somewhat naive but seems to work
+func (c *DockerClient) KillProcesses(containerID string, sig string, output io.Writer) error {
+	details, err := c.InspectContainer(containerID)
+	if err != nil {
+		return err
+	}
+	var cmd []string
+	if len(details.Args) > 0 && details.Args[len(details.Args)-1] == `if [ -e /bin/bash ]; then /bin/bash; else /bin/sh; fi` {
+		cmd = []string{`/bin/sh`, `-c`, fmt.Sprintf(`ps -eaf | grep -v PID | awk "{if (\$2 != 1 && \$3 != 1) print \$2}" | xargs -n 1 kill -s %s`, sig)}
+	} else {
+		cmd = []string{`/bin/sh`, `-c`, fmt.Sprintf(`ps | grep -v PID | awk "{if (\$1 != 1) print \$1}" | xargs -n 1 kill -s %s`, sig)}
+	}
+	return c.ExecOne(containerID, cmd, output)
+}
@@ -52,6 +52,11 @@ func NewDockerKillStep(stepConfig *core.StepConfig, options *core.PipelineOption
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
+		Retry:       stepConfig.Retry,
+		Timeout:     stepConfig.Timeout,
+		CacheKey:    stepConfig.CacheKey,
+		Outputs:     stepConfig.Outputs,
 	})
 	return &DockerKillStep{
 		BaseStep:      baseStep,
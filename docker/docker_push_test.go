@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/suite"
 	"github.com/wercker/docker-check-access"
 	"github.com/wercker/wercker/auth"
@@ -61,6 +62,55 @@ func (s *PushSuite) TestEmptyPush() {
 	s.Equal([]string{"latest", "master-s4k2r0d6a9b"}, tags)
 }
 
+func (s *PushSuite) TestStructuredProperties() {
+	config := &core.StepConfig{
+		ID: "internal/docker-push",
+		Data: map[string]string{
+			"ports":  `["8080","9090/udp"]`,
+			"env":    `{"B":"2","A":"$VALUE"}`,
+			"labels": `{"maintainer":"wercker"}`,
+		},
+		Properties: map[string]interface{}{
+			"ports":  []interface{}{8080, "9090/udp"},
+			"env":    map[string]interface{}{"B": 2, "A": "$VALUE"},
+			"labels": map[string]interface{}{"maintainer": "wercker"},
+		},
+	}
+	step, _ := NewDockerPushStep(config, &core.PipelineOptions{}, nil)
+	env := util.NewEnvironment("VALUE=1")
+	s.Nil(step.configure(env))
+
+	s.Len(step.ports, 2)
+	_, ok := step.ports[nat.Port("8080/tcp")]
+	s.True(ok)
+	_, ok = step.ports[nat.Port("9090/udp")]
+	s.True(ok)
+	s.Equal([]string{"A=1", "B=2"}, step.env)
+	s.Equal(map[string]string{"maintainer": "wercker"}, step.labels)
+}
+
+func (s *PushSuite) TestInterpolateOutputs() {
+	config := &core.StepConfig{
+		ID: "internal/docker-push",
+		Data: map[string]string{
+			"tag": `["latest","${{ steps.version.outputs.version }}"]`,
+		},
+		Properties: map[string]interface{}{
+			"tag": []interface{}{"latest", "${{ steps.version.outputs.version }}"},
+		},
+	}
+	outputs := core.NewStepOutputs()
+	outputs.Set("version", map[string]string{"version": "1.2.3"})
+
+	step, _ := NewDockerPushStep(config, &core.PipelineOptions{}, nil)
+	s.Require().NoError(step.InterpolateOutputs(outputs))
+	s.Nil(step.configure(util.NewEnvironment()))
+	s.Equal([]string{"latest", "1.2.3"}, step.tags)
+
+	// The config from the wercker.yml is left alone
+	s.Equal("${{ steps.version.outputs.version }}", config.Properties["tag"].([]interface{})[1])
+}
+
 func (s *PushSuite) TestInferRegistryAndRepository() {
 	testWerckerRegistry, _ := url.Parse("https://test.wcr.io/v2")
 	repoTests := []struct {
@@ -74,6 +74,11 @@ func NewDockerRunStep(stepConfig *core.StepConfig, options *core.PipelineOptions
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
+		Retry:       stepConfig.Retry,
+		Timeout:     stepConfig.Timeout,
+		CacheKey:    stepConfig.CacheKey,
+		Outputs:     stepConfig.Outputs,
 	})
 
 	return &DockerRunStep{
@@ -0,0 +1,260 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pborman/uuid"
+	"github.com/wercker/wercker/core"
+	"golang.org/x/net/context"
+)
+
+// serviceLogLines is how many lines of its logs a service that doesn't get
+// ready fails with
+const serviceLogLines = 50
+
+// DependsOn are the aliases of the services this one waits for
+func (b *InternalServiceBox) DependsOn() []string {
+	return b.config.DependsOn
+}
+
+// WaitUntilReady runs the healthcheck of the service until it passes or
+// runs out of retries. A service that exits or doesn't get ready fails with
+// its last logs.
+func (b *InternalServiceBox) WaitUntilReady(ctx context.Context) error {
+	check := b.config.Healthcheck
+	if check == nil || b.container == nil {
+		return nil
+	}
+	client, err := NewDockerClient(b.dockerOptions)
+	if err != nil {
+		return err
+	}
+	alias := b.GetServiceAlias()
+	b.logger.Debugln("Waiting for service", alias, "to pass", check)
+
+	// The command runs in the service, the tcp and http checks in a
+	// container on the pipeline network. The host may not be able to reach
+	// the service, like when docker runs in a VM or on a remote DOCKER_HOST.
+	prober := b.container.ID
+	if check.Type != core.CommandHealthcheck {
+		helper, err := b.startProber(client)
+		if err != nil {
+			return err
+		}
+		defer b.removeProber(client, helper)
+		prober = helper.ID
+	}
+
+	var lastErr error
+	for i := 0; i < check.Retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(check.Interval):
+			}
+		}
+		container, err := client.InspectContainer(b.container.ID)
+		if err != nil {
+			return err
+		}
+		if !container.State.Running {
+			return b.notReady(client, fmt.Sprintf("exited with %d", container.State.ExitCode))
+		}
+		lastErr = b.probe(ctx, client, container, prober, check)
+		if lastErr == nil {
+			b.logger.Debugln("Service", alias, "is ready after", i+1, "checks")
+			return nil
+		}
+		b.logger.Debugln("Service", alias, "isn't ready:", lastErr)
+	}
+	return b.notReady(client, fmt.Sprintf("%s failed %d times, last with: %s", check, check.Retries, lastErr))
+}
+
+// probe runs the healthcheck once in the prober container
+func (b *InternalServiceBox) probe(ctx context.Context, client *DockerClient, container *docker.Container, prober string, check *core.HealthcheckConfig) error {
+	if check.Type == core.CommandHealthcheck {
+		return b.execProbe(ctx, client, prober, []string{"/bin/sh", "-c", check.Command}, check.Timeout)
+	}
+
+	ip := container.NetworkSettings.IPAddress
+	networkName, err := b.GetDockerNetworkName()
+	if err != nil {
+		return err
+	}
+	if network, ok := container.NetworkSettings.Networks[networkName]; ok && network.IPAddress != "" {
+		ip = network.IPAddress
+	}
+	if ip == "" {
+		return fmt.Errorf("the service has no IP address yet")
+	}
+	// nc and wget time out by themselves, the exec gets a moment longer
+	return b.execProbe(ctx, client, prober, healthcheckCommand(check, ip), check.Timeout+time.Second)
+}
+
+// healthcheckCommand is the nc or wget that runs a tcp or http check of the
+// service at ip
+func healthcheckCommand(check *core.HealthcheckConfig, ip string) []string {
+	timeout := strconv.Itoa(int(math.Ceil(check.Timeout.Seconds())))
+	port := strconv.Itoa(check.Port)
+	if check.Type == core.TCPHealthcheck {
+		return []string{"nc", "-z", "-w", timeout, ip, port}
+	}
+	// wget fails on the responses with a 4xx or 5xx status too
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, port), check.Path)
+	return []string{"wget", "-q", "-O", "/dev/null", "-T", timeout, url}
+}
+
+func (b *InternalServiceBox) setHealthcheckImage(image string) {
+	b.healthcheckImage = image
+}
+
+// startProber starts the container that runs the tcp and http checks on the
+// network of the pipeline
+func (b *InternalServiceBox) startProber(client *DockerClient) (*docker.Container, error) {
+	networkName, err := b.GetDockerNetworkName()
+	if err != nil {
+		return nil, err
+	}
+	image := b.healthcheckImage
+	if image == "" {
+		return nil, fmt.Errorf("No image to run the healthcheck of service %s in, set one with --docker-healthcheck-image", b.GetServiceAlias())
+	}
+	if _, err := client.InspectImage(image); err == docker.ErrNoSuchImage && !b.dockerOptions.Local {
+		repository, tag := image, "latest"
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			repository, tag = image[:i], image[i+1:]
+		}
+		b.logger.Debugln("Pulling", image, "to run the healthchecks")
+		err = client.PullImage(docker.PullImageOptions{
+			OutputStream: ioutil.Discard,
+			Repository:   repository,
+			Tag:          tag,
+		}, docker.AuthConfiguration{})
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	hostConfig := &docker.HostConfig{
+		DNS:         b.dockerOptions.DNS,
+		NetworkMode: networkName,
+	}
+	// The name is unique so a prober left behind by an earlier run with the
+	// same run id doesn't get in the way
+	container, err := client.CreateContainer(docker.CreateContainerOptions{
+		Name: fmt.Sprintf("%s-healthcheck-%s", b.getContainerName(), uuid.NewRandom().String()[:8]),
+		Config: &docker.Config{
+			Image:      image,
+			Entrypoint: []string{"sleep", "86400"},
+		},
+		HostConfig: hostConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.StartContainer(container.ID, hostConfig); err != nil {
+		b.removeProber(client, container)
+		return nil, err
+	}
+	return container, nil
+}
+
+func (b *InternalServiceBox) removeProber(client *DockerClient, container *docker.Container) {
+	err := client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:            container.ID,
+		RemoveVolumes: true,
+		Force:         true,
+	})
+	if err != nil {
+		b.logger.WithField("Error", err).Warnln("Unable to remove the healthcheck container of service", b.GetServiceAlias())
+	}
+}
+
+// execProbe runs cmd in the container, it fails when cmd exits with
+// anything but 0 or takes longer than timeout
+func (b *InternalServiceBox) execProbe(ctx context.Context, client *DockerClient, container string, cmd []string, timeout time.Duration) error {
+	exec, err := client.CreateExec(docker.CreateExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+		Container:    container,
+	})
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	started := make(chan error, 1)
+	go func() {
+		started <- client.StartExec(exec.ID, docker.StartExecOptions{
+			OutputStream: &output,
+			ErrorStream:  &output,
+		})
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	case err := <-started:
+		if err != nil {
+			return err
+		}
+	}
+
+	inspect, err := client.InspectExec(exec.ID)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("exited with %d: %s", inspect.ExitCode, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// notReady is the error of a service that didn't get ready, with its last
+// logs
+func (b *InternalServiceBox) notReady(client *DockerClient, reason string) error {
+	var logs bytes.Buffer
+	err := client.Logs(docker.LogsOptions{
+		Container:    b.container.ID,
+		Stdout:       true,
+		Stderr:       true,
+		OutputStream: &logs,
+		ErrorStream:  &logs,
+		Tail:         strconv.Itoa(serviceLogLines),
+	})
+	if err != nil {
+		b.logger.WithField("Error", err).Warnln("Unable to get the logs of service", b.GetServiceAlias())
+	}
+	alias := b.GetServiceAlias()
+	if logs.Len() == 0 {
+		return fmt.Errorf("Service %s is not ready, %s", alias, reason)
+	}
+	return fmt.Errorf("Service %s is not ready, %s\nLast logs of %s:\n%s", alias, reason, alias, strings.TrimRight(logs.String(), "\n"))
+}
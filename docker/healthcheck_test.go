@@ -0,0 +1,41 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type HealthcheckSuite struct {
+	*util.TestSuite
+}
+
+func TestHealthcheckSuite(t *testing.T) {
+	suiteTester := &HealthcheckSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *HealthcheckSuite) TestHealthcheckCommand() {
+	tcp := &core.HealthcheckConfig{Type: core.TCPHealthcheck, Port: 5432, Timeout: 5 * time.Second}
+	s.Equal([]string{"nc", "-z", "-w", "5", "172.18.0.2", "5432"}, healthcheckCommand(tcp, "172.18.0.2"))
+
+	http := &core.HealthcheckConfig{Type: core.HTTPHealthcheck, Port: 9200, Path: "/_cluster/health", Timeout: 1500 * time.Millisecond}
+	s.Equal([]string{"wget", "-q", "-O", "/dev/null", "-T", "2", "http://172.18.0.2:9200/_cluster/health"}, healthcheckCommand(http, "172.18.0.2"))
+}
@@ -0,0 +1,108 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+// lockedDigest is the digest wercker.lock pins the image name to, empty if
+// there is no wercker.lock. Checkpoints are local images so they can't be
+// locked.
+func (b *DockerBox) lockedDigest(name string) (string, error) {
+	lockfile := b.options.Lockfile
+	if lockfile == nil || b.options.Checkpoint != "" {
+		return "", nil
+	}
+	digest, ok := lockfile.Images[name]
+	if ok {
+		b.logger.Debugln("Using", name, "at", digest, "from", core.LockfileName)
+		return digest, nil
+	}
+	if b.options.FrozenLockfile {
+		return "", fmt.Errorf("The image %s isn't in %s, run wercker lock to add it", name, core.LockfileName)
+	}
+	b.logger.Warnln("The image", name, "isn't in", core.LockfileName+", run wercker lock to pin it")
+	return "", nil
+}
+
+// checkLockedDigest makes sure the image is the one wercker.lock pins name
+// to. Pulled images are pulled by the digest, but with --docker-local the
+// image is whatever has the tag locally. That fails with --frozen-lockfile.
+// The digest the tag has in the registry now isn't looked at, the pinned
+// one is used until wercker lock is run again.
+func (b *DockerBox) checkLockedDigest(image *docker.Image, name, digest string) error {
+	if digest == "" || hasRepoDigest(image.RepoDigests, b.repository, digest) {
+		return nil
+	}
+	if b.options.FrozenLockfile {
+		return fmt.Errorf("The image %s isn't %s from %s, pull it or run wercker lock to update it", name, digest, core.LockfileName)
+	}
+	b.logger.Warnln("The image", name, "isn't", digest, "from", core.LockfileName)
+	return nil
+}
+
+// Lock pulls the image of the box by its tag and returns the digest the
+// registry has for it, for wercker lock
+func (b *DockerBox) Lock(ctx context.Context, env *util.Environment) (string, error) {
+	name := env.Interpolate(b.Name)
+	if _, err := b.Fetch(ctx, env); err != nil {
+		return "", err
+	}
+	digest := repoDigest(b.image.RepoDigests, b.repository)
+	if digest == "" {
+		return "", fmt.Errorf("The image %s has no digest, only images from a registry can be locked", name)
+	}
+	return digest, nil
+}
+
+// repoDigest picks the digest of repository from the repo digests of an
+// image, which look like repository@sha256:...
+func repoDigest(repoDigests []string, repository string) string {
+	for _, repoDigest := range repoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) == 2 && parts[0] == repository {
+			return parts[1]
+		}
+	}
+	// Docker Hub images may be known by a longer name, like
+	// docker.io/library/golang
+	for _, repoDigest := range repoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) == 2 && strings.HasSuffix(parts[0], "/"+repository) {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// hasRepoDigest tells whether repository@digest is one of the repo digests
+// of an image
+func hasRepoDigest(repoDigests []string, repository, digest string) bool {
+	for _, repoDigest := range repoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) == 2 && parts[1] == digest &&
+			(parts[0] == repository || strings.HasSuffix(parts[0], "/"+repository)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type LockSuite struct {
+	*util.TestSuite
+}
+
+func TestLockSuite(t *testing.T) {
+	suiteTester := &LockSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *LockSuite) TestRepoDigest() {
+	repoDigests := []string{
+		"quay.io/coreos/etcd@sha256:1111",
+		"docker.io/library/golang@sha256:2222",
+	}
+	s.Equal("sha256:1111", repoDigest(repoDigests, "quay.io/coreos/etcd"))
+	s.Equal("sha256:2222", repoDigest(repoDigests, "golang"))
+	s.Equal("", repoDigest(repoDigests, "postgres"))
+	s.Equal("", repoDigest(nil, "golang"))
+}
+
+func (s *LockSuite) TestHasRepoDigest() {
+	repoDigests := []string{
+		"quay.io/coreos/etcd@sha256:1111",
+		"docker.io/library/golang@sha256:2222",
+		"docker.io/library/golang@sha256:3333",
+	}
+	s.True(hasRepoDigest(repoDigests, "quay.io/coreos/etcd", "sha256:1111"))
+	s.True(hasRepoDigest(repoDigests, "golang", "sha256:3333"))
+	s.False(hasRepoDigest(repoDigests, "golang", "sha256:1111"))
+	s.False(hasRepoDigest(repoDigests, "postgres", "sha256:2222"))
+	s.False(hasRepoDigest(nil, "golang", "sha256:2222"))
+}
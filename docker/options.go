@@ -39,6 +39,7 @@ type Options struct {
 	KernelMemory        int64
 	CleanupImage        bool
 	NetworkName         string
+	HealthcheckImage    string
 	RddServiceURI       string
 	RddProvisionTimeout time.Duration
 }
@@ -126,6 +127,7 @@ func NewOptions(ctx context.Context, c util.Settings, e *util.Environment) (*Opt
 	dockerKernelMemory, _ := c.Int("docker-kernel-memory")
 	dockerCleanupImage, _ := c.Bool("docker-cleanup-image")
 	dockerNetworkName, _ := c.String("docker-network")
+	dockerHealthcheckImage, _ := c.String("docker-healthcheck-image")
 	rddServiceURI, _ := c.String("rdd-service-uri")
 	rddProvisionTimeout, _ := c.Duration("rdd-provision-timeout")
 
@@ -143,6 +145,7 @@ func NewOptions(ctx context.Context, c util.Settings, e *util.Environment) (*Opt
 		KernelMemory:        int64(dockerKernelMemory) * 1024 * 1024,
 		CleanupImage:        dockerCleanupImage,
 		NetworkName:         dockerNetworkName,
+		HealthcheckImage:    dockerHealthcheckImage,
 		RddServiceURI:       rddServiceURI,
 		RddProvisionTimeout: rddProvisionTimeout,
 	}
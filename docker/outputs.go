@@ -0,0 +1,101 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"fmt"
+
+	"github.com/wercker/wercker/core"
+)
+
+// interpolateOutputs replaces the references to the outputs of earlier
+// steps in the data of an internal step, before InitEnv reads it
+func interpolateOutputs(step core.Step, data map[string]string, outputs *core.StepOutputs) (map[string]string, error) {
+	interpolated, _, err := outputs.InterpolateData(data, nil, false)
+	if err != nil {
+		return data, fmt.Errorf("Invalid data for step %s, %s", step.DisplayName(), err)
+	}
+	if interpolated == nil {
+		return data, nil
+	}
+	return interpolated, nil
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *DockerBuildStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+// and in the lists and maps of the config
+func (s *DockerPushStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, properties, err := outputs.InterpolateData(s.data, s.config.Properties, false)
+	if err != nil {
+		return fmt.Errorf("Invalid data for step %s, %s", s.DisplayName(), err)
+	}
+	if data == nil {
+		return nil
+	}
+	config := *s.config
+	config.Data = data
+	config.Properties = properties
+	s.data = data
+	s.config = &config
+	return nil
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *DockerKillStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *DockerRunStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *PublishStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *ShellStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *StoreContainerStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
+
+// InterpolateOutputs replaces the references to step outputs in the data
+func (s *WatchStep) InterpolateOutputs(outputs *core.StepOutputs) error {
+	data, err := interpolateOutputs(s, s.data, outputs)
+	s.data = data
+	return err
+}
@@ -43,8 +43,20 @@ func NewDockerPipeline(name string, config *core.Config, options *core.PipelineO
 		return nil, fmt.Errorf("Pipeline %s is empty", pipelineName)
 	}
 
-	// Select this pipeline's config or the global config
+	// Pick the matrix variant we are running, if any
+	var variant *core.MatrixConfig
+	if options.MatrixIndex > 0 {
+		if options.MatrixIndex > len(pipelineConfig.Matrix) {
+			return nil, fmt.Errorf("No matrix variant %d in pipeline %s", options.MatrixIndex, pipelineName)
+		}
+		variant = pipelineConfig.Matrix[options.MatrixIndex-1]
+	}
+
+	// Select the variant's config, this pipeline's config or the global config
 	rawBoxConfig := pipelineConfig.Box
+	if variant != nil && variant.Box != nil {
+		rawBoxConfig = variant.Box
+	}
 	if rawBoxConfig == nil {
 		rawBoxConfig = config.Box
 	}
@@ -56,17 +68,14 @@ func NewDockerPipeline(name string, config *core.Config, options *core.PipelineO
 
 	// Select this pipeline's service or the global config
 	servicesConfig := pipelineConfig.Services
+	if variant != nil && variant.Services != nil {
+		servicesConfig = variant.Services
+	}
 	if servicesConfig == nil {
 		servicesConfig = config.Services
 	}
 
-	stepsConfig := pipelineConfig.Steps
-	if options.DeployTarget != "" {
-		sectionSteps, ok := pipelineConfig.StepsMap[options.DeployTarget]
-		if ok {
-			stepsConfig = sectionSteps
-		}
-	}
+	stepsConfig, _ := pipelineConfig.StepsFor(options.DeployTarget)
 
 	afterStepsConfig := pipelineConfig.AfterSteps
 
@@ -74,6 +83,9 @@ func NewDockerPipeline(name string, config *core.Config, options *core.PipelineO
 	if err != nil {
 		return nil, err
 	}
+	if options.Resume != nil {
+		box.UseSnapshot(options.Resume.Image)
+	}
 
 	var services []core.ServiceBox
 	for _, serviceConfig := range servicesConfig {
@@ -124,11 +136,17 @@ func NewDockerPipeline(name string, config *core.Config, options *core.PipelineO
 		afterSteps = append([]core.Step{initStep}, afterSteps...)
 	}
 
+	env := util.NewEnvironment()
+	env.Update(pipelineConfig.OrderedEnv())
+	if variant != nil {
+		env.Update(variant.OrderedEnv())
+	}
+
 	logger := util.RootLogger().WithField("Logger", "Pipeline")
 	base := core.NewBasePipeline(core.BasePipelineOptions{
 		Options:    options,
 		Config:     pipelineConfig.PipelineConfig,
-		Env:        util.NewEnvironment(),
+		Env:        env,
 		Box:        box,
 		Services:   services,
 		Steps:      steps,
@@ -64,6 +64,11 @@ func NewPublishStep(stepConfig *core.StepConfig, options *core.PipelineOptions,
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
+		Retry:       stepConfig.Retry,
+		Timeout:     stepConfig.Timeout,
+		CacheKey:    stepConfig.CacheKey,
+		Outputs:     stepConfig.Outputs,
 	})
 
 	return &PublishStep{
@@ -109,8 +114,8 @@ func (s *PublishStep) Fetch() (string, error) {
 
 // Execute a shell and give it to the user
 func (s *PublishStep) Execute(ctx context.Context, sess *core.Session) (int, error) {
-	dt := sess.Transport().(*DockerTransport)
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport)
+	containerID := dt.ContainerID()
 
 	client, err := NewDockerClient(s.dockerOptions)
 	if err != nil {
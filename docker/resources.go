@@ -0,0 +1,58 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"github.com/fsouza/go-dockerclient"
+	"github.com/wercker/wercker/core"
+)
+
+// limitsMemory tells whether the resources of config set the memory, which
+// takes the place of the --docker-memory share of the box
+func limitsMemory(config *core.BoxConfig) bool {
+	return config.Resources != nil && config.Resources.Memory != 0
+}
+
+// applyResources sets the limits in the resources of config on hostConfig
+func applyResources(hostConfig *docker.HostConfig, config *core.BoxConfig) {
+	resources := config.Resources
+	if resources == nil {
+		return
+	}
+	if resources.Memory != 0 {
+		hostConfig.Memory = resources.Memory
+		hostConfig.MemorySwap = resources.MemorySwap
+	}
+	if resources.CPUs != 0 {
+		hostConfig.CPUPeriod = core.CPUPeriod
+		hostConfig.CPUQuota = resources.CPUQuota()
+	}
+	if resources.PidsLimit != 0 {
+		hostConfig.PidsLimit = resources.PidsLimit
+	}
+	if resources.ShmSize != 0 {
+		hostConfig.ShmSize = resources.ShmSize
+	}
+	for _, ulimit := range resources.Ulimits {
+		hostConfig.Ulimits = append(hostConfig.Ulimits, docker.ULimit{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		})
+	}
+	if len(resources.Tmpfs) > 0 {
+		hostConfig.Tmpfs = resources.Tmpfs
+	}
+}
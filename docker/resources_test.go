@@ -0,0 +1,67 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type ResourcesSuite struct {
+	*util.TestSuite
+}
+
+func TestResourcesSuite(t *testing.T) {
+	suiteTester := &ResourcesSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *ResourcesSuite) TestApplyResources() {
+	config := &core.BoxConfig{
+		ID: "golang",
+		Resources: &core.ResourcesConfig{
+			Memory:     1 << 30,
+			MemorySwap: -1,
+			CPUs:       0.5,
+			PidsLimit:  100,
+			ShmSize:    64 << 20,
+			Ulimits:    []core.UlimitConfig{{Name: "nofile", Soft: 1024, Hard: 4096}},
+			Tmpfs:      map[string]string{"/tmp": "size=64m"},
+		},
+	}
+	hostConfig := &docker.HostConfig{NetworkMode: "wercker"}
+	applyResources(hostConfig, config)
+	s.Equal(&docker.HostConfig{
+		NetworkMode: "wercker",
+		Memory:      1 << 30,
+		MemorySwap:  -1,
+		CPUPeriod:   100000,
+		CPUQuota:    50000,
+		PidsLimit:   100,
+		ShmSize:     64 << 20,
+		Ulimits:     []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 4096}},
+		Tmpfs:       map[string]string{"/tmp": "size=64m"},
+	}, hostConfig)
+	s.True(limitsMemory(config))
+
+	hostConfig = &docker.HostConfig{CPUPeriod: 50000, CPUQuota: 25000}
+	applyResources(hostConfig, &core.BoxConfig{ID: "golang"})
+	s.Equal(&docker.HostConfig{CPUPeriod: 50000, CPUQuota: 25000}, hostConfig)
+	s.False(limitsMemory(&core.BoxConfig{ID: "golang"}))
+}
@@ -46,6 +46,9 @@ func NewNilBuilder() *nilBuilder {
 type InternalServiceBox struct {
 	*DockerBox
 	logger *util.LogEntry
+	// healthcheckImage runs the tcp and http healthchecks, the pipeline
+	// sets it before waiting for the service
+	healthcheckImage string
 }
 
 // ExternalServiceBox wraps a box as a service
@@ -84,6 +87,9 @@ func NewServiceBox(config *core.BoxConfig, options *core.PipelineOptions, docker
 	if config.IsExternal() {
 		return NewExternalServiceBox(config, options, dockerOptions, builder)
 	}
+	if config.Build != nil && config.ID == "" && config.Name == "" {
+		return nil, fmt.Errorf("A service built from a Dockerfile needs an id or a name")
+	}
 	return NewInternalServiceBox(config, options, dockerOptions)
 }
 
@@ -185,6 +191,7 @@ func (b *InternalServiceBox) Run(ctx context.Context, env *util.Environment, env
 		PortBindings: portBindings(portsToBind),
 		NetworkMode:  networkName,
 	}
+	applyResources(hostConfig, b.config)
 
 	if len(binds) > 0 {
 		hostConfig.Binds = binds
@@ -203,7 +210,7 @@ func (b *InternalServiceBox) Run(ctx context.Context, env *util.Environment, env
 	// TODO(termie): terrible hack
 	// Get service count so we can divvy memory
 	serviceCount := ctx.Value("ServiceCount").(int)
-	if b.dockerOptions.Memory != 0 {
+	if b.dockerOptions.Memory != 0 && !limitsMemory(b.config) {
 		mem := b.dockerOptions.Memory
 		mem = int64(float64(mem) * 0.25 / float64(serviceCount))
 
@@ -251,6 +258,14 @@ func (b *InternalServiceBox) Run(ctx context.Context, env *util.Environment, env
 	client.StartContainer(container.ID, hostConfig)
 	b.container = container
 
+	if b.logsEnabled() {
+		file, err := b.createLogFile()
+		if err != nil {
+			return nil, err
+		}
+		go b.streamLogs(e, client, container.ID, file)
+	}
+
 	go func() {
 		status, err := client.WaitContainer(container.ID)
 		if err != nil {
@@ -258,7 +273,8 @@ func (b *InternalServiceBox) Run(ctx context.Context, env *util.Environment, env
 		}
 		b.logger.Debugln("Service container finished with status code:", status, container.ID)
 
-		if status != 0 {
+		// The logs were already shown while the service ran
+		if status != 0 && !b.logsEnabled() {
 			var errstream bytes.Buffer
 			var outstream bytes.Buffer
 			// recv := make(chan string)
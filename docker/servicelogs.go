@@ -0,0 +1,122 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+// logsEnabled tells whether the output of the service is shown, with logs:
+// true on the service or --service-logs for all of them
+func (b *InternalServiceBox) logsEnabled() bool {
+	return b.config.Logs || b.options.ServiceLogs
+}
+
+// createLogFile creates the file the output of the service is kept in, the
+// services dir is mounted in the pipeline container so after-steps can
+// keep it as an artifact
+func (b *InternalServiceBox) createLogFile() (*os.File, error) {
+	dir := b.options.HostPath(core.ServiceLogsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	name := strings.Replace(b.GetServiceAlias(), "/", "-", -1) + ".log"
+	return os.Create(filepath.Join(dir, name))
+}
+
+// streamLogs follows the output of the service until it stops. Every line
+// is emitted as logs of the service:<alias> stream with the alias in front
+// of it, and written to file.
+func (b *InternalServiceBox) streamLogs(e *core.NormalizedEmitter, client *DockerClient, containerID string, file io.WriteCloser) {
+	alias := b.GetServiceAlias()
+	f := &util.Formatter{ShowColors: b.options.GlobalOptions.ShowColors}
+	fork := e.Fork(f.Label(alias))
+	w := newServiceLogWriter(fork, "service:"+alias, file)
+
+	err := client.Logs(docker.LogsOptions{
+		Container:    containerID,
+		Follow:       true,
+		Stdout:       true,
+		Stderr:       true,
+		OutputStream: w,
+		ErrorStream:  w,
+	})
+	if err != nil {
+		b.logger.WithField("Error", err).Warnln("Unable to follow the logs of service", alias)
+	}
+	if err := w.Close(); err != nil {
+		b.logger.WithField("Error", err).Warnln("Unable to write the logs of service", alias)
+	}
+	fork.Flush()
+}
+
+// serviceLogWriter emits what the service writes to stdout and stderr and
+// keeps it in a file. The secrets are masked in the file a line at a time,
+// the emitter masks them in the logs.
+type serviceLogWriter struct {
+	emitter *core.NormalizedEmitter
+	stream  string
+	file    io.WriteCloser
+
+	lock    sync.Mutex
+	partial string
+}
+
+func newServiceLogWriter(emitter *core.NormalizedEmitter, stream string, file io.WriteCloser) *serviceLogWriter {
+	return &serviceLogWriter{emitter: emitter, stream: stream, file: file}
+}
+
+// Write emits p and writes the lines it finishes to the file
+func (w *serviceLogWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.emitter.Emit(core.Logs, &core.LogsArgs{
+		Stream: w.stream,
+		Logs:   string(p),
+	})
+
+	logs := w.partial + string(p)
+	end := strings.LastIndex(logs, "\n") + 1
+	w.partial = logs[end:]
+	if end == 0 {
+		return len(p), nil
+	}
+	if _, err := io.WriteString(w.file, w.emitter.Secrets().Mask(logs[:end])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the unfinished line to the file and closes it
+func (w *serviceLogWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.partial != "" {
+		if _, err := io.WriteString(w.file, w.emitter.Secrets().Mask(w.partial)); err != nil {
+			w.file.Close()
+			return err
+		}
+		w.partial = ""
+	}
+	return w.file.Close()
+}
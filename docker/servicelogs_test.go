@@ -0,0 +1,85 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type ServiceLogsSuite struct {
+	*util.TestSuite
+}
+
+func TestServiceLogsSuite(t *testing.T) {
+	suiteTester := &ServiceLogsSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (s *ServiceLogsSuite) TestWriter() {
+	e := core.NewNormalizedEmitter()
+	e.Secrets().Add("s3cr3t-value")
+	logs := []*core.LogsArgs{}
+	e.AddListener(core.Logs, func(args *core.LogsArgs) {
+		logs = append(logs, args)
+	})
+
+	file := &closingBuffer{}
+	fork := e.Fork("[db] ")
+	w := newServiceLogWriter(fork, "service:db", file)
+	w.Write([]byte("starting\nready with s3cr3t-"))
+	w.Write([]byte("value\nshutting"))
+	s.Equal("starting\nready with ****\n", file.String())
+
+	s.Require().NoError(w.Close())
+	fork.Flush()
+	s.True(file.closed)
+	s.Equal("starting\nready with ****\nshutting", file.String())
+
+	actual := []string{}
+	for _, args := range logs {
+		s.Equal("service:db", args.Stream)
+		s.Nil(args.Step)
+		actual = append(actual, args.Logs)
+	}
+	s.Equal([]string{
+		"[db] starting\n",
+		"[db] ready with ****\n",
+		"[db] shutting\n",
+	}, actual)
+}
+
+func (s *ServiceLogsSuite) TestLabel() {
+	f := &util.Formatter{ShowColors: true}
+	s.Equal(f.Label("db"), f.Label("db"))
+	s.Contains(f.Label("db"), "[db]")
+
+	f.ShowColors = false
+	s.Equal("[db] ", f.Label("db"))
+}
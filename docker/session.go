@@ -15,9 +15,12 @@
 package dockerlocal
 
 import (
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/fsouza/go-dockerclient"
+	"github.com/pborman/uuid"
 	"github.com/wercker/wercker/core"
 	"github.com/wercker/wercker/util"
 	"golang.org/x/net/context"
@@ -41,6 +44,17 @@ func NewDockerTransport(options *core.PipelineOptions, dockerOptions *Options, c
 	return &DockerTransport{options: options, client: client, containerID: containerID, logger: logger}, nil
 }
 
+// containerTransport is a transport to a docker container, for the steps
+// that need to talk to docker about it
+type containerTransport interface {
+	ContainerID() string
+}
+
+// ContainerID of the container we are attached to
+func (t *DockerTransport) ContainerID() string {
+	return t.containerID
+}
+
 // Attach the given reader and writers to the transport, return a context
 // that will be closed when the transport dies
 func (t *DockerTransport) Attach(sessionCtx context.Context, stdin io.Reader, stdout, stderr io.Writer) (context.Context, error) {
@@ -86,3 +100,108 @@ func (t *DockerTransport) Attach(sessionCtx context.Context, stdin io.Reader, st
 	started <- struct{}{}
 	return transportCtx, nil
 }
+
+// DockerExecTransport runs every batch of commands with its own docker exec
+// rather than through a shell attached to the container, which gives native
+// exit codes and keeps stdout and stderr apart. The environment and working
+// dir are kept in a state file in the container between batches, shell
+// options and functions don't carry over.
+type DockerExecTransport struct {
+	options     *core.PipelineOptions
+	client      *DockerClient
+	containerID string
+	statePath   string
+	logger      *util.LogEntry
+}
+
+// NewDockerExecTransport constructor
+func NewDockerExecTransport(options *core.PipelineOptions, dockerOptions *Options, containerID string) (core.Transport, error) {
+	client, err := NewDockerClient(dockerOptions)
+	if err != nil {
+		return nil, err
+	}
+	logger := util.RootLogger().WithField("Logger", "DockerExecTransport")
+	return &DockerExecTransport{
+		options:     options,
+		client:      client,
+		containerID: containerID,
+		statePath:   fmt.Sprintf("/tmp/.wercker-session-%s", uuid.NewRandom().String()),
+		logger:      logger,
+	}, nil
+}
+
+// ContainerID of the container we run the commands in
+func (t *DockerExecTransport) ContainerID() string {
+	return t.containerID
+}
+
+// Attach returns a context that will be closed when the container exits,
+// the commands are run by Exec so there is nothing to attach to
+func (t *DockerExecTransport) Attach(sessionCtx context.Context, stdin io.Reader, stdout, stderr io.Writer) (context.Context, error) {
+	transportCtx, cancel := context.WithCancel(sessionCtx)
+	go func() {
+		defer cancel()
+		status, err := t.client.WaitContainer(t.containerID)
+		if err != nil {
+			t.logger.Errorln("Error waiting", err)
+		}
+		t.logger.Debugln("Container finished with status code:", status, t.containerID)
+	}()
+	return transportCtx, nil
+}
+
+// execShell runs the script in $1 with bash if the box has it, the same
+// shell the box is started with
+const execShell = `if [ -e /bin/bash ]; then exec /bin/bash -c "$1"; else exec /bin/sh -c "$1"; fi`
+
+// script wraps commands so they start with the environment and working dir
+// the last batch left behind, and save theirs however they exit
+func (t *DockerExecTransport) script(commands []string) string {
+	return fmt.Sprintf(`if [ -f %[1]s ]; then . %[1]s 2>/dev/null; cd "$WERCKER_SESSION_PWD"; fi
+__wercker_save() {
+  __wercker_exit=$?
+  export WERCKER_SESSION_PWD="$PWD"
+  export -p > %[1]s
+  exit $__wercker_exit
+}
+trap __wercker_save EXIT
+%[2]s
+`, t.statePath, strings.Join(commands, "\n"))
+}
+
+// Exec runs commands in the container and returns their exit code
+func (t *DockerExecTransport) Exec(ctx context.Context, commands []string, stdout, stderr io.Writer) (int, error) {
+	exec, err := t.client.CreateExec(docker.CreateExecOptions{
+		AttachStdin:  false,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          false,
+		Cmd:          []string{"/bin/sh", "-c", execShell, "wercker", t.script(commands)},
+		Container:    t.containerID,
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- t.client.StartExec(exec.ID, docker.StartExecOptions{
+			OutputStream: stdout,
+			ErrorStream:  stderr,
+		})
+	}()
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case err := <-started:
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	inspect, err := t.client.InspectExec(exec.ID)
+	if err != nil {
+		return -1, err
+	}
+	return inspect.ExitCode, nil
+}
@@ -0,0 +1,116 @@
+//   Copyright © 2016,2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package dockerlocal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+	"golang.org/x/net/context"
+)
+
+type SessionSuite struct {
+	*util.TestSuite
+}
+
+func TestSessionSuite(t *testing.T) {
+	suiteTester := &SessionSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+type transportResult struct {
+	exit   int
+	output string
+}
+
+// sendAll starts a container and sends each of commands to it in a session
+// over the transport newTransport makes
+func (s *SessionSuite) sendAll(newTransport func(*core.PipelineOptions, *Options, string) (core.Transport, error), commands []string) ([]transportResult, []*core.LogsArgs) {
+	ctx := context.Background()
+	client := DockerOrSkip(ctx, s.T())
+
+	container, err := TempBusybox(ctx, client)
+	s.Require().Nil(err)
+	defer container.Remove(ctx)
+
+	dockerOptions := MinimalDockerOptions()
+	dc, err := NewDockerClient(dockerOptions)
+	s.Require().Nil(err)
+	s.Require().Nil(dc.StartContainer(container.ID, nil))
+	defer dc.KillContainer(docker.KillContainerOptions{ID: container.ID})
+
+	options := core.EmptyPipelineOptions()
+	options.CommandTimeout = 60 * 1000
+	options.NoResponseTimeout = 60 * 1000
+	transport, err := newTransport(options, dockerOptions, container.ID)
+	s.Require().Nil(err)
+
+	e := core.NewNormalizedEmitter()
+	logs := []*core.LogsArgs{}
+	e.AddListener(core.Logs, func(args *core.LogsArgs) {
+		logs = append(logs, args)
+	})
+	sess := core.NewSession(options, transport)
+	sessionCtx, err := sess.Attach(core.WithEmitter(ctx, e))
+	s.Require().Nil(err)
+
+	results := []transportResult{}
+	for _, command := range commands {
+		exit, recv, _ := sess.SendChecked(sessionCtx, command)
+		results = append(results, transportResult{exit: exit, output: strings.Join(recv, "")})
+	}
+	return results, logs
+}
+
+func (s *SessionSuite) TestExecTransportParity() {
+	commands := []string{
+		"export FOO=bar",
+		"cd /tmp",
+		`echo "$FOO $(pwd)"`,
+		"false",
+		"sh -c 'exit 3'",
+		"unset FOO",
+		`echo "foo=$FOO"`,
+	}
+	expected, _ := s.sendAll(NewDockerTransport, commands)
+	results, _ := s.sendAll(NewDockerExecTransport, commands)
+
+	s.Equal([]transportResult{
+		{0, ""},
+		{0, ""},
+		{0, "bar /tmp\n"},
+		{1, ""},
+		{3, ""},
+		{0, ""},
+		{0, "foo=\n"},
+	}, expected)
+	s.Equal(expected, results)
+}
+
+func (s *SessionSuite) TestExecTransportStreams() {
+	results, logs := s.sendAll(NewDockerExecTransport, []string{"echo out; echo err >&2; exit 2"})
+	s.Equal(2, results[0].exit)
+
+	streams := map[string]string{}
+	for _, args := range logs {
+		streams[args.Stream] += args.Logs
+	}
+	s.Equal("out\n", streams["stdout"])
+	s.Equal("err\n", streams["stderr"])
+}
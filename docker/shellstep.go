@@ -56,6 +56,7 @@ func NewShellStep(stepConfig *core.StepConfig, options *core.PipelineOptions, do
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
 	})
 
 	return &ShellStep{
@@ -97,8 +98,8 @@ func (s *ShellStep) Fetch() (string, error) {
 func (s *ShellStep) Execute(ctx context.Context, sess *core.Session) (int, error) {
 	// cheating to get containerID
 	// TODO(termie): we should deal with this eventually
-	dt := sess.Transport().(*DockerTransport)
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport)
+	containerID := dt.ContainerID()
 
 	client, err := NewDockerClient(s.dockerOptions)
 	if err != nil {
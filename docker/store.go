@@ -58,6 +58,7 @@ func NewStoreContainerStep(stepConfig *core.StepConfig, options *core.PipelineOp
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
 	})
 
 	return &StoreContainerStep{
@@ -120,8 +121,8 @@ func (s *StoreContainerStep) Execute(ctx context.Context, sess *core.Session) (i
 	}
 	// This is clearly only relevant to docker so we're going to dig into the
 	// transport internals a little bit to get the container ID
-	dt := sess.Transport().(*DockerTransport)
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport)
+	containerID := dt.ContainerID()
 
 	repoName := s.DockerRepo()
 	tag := s.DockerTag()
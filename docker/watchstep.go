@@ -68,6 +68,7 @@ func NewWatchStep(stepConfig *core.StepConfig, options *core.PipelineOptions, do
 		Owner:       "wercker",
 		SafeID:      stepSafeID,
 		Version:     util.Version(),
+		When:        stepConfig.When,
 	})
 
 	return &WatchStep{
@@ -179,28 +180,13 @@ func (s *WatchStep) watch(root string) (*fsnotify.Watcher, error) {
 }
 
 // killProcesses sends a signal to all the processes on the machine except
-// for PID 1, somewhat naive but seems to work
+// for PID 1, see DockerClient.KillProcesses
 func (s *WatchStep) killProcesses(containerID string, signal string) error {
 	client, err := NewDockerClient(s.dockerOptions)
 	if err != nil {
 		return err
 	}
-	details, err := client.InspectContainer(containerID)
-	if err != nil {
-		return err
-	}
-	var cmd []string
-	if len(details.Args) > 0 && details.Args[len(details.Args)-1] == `if [ -e /bin/bash ]; then /bin/bash; else /bin/sh; fi` {
-		cmd = []string{`/bin/sh`, `-c`, fmt.Sprintf(`ps -eaf | grep -v PID | awk "{if (\$2 != 1 && \$3 != 1) print \$2}" | xargs -n 1 kill -s %s`, signal)}
-	} else {
-		cmd = []string{`/bin/sh`, `-c`, fmt.Sprintf(`ps | grep -v PID | awk "{if (\$1 != 1) print \$1}" | xargs -n 1 kill -s %s`, signal)}
-	}
-
-	err = client.ExecOne(containerID, cmd, os.Stdout)
-	if err != nil {
-		return err
-	}
-	return nil
+	return client.KillProcesses(containerID, signal, os.Stdout)
 }
 
 // Execute runs a command and optionally reloads it
@@ -233,8 +219,8 @@ func (s *WatchStep) Execute(ctx context.Context, sess *core.Session) (int, error
 
 	// cheating to get containerID
 	// TODO(termie): we should deal with this eventually
-	dt := sess.Transport().(*DockerTransport)
-	containerID := dt.containerID
+	dt := sess.Transport().(containerTransport)
+	containerID := dt.ContainerID()
 
 	// Set up a signal handler to end our step.
 	finishedStep := make(chan struct{})
@@ -254,33 +240,45 @@ func (s *WatchStep) Execute(ctx context.Context, sess *core.Session) (int, error
 	//               after it processes them, so this may be superfluous
 	defer util.GlobalSigint().Remove(stopWatchHandler)
 
-	// If we're not going to reload just run the thing once, synchronously
+	// If we're not going to reload just run the thing once, with the exec
+	// transport Send only returns once the code has exited
 	if !s.reload {
-		err := sess.Send(ctx, false, "set +e", s.Code)
-		if err != nil {
-			return 0, err
+		sent := make(chan error, 1)
+		go func() {
+			sent <- sess.Send(ctx, false, "set +e", s.Code)
+		}()
+		select {
+		case err := <-sent:
+			if err != nil {
+				return 0, err
+			}
+			<-finishedStep
+		case <-finishedStep:
 		}
-		<-finishedStep
 		// ignoring errors
 		s.killProcesses(containerID, "INT")
 		return 0, nil
 	}
 	f := &util.Formatter{ShowColors: s.options.GlobalOptions.ShowColors}
 	s.logger.Info(f.Info("Reloading on file changes"))
-	doCmd := func() {
-		err := sess.Send(ctx, false, "set +e", s.Code)
-		if err != nil {
-			s.logger.Errorln(err)
-			return
-		}
+	// running is closed once the last run of the code has exited
+	running := make(chan struct{})
+	close(running)
+	doCmd := func(previous <-chan struct{}, exited chan<- struct{}) {
+		defer close(exited)
+		// Wait for the killed run to exit so the two don't overlap
+		<-previous
 		open, err := exposedPortMaps(s.dockerOptions.Host, s.options.PublishPorts)
 		if err != nil {
 			s.logger.Warnf(f.Info("There was a problem parsing your docker host."), err)
-			return
 		}
 		for _, uri := range open {
 			s.logger.Infof(f.Info("Forwarding %s to %s on the container."), uri.HostURI, uri.ContainerPort)
 		}
+		err = sess.Send(ctx, false, "set +e", s.Code)
+		if err != nil {
+			s.logger.Errorln(err)
+		}
 	}
 
 	// Otherwise set up a watcher and do some magic
@@ -309,7 +307,9 @@ func (s *WatchStep) Execute(ctx context.Context, sess *core.Session) (int, error
 					return
 				}
 				s.logger.Info(f.Info("Reloading"))
-				go doCmd()
+				exited := make(chan struct{})
+				go doCmd(running, exited)
+				running = exited
 			case err := <-watcher.Errors:
 				s.logger.Error(err)
 				done <- struct{}{}
@@ -0,0 +1,117 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// AsciicastOutput is the type of the events with terminal output
+	AsciicastOutput = "o"
+	// AsciicastMarker is the type of the events marking the start of a
+	// step, the data is the name of the step
+	AsciicastMarker = "m"
+)
+
+// AsciicastHeader is the first line of an asciicast v2 file, see
+// https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+type AsciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// AsciicastEvent is one of the lines after the header, stored as
+// [time, type, data] with time in seconds since the recording started
+type AsciicastEvent struct {
+	Time time.Duration
+	Type string
+	Data string
+}
+
+// MarshalJSON writes the event as an array
+func (e AsciicastEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time.Seconds(), e.Type, e.Data})
+}
+
+// UnmarshalJSON reads the event from an array
+func (e *AsciicastEvent) UnmarshalJSON(b []byte) error {
+	var seconds float64
+	fields := []interface{}{&seconds, &e.Type, &e.Data}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("Expected [time, type, data], got %s", b)
+	}
+	e.Time = time.Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// Asciicast is a recording made with --record
+type Asciicast struct {
+	Header AsciicastHeader
+	Events []AsciicastEvent
+}
+
+// ReadAsciicast reads an asciicast v2 recording
+func ReadAsciicast(r io.Reader) (*Asciicast, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Empty recording")
+	}
+
+	cast := &Asciicast{Events: []AsciicastEvent{}}
+	if err := json.Unmarshal(scanner.Bytes(), &cast.Header); err != nil {
+		return nil, fmt.Errorf("Invalid header on line 1: %s", err)
+	}
+	if cast.Header.Version != 2 {
+		return nil, fmt.Errorf("Unsupported asciicast version %d, expected 2", cast.Header.Version)
+	}
+	for n := 2; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event AsciicastEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("Invalid event on line %d: %s", n, err)
+		}
+		cast.Events = append(cast.Events, event)
+	}
+	return cast, scanner.Err()
+}
+
+// Markers are the indexes of the marker events, one for every step
+func (c *Asciicast) Markers() []int {
+	markers := []int{}
+	for i, event := range c.Events {
+		if event.Type == AsciicastMarker {
+			markers = append(markers, i)
+		}
+	}
+	return markers
+}
@@ -0,0 +1,123 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type AsciicastSuite struct {
+	*util.TestSuite
+}
+
+func TestAsciicastSuite(t *testing.T) {
+	suiteTester := &AsciicastSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error { return nil }
+
+type fakeStep struct {
+	core.Step
+	name string
+}
+
+func (s *fakeStep) DisplayName() string { return s.name }
+func (s *fakeStep) Name() string        { return s.name }
+func (s *fakeStep) SafeID() string      { return s.name + "-safe" }
+
+func (s *AsciicastSuite) TestRecord() {
+	var b bytes.Buffer
+	options := core.EmptyPipelineOptions()
+	options.Pipeline = "build"
+	h, err := newAsciicastHandler(nopCloser{&b}, options, 120, 40)
+	s.Require().Nil(err)
+
+	// The emitter masks the secrets before the handler gets the logs
+	e := core.NewNormalizedEmitter()
+	env := util.NewEnvironment()
+	env.Hidden.Add("XXX_TOKEN", "s3cr3t")
+	e.Secrets().AddEnvironment(env)
+	h.ListenTo(e)
+	step := &fakeStep{name: "deploy"}
+
+	e.Emit(core.BuildStepStarted, &core.BuildStepStartedArgs{Step: step})
+	e.Emit(core.Logs, &core.LogsArgs{Logs: "export TOKEN=$XXX_TOKEN\n", Stream: "stdin"})
+	e.Emit(core.Logs, &core.LogsArgs{Logs: "set -x\n", Hidden: true})
+	e.Emit(core.Logs, &core.LogsArgs{Logs: "token is s3cr3t\ndone\n"})
+	e.Emit(core.BuildStepFinished, &core.BuildStepFinishedArgs{Step: step, Successful: true})
+	e.Emit(core.FullPipelineFinished, &core.FullPipelineFinishedArgs{})
+	e.Emit(core.Logs, &core.LogsArgs{Logs: "after closing\n"})
+
+	s.NotContains(b.String(), "s3cr3t")
+
+	cast, err := ReadAsciicast(strings.NewReader(b.String()))
+	s.Require().Nil(err)
+	s.Equal(2, cast.Header.Version)
+	s.Equal(120, cast.Header.Width)
+	s.Equal(40, cast.Header.Height)
+	s.Equal([]int{0}, cast.Markers())
+
+	data := []string{}
+	for _, event := range cast.Events {
+		data = append(data, event.Type+" "+event.Data)
+	}
+	s.Equal([]string{
+		"m deploy",
+		"o --> Running step: deploy\r\n",
+		"o token is " + core.SecretMask + "\r\ndone\r\n",
+		"o --> Step passed: deploy\r\n",
+	}, data)
+}
+
+func (s *AsciicastSuite) TestReadAsciicast() {
+	cast, err := ReadAsciicast(strings.NewReader(`{"version": 2, "width": 80, "height": 24}
+[0.5, "m", "build"]
+
+[1.25, "o", "hello\r\n"]
+`))
+	s.Require().Nil(err)
+	s.Len(cast.Events, 2)
+	s.Equal("build", cast.Events[0].Data)
+	s.Equal(1250*time.Millisecond, cast.Events[1].Time)
+
+	_, err = ReadAsciicast(strings.NewReader(`{"version": 1}`))
+	s.Error(err)
+
+	_, err = ReadAsciicast(strings.NewReader("{\"version\": 2}\n[0.5, \"o\"]\n"))
+	s.Error(err)
+}
+
+func (s *AsciicastSuite) TestRecordPath() {
+	options := core.EmptyPipelineOptions()
+	options.Record = "build.cast"
+	s.Equal("build.cast", options.RunPath(options.Record))
+	options.MatrixIndex = 2
+	s.Equal("build-matrix-2.cast", options.RunPath(options.Record))
+	options.MatrixIndex = 0
+	options.WorkflowNode = "test"
+	s.Equal("build-test.cast", options.RunPath(options.Record))
+}
@@ -0,0 +1,157 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/term"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+// NewAsciicastHandler will create a new AsciicastHandler recording to the
+// --record file.
+func NewAsciicastHandler(options *core.PipelineOptions) (*AsciicastHandler, error) {
+	f, err := os.Create(options.RunPath(options.Record))
+	if err != nil {
+		return nil, err
+	}
+	width, height := 80, 24
+	if term.IsTerminal(os.Stdout.Fd()) {
+		if ws, err := term.GetWinsize(os.Stdout.Fd()); err == nil && ws.Width > 0 {
+			width, height = int(ws.Width), int(ws.Height)
+		}
+	}
+	return newAsciicastHandler(f, options, width, height)
+}
+
+func newAsciicastHandler(w io.WriteCloser, options *core.PipelineOptions, width, height int) (*AsciicastHandler, error) {
+	h := &AsciicastHandler{
+		w:         w,
+		enc:       json.NewEncoder(w),
+		options:   options,
+		formatter: &util.Formatter{ShowColors: options.GlobalOptions.ShowColors},
+		started:   time.Now(),
+	}
+	err := h.enc.Encode(&AsciicastHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: h.started.Unix(),
+		Title:     fmt.Sprintf("wercker %s %s", options.Pipeline, options.ApplicationName),
+		Env: map[string]string{
+			"TERM":  os.Getenv("TERM"),
+			"SHELL": os.Getenv("SHELL"),
+		},
+	})
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// An AsciicastHandler records what the build prints as an asciicast v2
+// file, with a marker at the start of every step. Hidden logs are left out,
+// the emitter masks the secrets in the others before they get here.
+type AsciicastHandler struct {
+	sync.Mutex
+	w         io.WriteCloser
+	enc       *json.Encoder
+	options   *core.PipelineOptions
+	formatter *util.Formatter
+	started   time.Time
+	closed    bool
+}
+
+// emit writes an event, terminal output needs \r\n line endings
+func (h *AsciicastHandler) emit(eventType, data string) {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return
+	}
+	if eventType == AsciicastOutput {
+		data = strings.Replace(data, "\n", "\r\n", -1)
+	}
+	err := h.enc.Encode(AsciicastEvent{
+		Time: time.Since(h.started),
+		Type: eventType,
+		Data: data,
+	})
+	if err != nil {
+		util.RootLogger().WithField("Logger", "Asciicast").WithField("Error", err).Warnln("Unable to record event")
+	}
+}
+
+// Logs will handle the Logs event.
+func (h *AsciicastHandler) Logs(args *core.LogsArgs) {
+	if args.Hidden || (args.Stream == "stdin" && !h.options.Verbose) {
+		return
+	}
+	h.emit(AsciicastOutput, args.Logs)
+}
+
+// StepStarted will handle the BuildStepStarted event.
+func (h *AsciicastHandler) StepStarted(args *core.BuildStepStartedArgs) {
+	name := args.Step.DisplayName()
+	h.emit(AsciicastMarker, name)
+	h.emit(AsciicastOutput, h.formatter.Info("Running step", name)+"\n")
+}
+
+// StepFinished will handle the BuildStepFinished event.
+func (h *AsciicastHandler) StepFinished(args *core.BuildStepFinishedArgs) {
+	name := args.Step.DisplayName()
+	switch {
+	case args.Skipped:
+		h.emit(AsciicastOutput, h.formatter.Info("Step skipped", name)+"\n")
+	case args.Successful:
+		h.emit(AsciicastOutput, h.formatter.Success("Step passed", name)+"\n")
+	default:
+		h.emit(AsciicastOutput, h.formatter.Fail("Step failed", name)+"\n")
+	}
+}
+
+// FullPipelineFinished will handle the FullPipelineFinished event, the
+// recording is done after it.
+func (h *AsciicastHandler) FullPipelineFinished(args *core.FullPipelineFinishedArgs) {
+	h.Close()
+}
+
+// Close the recording
+func (h *AsciicastHandler) Close() error {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return h.w.Close()
+}
+
+// ListenTo will add eventhandlers to e.
+func (h *AsciicastHandler) ListenTo(e *core.NormalizedEmitter) {
+	e.AddListener(core.Logs, h.Logs)
+	e.AddListener(core.BuildStepStarted, h.StepStarted)
+	e.AddListener(core.BuildStepFinished, h.StepFinished)
+	e.AddListener(core.FullPipelineFinished, h.FullPipelineFinished)
+}
@@ -0,0 +1,364 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+// JSONLSchemaVersion is the version of the events written by --output=jsonl.
+// Fields may be added without changing it, it goes up if a field is renamed,
+// removed or changes meaning.
+const JSONLSchemaVersion = 1
+
+// JSONLEvent is a line of --output=jsonl, the fields of Data depend on Event
+type JSONLEvent struct {
+	Version int       `json:"version"`
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	RunID   string    `json:"runId"`
+	// The pipeline, matrix variant and workflow node the event is from, a
+	// matrix or workflow writes the events of all its runs to one stream
+	Pipeline     string      `json:"pipeline"`
+	MatrixIndex  int         `json:"matrixIndex,omitempty"`
+	WorkflowNode string      `json:"workflowNode,omitempty"`
+	Data         interface{} `json:"data"`
+}
+
+// JSONLStep identifies a step in the events
+type JSONLStep struct {
+	SafeID      string `json:"safeId"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// JSONLBuildStarted is the Data of a BuildStarted event
+type JSONLBuildStarted struct {
+	ApplicationID   string `json:"applicationId"`
+	ApplicationName string `json:"applicationName"`
+}
+
+// JSONLBuildStepsAdded is the Data of a BuildStepsAdded event
+type JSONLBuildStepsAdded struct {
+	Steps      []JSONLStep `json:"steps"`
+	StoreStep  *JSONLStep  `json:"storeStep"`
+	AfterSteps []JSONLStep `json:"afterSteps"`
+}
+
+// JSONLBuildStepStarted is the Data of a BuildStepStarted event
+type JSONLBuildStepStarted struct {
+	Step  JSONLStep `json:"step"`
+	Order int       `json:"order"`
+}
+
+// JSONLLogs is the Data of a Logs event, Step is nil for the logs that are
+// not from a step
+type JSONLLogs struct {
+	Step   *JSONLStep `json:"step"`
+	Order  int        `json:"order"`
+	Stream string     `json:"stream"`
+	Logs   string     `json:"logs"`
+}
+
+// JSONLBuildStepFinished is the Data of a BuildStepFinished event
+type JSONLBuildStepFinished struct {
+	Step        JSONLStep         `json:"step"`
+	Order       int               `json:"order"`
+	Result      string            `json:"result"`
+	Message     string            `json:"message"`
+	ArtifactURL string            `json:"artifactUrl,omitempty"`
+	PackageURL  string            `json:"packageUrl,omitempty"`
+	Attempts    int               `json:"attempts"`
+	Cached      bool              `json:"cached"`
+	Outputs     map[string]string `json:"outputs,omitempty"`
+}
+
+// JSONLBuildFinished is the Data of a BuildFinished event
+type JSONLBuildFinished struct {
+	Result string `json:"result"`
+}
+
+// JSONLFullPipelineFinished is the Data of a FullPipelineFinished event,
+// Result is passed if both the steps and after-steps passed. Steps sums up
+// the run, every step that finished with its result and outputs.
+type JSONLFullPipelineFinished struct {
+	Result              string                    `json:"result"`
+	MainSuccessful      bool                      `json:"mainSuccessful"`
+	RanAfterSteps       bool                      `json:"ranAfterSteps"`
+	AfterStepSuccessful bool                      `json:"afterStepSuccessful"`
+	Steps               []*JSONLBuildStepFinished `json:"steps"`
+}
+
+// jsonlFiles are the --output-file files opened so far, the runs of a matrix
+// or workflow append to the file the first one created
+var jsonlFiles = struct {
+	sync.Mutex
+	created map[string]bool
+}{created: map[string]bool{}}
+
+// jsonlWriteLock keeps the lines of runs going at the same time apart
+var jsonlWriteLock sync.Mutex
+
+// NewJSONLHandler will create a new JSONLHandler writing to the
+// --output-file, or stdout if there is none.
+func NewJSONLHandler(options *core.PipelineOptions) (*JSONLHandler, error) {
+	if options.OutputFile == "" {
+		return newJSONLHandler(nopWriteCloser{os.Stdout}, options), nil
+	}
+
+	jsonlFiles.Lock()
+	defer jsonlFiles.Unlock()
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !jsonlFiles.created[options.OutputFile] {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(options.OutputFile, flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	jsonlFiles.created[options.OutputFile] = true
+	return newJSONLHandler(f, options), nil
+}
+
+func newJSONLHandler(w io.WriteCloser, options *core.PipelineOptions) *JSONLHandler {
+	return &JSONLHandler{
+		w:       w,
+		options: options,
+		logger:  util.RootLogger().WithField("Logger", "JSONL"),
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// A JSONLHandler writes every event as a line of JSON, see JSONLEvent.
+type JSONLHandler struct {
+	sync.Mutex
+	w       io.WriteCloser
+	options *core.PipelineOptions
+	logger  *util.LogEntry
+	seq     int
+	closed  bool
+	// The steps that finished so far, for the summary at the end
+	steps []*JSONLBuildStepFinished
+}
+
+func jsonlStep(step core.Step) JSONLStep {
+	return JSONLStep{
+		SafeID:      step.SafeID(),
+		Name:        step.Name(),
+		DisplayName: step.DisplayName(),
+	}
+}
+
+func jsonlSteps(steps []core.Step) []JSONLStep {
+	buffer := make([]JSONLStep, len(steps))
+	for i, step := range steps {
+		buffer[i] = jsonlStep(step)
+	}
+	return buffer
+}
+
+// newJSONLEvent wraps the data of an event from the run options are for
+func newJSONLEvent(options *core.PipelineOptions, seq int, event string, data interface{}) *JSONLEvent {
+	return &JSONLEvent{
+		Version:      JSONLSchemaVersion,
+		Seq:          seq,
+		Time:         time.Now().UTC(),
+		Event:        event,
+		RunID:        options.RunID,
+		Pipeline:     options.Pipeline,
+		MatrixIndex:  options.MatrixIndex,
+		WorkflowNode: options.WorkflowNode,
+		Data:         data,
+	}
+}
+
+func newJSONLBuildStarted(options *core.PipelineOptions) *JSONLBuildStarted {
+	return &JSONLBuildStarted{
+		ApplicationID:   options.ApplicationID,
+		ApplicationName: options.ApplicationName,
+	}
+}
+
+func newJSONLBuildStepsAdded(args *core.BuildStepsAddedArgs) *JSONLBuildStepsAdded {
+	data := &JSONLBuildStepsAdded{
+		Steps:      jsonlSteps(args.Steps),
+		AfterSteps: jsonlSteps(args.AfterSteps),
+	}
+	if args.StoreStep != nil {
+		storeStep := jsonlStep(args.StoreStep)
+		data.StoreStep = &storeStep
+	}
+	return data
+}
+
+func newJSONLBuildStepStarted(args *core.BuildStepStartedArgs) *JSONLBuildStepStarted {
+	return &JSONLBuildStepStarted{
+		Step:  jsonlStep(args.Step),
+		Order: args.Order,
+	}
+}
+
+func newJSONLLogs(args *core.LogsArgs) *JSONLLogs {
+	data := &JSONLLogs{
+		Order:  args.Order,
+		Stream: args.Stream,
+		Logs:   args.Logs,
+	}
+	if args.Step != nil {
+		step := jsonlStep(args.Step)
+		data.Step = &step
+	}
+	return data
+}
+
+func newJSONLBuildStepFinished(args *core.BuildStepFinishedArgs) *JSONLBuildStepFinished {
+	result := "failed"
+	if args.Skipped {
+		result = "skipped"
+	} else if args.Successful {
+		result = "passed"
+	}
+	return &JSONLBuildStepFinished{
+		Step:        jsonlStep(args.Step),
+		Order:       args.Order,
+		Result:      result,
+		Message:     args.Message,
+		ArtifactURL: args.ArtifactURL,
+		PackageURL:  args.PackageURL,
+		Attempts:    args.Attempts,
+		Cached:      args.Cached,
+		Outputs:     args.Outputs,
+	}
+}
+
+func newJSONLBuildFinished(args *core.BuildFinishedArgs) *JSONLBuildFinished {
+	return &JSONLBuildFinished{Result: args.Result}
+}
+
+func newJSONLFullPipelineFinished(args *core.FullPipelineFinishedArgs, steps []*JSONLBuildStepFinished) *JSONLFullPipelineFinished {
+	result := "failed"
+	if args.MainSuccessful && (!args.RanAfterSteps || args.AfterStepSuccessful) {
+		result = "passed"
+	}
+	return &JSONLFullPipelineFinished{
+		Result:              result,
+		MainSuccessful:      args.MainSuccessful,
+		RanAfterSteps:       args.RanAfterSteps,
+		AfterStepSuccessful: args.AfterStepSuccessful,
+		Steps:               steps,
+	}
+}
+
+// emit writes an event as a single line
+func (h *JSONLHandler) emit(event string, data interface{}) {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return
+	}
+	h.seq++
+	b, err := json.Marshal(newJSONLEvent(h.options, h.seq, event, data))
+	if err == nil {
+		jsonlWriteLock.Lock()
+		_, err = h.w.Write(append(b, '\n'))
+		jsonlWriteLock.Unlock()
+	}
+	if err != nil {
+		h.logger.WithField("Error", err).Warnln("Unable to write event")
+	}
+}
+
+// BuildStarted will handle the BuildStarted event.
+func (h *JSONLHandler) BuildStarted(args *core.BuildStartedArgs) {
+	h.emit(core.BuildStarted, newJSONLBuildStarted(h.options))
+}
+
+// StepsAdded will handle the BuildStepsAdded event.
+func (h *JSONLHandler) StepsAdded(args *core.BuildStepsAddedArgs) {
+	h.emit(core.BuildStepsAdded, newJSONLBuildStepsAdded(args))
+}
+
+// StepStarted will handle the BuildStepStarted event.
+func (h *JSONLHandler) StepStarted(args *core.BuildStepStartedArgs) {
+	h.emit(core.BuildStepStarted, newJSONLBuildStepStarted(args))
+}
+
+// Logs will handle the Logs event, leaving out the same logs as the
+// LiteralLogHandler.
+func (h *JSONLHandler) Logs(args *core.LogsArgs) {
+	if args.Hidden || (args.Stream == "stdin" && !h.options.Verbose) {
+		return
+	}
+	h.emit(core.Logs, newJSONLLogs(args))
+}
+
+// StepFinished will handle the BuildStepFinished event.
+func (h *JSONLHandler) StepFinished(args *core.BuildStepFinishedArgs) {
+	data := newJSONLBuildStepFinished(args)
+	h.Lock()
+	h.steps = append(h.steps, data)
+	h.Unlock()
+	h.emit(core.BuildStepFinished, data)
+}
+
+// BuildFinished will handle the BuildFinished event.
+func (h *JSONLHandler) BuildFinished(args *core.BuildFinishedArgs) {
+	h.emit(core.BuildFinished, newJSONLBuildFinished(args))
+}
+
+// FullPipelineFinished will handle the FullPipelineFinished event, it is
+// the last event of a run and has the summary of it.
+func (h *JSONLHandler) FullPipelineFinished(args *core.FullPipelineFinishedArgs) {
+	h.Lock()
+	steps := append([]*JSONLBuildStepFinished{}, h.steps...)
+	h.Unlock()
+	h.emit(core.FullPipelineFinished, newJSONLFullPipelineFinished(args, steps))
+	h.Close()
+}
+
+// Close the output
+func (h *JSONLHandler) Close() error {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return h.w.Close()
+}
+
+// ListenTo will add eventhandlers to e.
+func (h *JSONLHandler) ListenTo(e *core.NormalizedEmitter) {
+	e.AddListener(core.BuildStarted, h.BuildStarted)
+	e.AddListener(core.BuildStepsAdded, h.StepsAdded)
+	e.AddListener(core.BuildStepStarted, h.StepStarted)
+	e.AddListener(core.Logs, h.Logs)
+	e.AddListener(core.BuildStepFinished, h.StepFinished)
+	e.AddListener(core.BuildFinished, h.BuildFinished)
+	e.AddListener(core.FullPipelineFinished, h.FullPipelineFinished)
+}
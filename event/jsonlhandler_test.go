@@ -0,0 +1,134 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type JSONLSuite struct {
+	*util.TestSuite
+}
+
+func TestJSONLSuite(t *testing.T) {
+	suiteTester := &JSONLSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+// jsonlLines decodes every line into a map, the way a consumer would
+func jsonlLines(s string) ([]map[string]interface{}, error) {
+	lines := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return nil, err
+		}
+		lines = append(lines, event)
+	}
+	return lines, nil
+}
+
+func (s *JSONLSuite) TestEvents() {
+	var b bytes.Buffer
+	options := core.EmptyPipelineOptions()
+	options.RunID = "run-1"
+	options.Pipeline = "build"
+	options.MatrixIndex = 2
+	h := newJSONLHandler(nopCloser{&b}, options)
+
+	step := &fakeStep{name: "test"}
+	store := &fakeStep{name: "store"}
+	h.BuildStarted(&core.BuildStartedArgs{Options: options})
+	h.StepsAdded(&core.BuildStepsAddedArgs{Steps: []core.Step{step}, StoreStep: store})
+	h.StepStarted(&core.BuildStepStartedArgs{Step: step, Order: 3})
+	h.Logs(&core.LogsArgs{Step: step, Order: 3, Stream: "stdin", Logs: "go test\n"})
+	h.Logs(&core.LogsArgs{Step: step, Order: 3, Stream: "stdout", Logs: "set +x\n", Hidden: true})
+	h.Logs(&core.LogsArgs{Step: step, Order: 3, Stream: "stderr", Logs: "ok\n"})
+	h.StepFinished(&core.BuildStepFinishedArgs{Step: step, Order: 3, Successful: true, Attempts: 1, Outputs: map[string]string{"version": "1.2"}})
+	h.BuildFinished(&core.BuildFinishedArgs{Result: "passed"})
+	h.FullPipelineFinished(&core.FullPipelineFinishedArgs{MainSuccessful: true})
+	h.Logs(&core.LogsArgs{Logs: "after closing\n"})
+
+	lines, err := jsonlLines(b.String())
+	s.Require().Nil(err)
+	s.Require().Len(lines, 7)
+
+	expected := []string{
+		core.BuildStarted,
+		core.BuildStepsAdded,
+		core.BuildStepStarted,
+		core.Logs,
+		core.BuildStepFinished,
+		core.BuildFinished,
+		core.FullPipelineFinished,
+	}
+	for i, line := range lines {
+		s.Equal(expected[i], line["event"])
+		s.Equal(float64(JSONLSchemaVersion), line["version"])
+		s.Equal(float64(i+1), line["seq"])
+		s.Equal("run-1", line["runId"])
+		s.Equal("build", line["pipeline"])
+		s.Equal(float64(2), line["matrixIndex"])
+		_, ok := line["workflowNode"]
+		s.False(ok)
+		s.NotEmpty(line["time"])
+	}
+
+	added := lines[1]["data"].(map[string]interface{})
+	s.Equal("test-safe", added["steps"].([]interface{})[0].(map[string]interface{})["safeId"])
+	s.Equal("store", added["storeStep"].(map[string]interface{})["displayName"])
+	s.Equal([]interface{}{}, added["afterSteps"])
+
+	logs := lines[3]["data"].(map[string]interface{})
+	s.Equal("stderr", logs["stream"])
+	s.Equal("ok\n", logs["logs"])
+	s.Equal(float64(3), logs["order"])
+	s.Equal("test-safe", logs["step"].(map[string]interface{})["safeId"])
+
+	finished := lines[4]["data"].(map[string]interface{})
+	s.Equal("passed", finished["result"])
+	s.Equal(false, finished["cached"])
+	s.Equal(map[string]interface{}{"version": "1.2"}, finished["outputs"])
+
+	full := lines[6]["data"].(map[string]interface{})
+	s.Equal(true, full["mainSuccessful"])
+	s.Equal(false, full["ranAfterSteps"])
+	summary := full["steps"].([]interface{})
+	s.Require().Len(summary, 1)
+	s.Equal(finished, summary[0])
+}
+
+func (s *JSONLSuite) TestVerboseStdin() {
+	var b bytes.Buffer
+	options := core.EmptyPipelineOptions()
+	options.Verbose = true
+	h := newJSONLHandler(nopCloser{&b}, options)
+
+	h.Logs(&core.LogsArgs{Stream: "stdin", Logs: "go test\n"})
+
+	lines, err := jsonlLines(b.String())
+	s.Require().Nil(err)
+	s.Require().Len(lines, 1)
+	data := lines[0]["data"].(map[string]interface{})
+	s.Nil(data["step"])
+	s.Equal("stdin", data["stream"])
+}
@@ -15,6 +15,8 @@
 package event
 
 import (
+	"sync"
+
 	"github.com/wercker/reporter-client"
 	"github.com/wercker/wercker/core"
 	"github.com/wercker/wercker/util"
@@ -57,6 +59,8 @@ type ReportHandler struct {
 	reporter *reporter.ReportingClient
 	writers  map[string]*reporter.LogWriter
 	logger   *util.LogEntry
+	// Steps in a parallel group log at the same time
+	writersLock sync.Mutex
 }
 
 // BuildStepStarted will handle the BuildStepStarted event.
@@ -70,6 +74,8 @@ func (h *ReportHandler) StepStarted(args *core.BuildStepStartedArgs) {
 }
 
 func (h *ReportHandler) flushLogs(safeID string) error {
+	h.writersLock.Lock()
+	defer h.writersLock.Unlock()
 	if writer, ok := h.writers[safeID]; ok {
 		return writer.Flush()
 	}
@@ -82,7 +88,9 @@ func (h *ReportHandler) StepFinished(args *core.BuildStepFinishedArgs) {
 	h.flushLogs(args.Step.SafeID())
 
 	result := "failed"
-	if args.Successful {
+	if args.Skipped {
+		result = "skipped"
+	} else if args.Successful {
 		result = "passed"
 	}
 
@@ -122,6 +130,8 @@ func (h *ReportHandler) StepsAdded(args *core.BuildStepsAddedArgs) {
 // getStepOutputWriter will check h.writers for a writer for the step, otherwise
 // it will create a new one.
 func (h *ReportHandler) getStepOutputWriter(args *core.LogsArgs) (*reporter.LogWriter, error) {
+	h.writersLock.Lock()
+	defer h.writersLock.Unlock()
 	key := args.Step.SafeID()
 
 	writer, ok := h.writers[key]
@@ -172,6 +182,8 @@ func (h *ReportHandler) FullPipelineFinished(args *core.FullPipelineFinishedArgs
 
 // Close will call close on any log writers that have been created.
 func (h *ReportHandler) Close() error {
+	h.writersLock.Lock()
+	defer h.writersLock.Unlock()
 	for _, w := range h.writers {
 		w.Close()
 	}
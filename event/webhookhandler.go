@@ -0,0 +1,372 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+const (
+	// WebhookEventHeader is the webhook event a payload is for, like
+	// step-finished
+	WebhookEventHeader = "X-Wercker-Event"
+	// WebhookSignatureHeader is sha256= and the hex HMAC-SHA256 of the
+	// payload with the webhook's secret
+	WebhookSignatureHeader = "X-Wercker-Signature"
+
+	// webhookQueueSize is how many payloads can wait for a slow webhook
+	// before new ones are dropped
+	webhookQueueSize = 100
+	// webhookRequestTimeout is how long a single attempt may take
+	webhookRequestTimeout = 10 * time.Second
+	// webhookFlushTimeout is how long to wait for the queued payloads at
+	// the end of the pipeline
+	webhookFlushTimeout = 30 * time.Second
+)
+
+// NewWebhookHandler will create a new WebhookHandler sending to the
+// --webhook urls, the webhooks from wercker.yml are added by AddConfig.
+func NewWebhookHandler(options *core.PipelineOptions) (*WebhookHandler, error) {
+	h := newWebhookHandler(options, &http.Client{Timeout: webhookRequestTimeout})
+	if len(options.Webhooks) == 0 {
+		return h, nil
+	}
+
+	var tmpl *template.Template
+	if options.WebhookTemplate != "" {
+		b, err := ioutil.ReadFile(options.WebhookTemplate)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err = core.ParseWebhookTemplate(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid webhook template %s: %s", options.WebhookTemplate, err)
+		}
+	}
+
+	configs := []*core.WebhookConfig{}
+	for _, u := range options.Webhooks {
+		configs = append(configs, &core.WebhookConfig{
+			URL:      u,
+			Secret:   options.WebhookSecret,
+			Events:   core.WebhookEvents,
+			Retry:    core.DefaultWebhookRetry(),
+			Template: tmpl,
+		})
+	}
+	err := h.add(configs)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func newWebhookHandler(options *core.PipelineOptions, client *http.Client) *WebhookHandler {
+	return &WebhookHandler{
+		options:      options,
+		client:       client,
+		logger:       util.RootLogger().WithField("Logger", "Webhooks"),
+		flushTimeout: webhookFlushTimeout,
+	}
+}
+
+// A WebhookHandler posts build-started, step-finished and pipeline-finished
+// payloads to webhooks. Every webhook has its own queue so a slow one
+// doesn't hold up the build or the others.
+type WebhookHandler struct {
+	sync.Mutex
+	options      *core.PipelineOptions
+	client       *http.Client
+	logger       *util.LogEntry
+	webhooks     []*webhook
+	flushTimeout time.Duration
+	seq          int
+	// The build-started payload, wercker.yml is only read after it is sent
+	started    *JSONLEvent
+	configured bool
+	closed     bool
+	// The steps that finished so far, for the summary at the end
+	steps []*JSONLBuildStepFinished
+}
+
+// AddConfig starts sending to the webhooks in the notifications section of
+// wercker.yml, once the runner has the code of the build. Only the first
+// call counts.
+func (h *WebhookHandler) AddConfig(config *core.NotificationsConfig) error {
+	h.Lock()
+	defer h.Unlock()
+	if h.configured || h.closed {
+		return nil
+	}
+	h.configured = true
+	if config == nil {
+		return nil
+	}
+
+	configs := []*core.WebhookConfig{}
+	for _, c := range config.Webhooks {
+		w := *c
+		w.URL = h.interpolate(c.URL)
+		w.Secret = h.interpolate(c.Secret)
+		w.Headers = map[string]string{}
+		for k, v := range c.Headers {
+			w.Headers[k] = h.interpolate(v)
+		}
+		configs = append(configs, &w)
+	}
+	return h.add(configs)
+}
+
+// interpolate fills in the env vars from the host
+func (h *WebhookHandler) interpolate(s string) string {
+	if h.options.HostEnv == nil {
+		return s
+	}
+	return h.options.HostEnv.Interpolate(s)
+}
+
+// add starts the webhooks, they get the build-started payload if the build
+// already started
+func (h *WebhookHandler) add(configs []*core.WebhookConfig) error {
+	webhooks := []*webhook{}
+	for _, config := range configs {
+		if err := config.CheckURL(); err != nil {
+			return err
+		}
+		u, _ := url.Parse(config.URL)
+		webhooks = append(webhooks, &webhook{
+			config: config,
+			client: h.client,
+			logger: h.logger.WithField("Webhook", u.Host),
+			queue:  make(chan *webhookDelivery, webhookQueueSize),
+			done:   make(chan struct{}),
+			abort:  make(chan struct{}),
+		})
+	}
+	for _, w := range webhooks {
+		go w.run()
+		if h.started != nil && w.config.SendsEvent(core.WebhookBuildStarted) {
+			w.enqueue(core.WebhookBuildStarted, h.started)
+		}
+	}
+	h.webhooks = append(h.webhooks, webhooks...)
+	return nil
+}
+
+// send queues the payload for the webhooks that want webhookEvent
+func (h *WebhookHandler) send(event, webhookEvent string, data interface{}) {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return
+	}
+	h.seq++
+	payload := newJSONLEvent(h.options, h.seq, event, data)
+	if webhookEvent == core.WebhookBuildStarted {
+		h.started = payload
+	}
+	for _, w := range h.webhooks {
+		if w.config.SendsEvent(webhookEvent) {
+			w.enqueue(webhookEvent, payload)
+		}
+	}
+}
+
+// BuildStarted will handle the BuildStarted event.
+func (h *WebhookHandler) BuildStarted(args *core.BuildStartedArgs) {
+	h.send(core.BuildStarted, core.WebhookBuildStarted, newJSONLBuildStarted(h.options))
+}
+
+// StepFinished will handle the BuildStepFinished event.
+func (h *WebhookHandler) StepFinished(args *core.BuildStepFinishedArgs) {
+	data := newJSONLBuildStepFinished(args)
+	h.Lock()
+	h.steps = append(h.steps, data)
+	h.Unlock()
+	h.send(core.BuildStepFinished, core.WebhookStepFinished, data)
+}
+
+// FullPipelineFinished will handle the FullPipelineFinished event and wait
+// for the payloads still queued to be sent.
+func (h *WebhookHandler) FullPipelineFinished(args *core.FullPipelineFinishedArgs) {
+	h.Lock()
+	steps := append([]*JSONLBuildStepFinished{}, h.steps...)
+	h.Unlock()
+	h.send(core.FullPipelineFinished, core.WebhookPipelineFinished, newJSONLFullPipelineFinished(args, steps))
+	h.Close()
+}
+
+// Close stops taking payloads and waits for the queued ones to be sent, for
+// at most the flush timeout.
+func (h *WebhookHandler) Close() error {
+	h.Lock()
+	if h.closed {
+		h.Unlock()
+		return nil
+	}
+	h.closed = true
+	webhooks := h.webhooks
+	h.Unlock()
+
+	for _, w := range webhooks {
+		close(w.queue)
+	}
+	timeout := time.After(h.flushTimeout)
+	for _, w := range webhooks {
+		select {
+		case <-w.done:
+		case <-timeout:
+			h.logger.Warnln("Timed out sending webhooks, giving up on the rest")
+			for _, w := range webhooks {
+				close(w.abort)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// ListenTo will add eventhandlers to e.
+func (h *WebhookHandler) ListenTo(e *core.NormalizedEmitter) {
+	e.AddListener(core.BuildStarted, h.BuildStarted)
+	e.AddListener(core.BuildStepFinished, h.StepFinished)
+	e.AddListener(core.FullPipelineFinished, h.FullPipelineFinished)
+}
+
+// webhookDelivery is a rendered payload
+type webhookDelivery struct {
+	event string
+	body  []byte
+}
+
+// webhook sends the payloads in its queue one after the other
+type webhook struct {
+	config *core.WebhookConfig
+	client *http.Client
+	logger *util.LogEntry
+	queue  chan *webhookDelivery
+	// done is closed when the queue is empty after it was closed, abort to
+	// stop retrying
+	done  chan struct{}
+	abort chan struct{}
+}
+
+// enqueue renders the payload for the webhook, dropping it if the queue is
+// full rather than waiting
+func (w *webhook) enqueue(event string, payload *JSONLEvent) {
+	body, err := w.render(payload)
+	if err != nil {
+		w.logger.WithField("Error", err).Warnln("Unable to render webhook payload")
+		return
+	}
+	select {
+	case w.queue <- &webhookDelivery{event: event, body: body}:
+	default:
+		w.logger.Warnln("Webhook queue is full, dropping", event, "payload")
+	}
+}
+
+// render is the template executed with the payload, or its JSON
+func (w *webhook) render(payload *JSONLEvent) ([]byte, error) {
+	if w.config.Template == nil {
+		return json.Marshal(payload)
+	}
+	var b bytes.Buffer
+	err := w.config.Template.Execute(&b, payload)
+	return b.Bytes(), err
+}
+
+func (w *webhook) run() {
+	defer close(w.done)
+	for d := range w.queue {
+		w.deliver(d)
+	}
+}
+
+// deliver posts d, retrying as the webhook says as long as the errors may
+// go away
+func (w *webhook) deliver(d *webhookDelivery) {
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(d)
+		if err == nil {
+			return
+		}
+		if !retry || !w.config.Retry.ShouldRetry(attempt, 0) {
+			w.logger.WithField("Error", err).Warnln("Unable to send", d.event, "webhook")
+			return
+		}
+		w.logger.WithField("Error", err).Debugln("Retrying", d.event, "webhook")
+		select {
+		case <-time.After(w.config.Retry.Wait(attempt)):
+		case <-w.abort:
+			return
+		}
+	}
+}
+
+// webhookSignature is the hex HMAC-SHA256 of body
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// post sends d once, returns whether it is worth trying again if it failed.
+// The errors leave out the url, it may have a token in it.
+func (w *webhook) post(d *webhookDelivery) (bool, error) {
+	req, err := http.NewRequest("POST", w.config.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, fmt.Errorf("Invalid webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("wercker/%s", util.Version()))
+	req.Header.Set(WebhookEventHeader, d.event)
+	if w.config.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+webhookSignature(w.config.Secret, d.body))
+	}
+	for k, v := range w.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("Webhook responded with %s", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,227 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package event
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/core"
+	"github.com/wercker/wercker/util"
+)
+
+type WebhookSuite struct {
+	*util.TestSuite
+}
+
+func TestWebhookSuite(t *testing.T) {
+	suiteTester := &WebhookSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+type webhookRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// webhookServer records the requests it gets and responds with the status
+// codes in statuses, then 200
+type webhookServer struct {
+	*httptest.Server
+	sync.Mutex
+	requests []*webhookRequest
+	statuses []int
+	// Requests wait for this to be closed if it is set
+	hold chan struct{}
+}
+
+func newWebhookServer(statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.hold != nil {
+			<-s.hold
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.Lock()
+		defer s.Unlock()
+		s.requests = append(s.requests, &webhookRequest{path: r.URL.Path, header: r.Header, body: body})
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *webhookServer) Requests() []*webhookRequest {
+	s.Lock()
+	defer s.Unlock()
+	return s.requests
+}
+
+func webhookOptions() *core.PipelineOptions {
+	options := core.EmptyPipelineOptions()
+	options.RunID = "run-1"
+	options.Pipeline = "build"
+	return options
+}
+
+func quickRetry(attempts int) *core.RetryConfig {
+	return &core.RetryConfig{Attempts: attempts, Delay: time.Millisecond, Backoff: 2}
+}
+
+func (s *WebhookSuite) TestSend() {
+	server := newWebhookServer()
+	defer server.Close()
+	h := newWebhookHandler(webhookOptions(), server.Client())
+	s.Require().Nil(h.add([]*core.WebhookConfig{{
+		URL:     server.URL + "/hook",
+		Secret:  "s3cr3t",
+		Events:  core.WebhookEvents,
+		Retry:   quickRetry(1),
+		Headers: map[string]string{"X-Team": "ci"},
+	}}))
+
+	step := &fakeStep{name: "test"}
+	h.BuildStarted(&core.BuildStartedArgs{})
+	h.StepFinished(&core.BuildStepFinishedArgs{Step: step, Order: 3, Successful: true})
+	h.FullPipelineFinished(&core.FullPipelineFinishedArgs{MainSuccessful: true})
+
+	requests := server.Requests()
+	s.Require().Len(requests, 3)
+	expected := []struct{ webhookEvent, event string }{
+		{core.WebhookBuildStarted, core.BuildStarted},
+		{core.WebhookStepFinished, core.BuildStepFinished},
+		{core.WebhookPipelineFinished, core.FullPipelineFinished},
+	}
+	for i, r := range requests {
+		s.Equal(expected[i].webhookEvent, r.header.Get(WebhookEventHeader))
+		s.Equal("sha256="+webhookSignature("s3cr3t", r.body), r.header.Get(WebhookSignatureHeader))
+		s.Equal("application/json", r.header.Get("Content-Type"))
+		s.Equal("ci", r.header.Get("X-Team"))
+
+		var payload map[string]interface{}
+		s.Require().Nil(json.Unmarshal(r.body, &payload))
+		s.Equal(expected[i].event, payload["event"])
+		s.Equal("run-1", payload["runId"])
+		s.Equal(float64(i+1), payload["seq"])
+	}
+
+	var payload struct{ Data *JSONLFullPipelineFinished }
+	s.Require().Nil(json.Unmarshal(requests[2].body, &payload))
+	s.Equal("passed", payload.Data.Result)
+}
+
+func (s *WebhookSuite) TestRetry() {
+	server := newWebhookServer(http.StatusBadGateway, http.StatusTooManyRequests, http.StatusBadRequest)
+	defer server.Close()
+	h := newWebhookHandler(webhookOptions(), server.Client())
+	s.Require().Nil(h.add([]*core.WebhookConfig{{
+		URL:    server.URL,
+		Events: core.WebhookEvents,
+		Retry:  quickRetry(3),
+	}}))
+
+	// Delivered on the third attempt
+	h.BuildStarted(&core.BuildStartedArgs{})
+	// Not retried after the 400
+	h.StepFinished(&core.BuildStepFinishedArgs{Step: &fakeStep{name: "test"}})
+	h.Close()
+
+	requests := server.Requests()
+	s.Require().Len(requests, 4)
+	for i, event := range []string{core.WebhookBuildStarted, core.WebhookBuildStarted, core.WebhookBuildStarted, core.WebhookStepFinished} {
+		s.Equal(event, requests[i].header.Get(WebhookEventHeader))
+	}
+	s.Empty(requests[0].header.Get(WebhookSignatureHeader))
+}
+
+func (s *WebhookSuite) TestSlowWebhook() {
+	server := newWebhookServer()
+	server.hold = make(chan struct{})
+	defer server.Close()
+	h := newWebhookHandler(webhookOptions(), server.Client())
+	h.flushTimeout = time.Millisecond
+	s.Require().Nil(h.add([]*core.WebhookConfig{{
+		URL:    server.URL,
+		Events: core.WebhookEvents,
+		Retry:  quickRetry(1),
+	}}))
+
+	// The emitter is never held up, the payloads that don't fit in the
+	// queue are dropped
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < webhookQueueSize*2; i++ {
+			h.StepFinished(&core.BuildStepFinishedArgs{Step: &fakeStep{name: "test"}})
+		}
+		h.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.Fail("Sending webhooks blocked")
+	}
+	close(server.hold)
+}
+
+func (s *WebhookSuite) TestAddConfig() {
+	server := newWebhookServer()
+	defer server.Close()
+	options := webhookOptions()
+	options.HostEnv = util.NewEnvironment("HOOK_PATH=hooks/1", "HOOK_SECRET=s3cr3t")
+	h := newWebhookHandler(options, server.Client())
+
+	// wercker.yml is read after the build started
+	h.BuildStarted(&core.BuildStartedArgs{})
+	tmpl, err := core.ParseWebhookTemplate(`{"text": {{json .Pipeline}}, "event": "{{.Event}}"}`)
+	s.Require().Nil(err)
+	config := &core.NotificationsConfig{Webhooks: []*core.WebhookConfig{{
+		URL:      server.URL + "/$HOOK_PATH",
+		Secret:   "$HOOK_SECRET",
+		Events:   []string{core.WebhookBuildStarted, core.WebhookPipelineFinished},
+		Retry:    quickRetry(1),
+		Template: tmpl,
+	}}}
+	s.Require().Nil(h.AddConfig(config))
+	// Only the first config counts
+	s.Require().Nil(h.AddConfig(config))
+	h.StepFinished(&core.BuildStepFinishedArgs{Step: &fakeStep{name: "test"}})
+	h.FullPipelineFinished(&core.FullPipelineFinishedArgs{})
+
+	requests := server.Requests()
+	s.Require().Len(requests, 2)
+	s.Equal("/hooks/1", requests[0].path)
+	s.Equal(`{"text": "build", "event": "BuildStarted"}`, string(requests[0].body))
+	s.Equal("sha256="+webhookSignature("s3cr3t", requests[0].body), requests[0].header.Get(WebhookSignatureHeader))
+	s.Equal(core.WebhookPipelineFinished, requests[1].header.Get(WebhookEventHeader))
+	// The env vars are filled in on a copy
+	s.Equal(server.URL+"/$HOOK_PATH", config.Webhooks[0].URL)
+}
+
+func (s *WebhookSuite) TestInvalidURL() {
+	h := newWebhookHandler(webhookOptions(), http.DefaultClient)
+	err := h.AddConfig(&core.NotificationsConfig{Webhooks: []*core.WebhookConfig{{URL: "$UNSET/hook"}}})
+	s.Error(err)
+}
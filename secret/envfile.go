@@ -0,0 +1,310 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wercker/wercker/util"
+)
+
+const (
+	// KeyPrefix starts a symmetric key
+	KeyPrefix = "wercker-key-"
+
+	// IdentityPrefix starts the private key of a recipient
+	IdentityPrefix = "wercker-identity-"
+
+	// RecipientPrefix starts the public key of a recipient
+	RecipientPrefix = "wercker-recipient-"
+
+	envFileVersion = "wercker-secrets/v1"
+	wrapLabel      = "wercker-secrets/v1/p256"
+)
+
+// An encrypted env file is like an age file: the env file is encrypted with
+// a random file key and the file key is wrapped in a stanza for each of the
+// symmetric keys and recipients that may decrypt it
+type encryptedEnvFile struct {
+	Version string    `json:"version"`
+	Stanzas []*stanza `json:"stanzas"`
+	Data    []byte    `json:"data"`
+}
+
+// stanza is the file key wrapped for a symmetric key ("key") or a P-256
+// recipient ("p256"), the latter with the ephemeral public key of the ECDH
+type stanza struct {
+	Type      string `json:"type"`
+	Ephemeral []byte `json:"ephemeral,omitempty"`
+	FileKey   []byte `json:"fileKey"`
+}
+
+var curve = elliptic.P256()
+
+// GenerateKey makes a symmetric key
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// GenerateIdentity makes the identity of a recipient and the recipient to
+// give to the ones encrypting for it
+func GenerateIdentity() (identity, recipient string, err error) {
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	identity = IdentityPrefix + base64.RawURLEncoding.EncodeToString(padded(priv.D))
+	recipient = RecipientPrefix + base64.RawURLEncoding.EncodeToString(elliptic.Marshal(curve, priv.X, priv.Y))
+	return identity, recipient, nil
+}
+
+func decodeKey(prefix, s string) ([]byte, error) {
+	if !strings.HasPrefix(s, prefix) {
+		return nil, fmt.Errorf("Expected a key starting with %s", prefix)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, prefix))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid key starting with %s", prefix)
+	}
+	return b, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, the nonce goes in front
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts what seal encrypted
+func open(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("Encrypted data is too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// padded is n as 32 big-endian bytes
+func padded(n *big.Int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+// wrapKey derives the key wrapping the file key from the ECDH of an
+// ephemeral key and a recipient
+func wrapKey(shared *big.Int, ephemeral, recipient []byte) []byte {
+	mac := hmac.New(sha256.New, padded(shared))
+	mac.Write([]byte(wrapLabel))
+	mac.Write(ephemeral)
+	mac.Write(recipient)
+	return mac.Sum(nil)
+}
+
+// Encrypt plaintext so each of keys and recipients can decrypt it
+func Encrypt(plaintext []byte, keys, recipients []string) ([]byte, error) {
+	if len(keys) == 0 && len(recipients) == 0 {
+		return nil, errors.New("Need a key or a recipient to encrypt for")
+	}
+	fileKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, err
+	}
+	file := &encryptedEnvFile{Version: envFileVersion}
+
+	for _, k := range keys {
+		key, err := decodeKey(KeyPrefix, k)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := seal(key, fileKey)
+		if err != nil {
+			return nil, err
+		}
+		file.Stanzas = append(file.Stanzas, &stanza{Type: "key", FileKey: wrapped})
+	}
+
+	for _, r := range recipients {
+		recipient, err := decodeKey(RecipientPrefix, r)
+		if err != nil {
+			return nil, err
+		}
+		x, y := elliptic.Unmarshal(curve, recipient)
+		if x == nil {
+			return nil, fmt.Errorf("Invalid recipient %s", r)
+		}
+		eph, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ephemeral := elliptic.Marshal(curve, eph.X, eph.Y)
+		shared, _ := curve.ScalarMult(x, y, eph.D.Bytes())
+		wrapped, err := seal(wrapKey(shared, ephemeral, recipient), fileKey)
+		if err != nil {
+			return nil, err
+		}
+		file.Stanzas = append(file.Stanzas, &stanza{Type: "p256", Ephemeral: ephemeral, FileKey: wrapped})
+	}
+
+	data, err := seal(fileKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	file.Data = data
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// unwrap gets the file key out of the stanzas with one of keys or identities
+func (f *encryptedEnvFile) unwrap(keys, identities []string) ([]byte, error) {
+	for _, s := range f.Stanzas {
+		switch s.Type {
+		case "key":
+			for _, k := range keys {
+				key, err := decodeKey(KeyPrefix, k)
+				if err != nil {
+					return nil, err
+				}
+				if fileKey, err := open(key, s.FileKey); err == nil {
+					return fileKey, nil
+				}
+			}
+		case "p256":
+			x, y := elliptic.Unmarshal(curve, s.Ephemeral)
+			if x == nil {
+				continue
+			}
+			for _, i := range identities {
+				d, err := decodeKey(IdentityPrefix, i)
+				if err != nil {
+					return nil, err
+				}
+				rx, ry := curve.ScalarBaseMult(d)
+				recipient := elliptic.Marshal(curve, rx, ry)
+				shared, _ := curve.ScalarMult(x, y, d)
+				if fileKey, err := open(wrapKey(shared, s.Ephemeral, recipient), s.FileKey); err == nil {
+					return fileKey, nil
+				}
+			}
+		}
+	}
+	return nil, errors.New("None of the keys or identities can decrypt it")
+}
+
+// Decrypt what Encrypt encrypted with one of keys or identities
+func Decrypt(data []byte, keys, identities []string) ([]byte, error) {
+	file := &encryptedEnvFile{}
+	if err := json.Unmarshal(data, file); err != nil || file.Version != envFileVersion {
+		return nil, errors.New("Not an encrypted env file")
+	}
+	fileKey, err := file.unwrap(keys, identities)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(fileKey, file.Data)
+	if err != nil {
+		return nil, errors.New("Encrypted env file is corrupt")
+	}
+	return plaintext, nil
+}
+
+// envFileProvider gets secrets from env files encrypted with Encrypt, the
+// path is the file and the key the env var
+type envFileProvider struct {
+	sync.Mutex
+	dir        string
+	keys       []string
+	identities []string
+	files      map[string]*util.Environment
+}
+
+// newEnvFileProvider is configured with a key and/or an identity, more
+// than one can be given separated by spaces or commas
+func newEnvFileProvider(options map[string]string) (Provider, error) {
+	p := &envFileProvider{
+		dir:        options["dir"],
+		keys:       util.SplitSpaceOrComma(options["key"]),
+		identities: util.SplitSpaceOrComma(options["identity"]),
+		files:      map[string]*util.Environment{},
+	}
+	if len(p.keys) == 0 && len(p.identities) == 0 {
+		return nil, errors.New("Needs a key or an identity, set WERCKER_SECRETS_KEY or WERCKER_SECRETS_IDENTITY")
+	}
+	return p, nil
+}
+
+// Get the env var key from the encrypted env file at path
+func (p *envFileProvider) Get(path, key string) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	env, ok := p.files[path]
+	if !ok {
+		name := path
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(p.dir, name)
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		plaintext, err := Decrypt(data, p.keys, p.identities)
+		if err != nil {
+			return "", fmt.Errorf("Unable to decrypt %s: %s", path, err)
+		}
+		env = util.NewEnvironment()
+		if err := env.Load(bytes.NewReader(plaintext)); err != nil {
+			return "", err
+		}
+		p.files[path] = env
+	}
+	return pick(env.Map, key, path)
+}
@@ -0,0 +1,74 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+// defaultPassCommand decrypts the files of a password store
+const defaultPassCommand = "gpg --quiet --batch --decrypt"
+
+// passProvider gets secrets from a directory of gpg encrypted files like
+// the ones pass keeps. The path is the file without .gpg, the password is
+// on its first line and the key picks one of the `key: value` lines after it.
+type passProvider struct {
+	dir     string
+	command []string
+}
+
+// newPassProvider is configured with the dir of the password store and
+// the command decrypting its files
+func newPassProvider(options map[string]string) (Provider, error) {
+	command := options["command"]
+	if command == "" {
+		command = defaultPassCommand
+	}
+	parts, err := shlex.Split(command)
+	if err != nil || len(parts) == 0 {
+		return nil, fmt.Errorf("Invalid command %q", command)
+	}
+	return &passProvider{dir: options["dir"], command: parts}, nil
+}
+
+// Get the password at path, or the value of key in it
+func (p *passProvider) Get(path, key string) (string, error) {
+	name := filepath.Join(p.dir, filepath.FromSlash(path)+".gpg")
+	var stderr bytes.Buffer
+	cmd := exec.Command(p.command[0], append(p.command[1:], name)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Unable to decrypt %s: %s %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if key == "" {
+		return strings.TrimSuffix(lines[0], "\r"), nil
+	}
+	for _, line := range lines[1:] {
+		parts := strings.SplitN(strings.TrimSuffix(line, "\r"), ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return strings.TrimSpace(parts[1]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no %s", path, key)
+}
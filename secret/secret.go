@@ -0,0 +1,242 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package secret resolves the secret:// references in the pipeline
+// environment with the providers that keep the secrets.
+package secret
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wercker/wercker/util"
+)
+
+// Scheme starts a reference to a secret, secret://provider/path#key
+const Scheme = "secret://"
+
+// A Provider looks up secrets, path is the secret and key the value to pick
+// if it has more than one
+type Provider interface {
+	Get(path, key string) (string, error)
+}
+
+// providerFactory makes a provider from the options it is configured with
+type providerFactory func(options map[string]string) (Provider, error)
+
+// providerTypes are the kinds of providers, the providers without config
+// are named after them
+var providerTypes = map[string]providerFactory{
+	"env-file": newEnvFileProvider,
+	"vault":    newVaultProvider,
+	"pass":     newPassProvider,
+}
+
+// ProviderTypes are the names of the kinds of providers
+func ProviderTypes() []string {
+	types := []string{}
+	for t := range providerTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Reference is a parsed secret://provider/path#key
+type Reference struct {
+	Provider string
+	Path     string
+	Key      string
+}
+
+// IsReference is whether s refers to a secret
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+// ParseReference parses a secret://provider/path#key, the key is optional
+func ParseReference(s string) (*Reference, error) {
+	if !IsReference(s) {
+		return nil, fmt.Errorf("Secret reference %q doesn't start with %s", s, Scheme)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid secret reference %q", s)
+	}
+	ref := &Reference{
+		Provider: u.Host,
+		Path:     strings.TrimPrefix(u.Path, "/"),
+		Key:      u.Fragment,
+	}
+	if ref.Provider == "" || ref.Path == "" {
+		return nil, fmt.Errorf("Invalid secret reference %q, expected %sprovider/path#key", s, Scheme)
+	}
+	return ref, nil
+}
+
+// Resolver finds the secrets references refer to. The providers in the
+// secret-providers section of wercker.yml are used by name, the others are
+// named after their type and configured from the host env.
+type Resolver struct {
+	sync.Mutex
+	configs   map[string]map[string]string
+	hostEnv   *util.Environment
+	dir       string
+	providers map[string]Provider
+}
+
+// NewResolver for the providers in configs, options of the providers can
+// use the host env and relative paths are relative to dir
+func NewResolver(configs map[string]map[string]string, hostEnv *util.Environment, dir string) (*Resolver, error) {
+	for name, config := range configs {
+		if _, ok := providerTypes[config["type"]]; !ok {
+			return nil, fmt.Errorf("Unknown type %q of secret provider %s, expected one of %s", config["type"], name, strings.Join(ProviderTypes(), ", "))
+		}
+	}
+	if hostEnv == nil {
+		hostEnv = util.NewEnvironment()
+	}
+	return &Resolver{
+		configs:   configs,
+		hostEnv:   hostEnv,
+		dir:       dir,
+		providers: map[string]Provider{},
+	}, nil
+}
+
+// defaultOptions configures the providers from the host env
+func (r *Resolver) defaultOptions(providerType string) map[string]string {
+	switch providerType {
+	case "env-file":
+		return map[string]string{
+			"key":      r.hostEnv.Get("WERCKER_SECRETS_KEY"),
+			"identity": r.hostEnv.Get("WERCKER_SECRETS_IDENTITY"),
+		}
+	case "vault":
+		return map[string]string{
+			"address":   r.hostEnv.Get("VAULT_ADDR"),
+			"token":     r.hostEnv.Get("VAULT_TOKEN"),
+			"namespace": r.hostEnv.Get("VAULT_NAMESPACE"),
+		}
+	case "pass":
+		return map[string]string{
+			"dir": r.hostEnv.Get("PASSWORD_STORE_DIR"),
+		}
+	}
+	return map[string]string{}
+}
+
+// provider is the provider called name, made the first time it's used so
+// only the providers that are used need to be configured
+func (r *Resolver) provider(name string) (Provider, error) {
+	r.Lock()
+	defer r.Unlock()
+	if provider, ok := r.providers[name]; ok {
+		return provider, nil
+	}
+
+	providerType := name
+	options := map[string]string{}
+	if config, ok := r.configs[name]; ok {
+		providerType = config["type"]
+		for k, v := range config {
+			options[k] = r.hostEnv.Interpolate(v)
+		}
+	} else if _, ok := providerTypes[name]; ok {
+		options = r.defaultOptions(name)
+	} else {
+		return nil, fmt.Errorf("Unknown secret provider %s", name)
+	}
+	if options["dir"] == "" {
+		options["dir"] = r.dir
+		if providerType == "pass" {
+			options["dir"] = "~/.password-store"
+		}
+	}
+	options["dir"] = util.ExpandHomePath(options["dir"], r.hostEnv.Get("HOME"))
+	if !filepath.IsAbs(options["dir"]) {
+		options["dir"] = filepath.Join(r.dir, options["dir"])
+	}
+
+	provider, err := providerTypes[providerType](options)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to set up secret provider %s: %s", name, err)
+	}
+	r.providers[name] = provider
+	return provider, nil
+}
+
+// Resolve the secret ref refers to
+func (r *Resolver) Resolve(ref string) (string, error) {
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	provider, err := r.provider(parsed.Provider)
+	if err != nil {
+		return "", err
+	}
+	return provider.Get(parsed.Path, parsed.Key)
+}
+
+// ResolveEnvironment replaces the references in env with the secrets they
+// refer to, moving them to the hidden env so they are kept out of the logs
+func (r *Resolver) ResolveEnvironment(env *util.Environment) error {
+	if env.Hidden == nil {
+		env.Hidden = util.NewEnvironment()
+	}
+	for _, pair := range env.Ordered() {
+		if !IsReference(pair[1]) {
+			continue
+		}
+		value, err := r.Resolve(pair[1])
+		if err != nil {
+			return fmt.Errorf("Unable to resolve secret %s: %s", pair[0], err)
+		}
+		env.Remove(pair[0])
+		env.Hidden.Add(pair[0], value)
+	}
+	for _, pair := range env.Hidden.Ordered() {
+		if !IsReference(pair[1]) {
+			continue
+		}
+		value, err := r.Resolve(pair[1])
+		if err != nil {
+			return fmt.Errorf("Unable to resolve secret %s: %s", pair[0], err)
+		}
+		env.Hidden.Add(pair[0], value)
+	}
+	return nil
+}
+
+// pick is the value of key in values, or the only value if key is empty
+func pick(values map[string]string, key, path string) (string, error) {
+	if key == "" {
+		if len(values) == 1 {
+			for _, value := range values {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%s has %d values, pick one with #key", path, len(values))
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("%s has no %s", path, key)
+	}
+	return value, nil
+}
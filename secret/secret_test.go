@@ -0,0 +1,157 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package secret
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/wercker/wercker/util"
+)
+
+type SecretSuite struct {
+	*util.TestSuite
+}
+
+func TestSecretSuite(t *testing.T) {
+	suiteTester := &SecretSuite{&util.TestSuite{}}
+	suite.Run(t, suiteTester)
+}
+
+func (s *SecretSuite) TestParseReference() {
+	ref, err := ParseReference("secret://vault/ci/deploy#token")
+	s.Require().NoError(err)
+	s.Equal(&Reference{Provider: "vault", Path: "ci/deploy", Key: "token"}, ref)
+
+	ref, err = ParseReference("secret://pass/ci/registry")
+	s.Require().NoError(err)
+	s.Equal("", ref.Key)
+
+	_, err = ParseReference("secret://vault")
+	s.Error(err)
+	_, err = ParseReference("vault/ci/deploy")
+	s.Error(err)
+}
+
+func (s *SecretSuite) TestEncrypt() {
+	key, err := GenerateKey()
+	s.Require().NoError(err)
+	identity, recipient, err := GenerateIdentity()
+	s.Require().NoError(err)
+	other, _, err := GenerateIdentity()
+	s.Require().NoError(err)
+
+	data, err := Encrypt([]byte("TOKEN=s3cr3t\n"), []string{key}, []string{recipient})
+	s.Require().NoError(err)
+
+	plaintext, err := Decrypt(data, []string{key}, nil)
+	s.Require().NoError(err)
+	s.Equal("TOKEN=s3cr3t\n", string(plaintext))
+
+	plaintext, err = Decrypt(data, nil, []string{other, identity})
+	s.Require().NoError(err)
+	s.Equal("TOKEN=s3cr3t\n", string(plaintext))
+
+	_, err = Decrypt(data, nil, []string{other})
+	s.Error(err)
+}
+
+func (s *SecretSuite) TestResolveEnvFile() {
+	dir := s.WorkingDir()
+
+	key, err := GenerateKey()
+	s.Require().NoError(err)
+	data, err := Encrypt([]byte("TOKEN=s3cr3t\nUSER=deploy\n"), []string{key}, nil)
+	s.Require().NoError(err)
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(dir, "secrets.env"), data, 0600))
+
+	hostEnv := util.NewEnvironment("WERCKER_SECRETS_KEY=" + key)
+	resolver, err := NewResolver(nil, hostEnv, dir)
+	s.Require().NoError(err)
+
+	env := util.NewEnvironment("PLAIN=value", "TOKEN=secret://env-file/secrets.env#TOKEN")
+	env.Hidden.Add("USER", "secret://env-file/secrets.env#USER")
+	s.Require().NoError(resolver.ResolveEnvironment(env))
+	s.Equal([][]string{{"PLAIN", "value"}}, env.Ordered())
+	s.Equal("deploy", env.Hidden.Get("USER"))
+	s.Equal("s3cr3t", env.Hidden.Get("TOKEN"))
+
+	env = util.NewEnvironment("TOKEN=secret://env-file/secrets.env#MISSING")
+	err = resolver.ResolveEnvironment(env)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to resolve secret TOKEN")
+}
+
+func (s *SecretSuite) TestResolveVault() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/ci/deploy":
+			w.Write([]byte(`{"data": {"data": {"token": "s3cr3t", "port": 22}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	hostEnv := util.NewEnvironment("ROOT_TOKEN=root")
+	resolver, err := NewResolver(map[string]map[string]string{
+		"ci": {"type": "vault", "address": server.URL, "token": "${ROOT_TOKEN}", "mount": "kv"},
+	}, hostEnv, "")
+	s.Require().NoError(err)
+
+	value, err := resolver.Resolve("secret://ci/ci/deploy#token")
+	s.Require().NoError(err)
+	s.Equal("s3cr3t", value)
+	value, err = resolver.Resolve("secret://ci/ci/deploy#port")
+	s.Require().NoError(err)
+	s.Equal("22", value)
+
+	_, err = resolver.Resolve("secret://ci/ci/deploy")
+	s.Error(err)
+	_, err = resolver.Resolve("secret://ci/ci/missing#token")
+	s.Error(err)
+	_, err = resolver.Resolve("secret://unknown/ci/deploy#token")
+	s.Error(err)
+
+	_, err = NewResolver(map[string]map[string]string{"ci": {"type": "keychain"}}, hostEnv, "")
+	s.Error(err)
+}
+
+func (s *SecretSuite) TestResolvePass() {
+	dir := s.WorkingDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "ci"), 0700))
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(dir, "ci", "registry.gpg"), []byte("hunter22\nuser: deploy\n"), 0600))
+
+	resolver, err := NewResolver(map[string]map[string]string{
+		"store": {"type": "pass", "dir": dir, "command": "cat"},
+	}, nil, "")
+	s.Require().NoError(err)
+
+	value, err := resolver.Resolve("secret://store/ci/registry")
+	s.Require().NoError(err)
+	s.Equal("hunter22", value)
+	value, err = resolver.Resolve("secret://store/ci/registry#user")
+	s.Require().NoError(err)
+	s.Equal("deploy", value)
+}
@@ -0,0 +1,135 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultProvider gets secrets from the KV secrets engine of a Vault (or
+// something with the same HTTP API), the path is the secret and the key
+// the field of it
+type vaultProvider struct {
+	sync.Mutex
+	address   string
+	token     string
+	namespace string
+	mount     string
+	version   string
+	client    *http.Client
+	secrets   map[string]map[string]string
+}
+
+// newVaultProvider is configured with the address of the Vault and a token,
+// the mount of the KV engine defaults to secret and its version to 2
+func newVaultProvider(options map[string]string) (Provider, error) {
+	p := &vaultProvider{
+		address:   strings.TrimSuffix(options["address"], "/"),
+		token:     options["token"],
+		namespace: options["namespace"],
+		mount:     strings.Trim(options["mount"], "/"),
+		version:   options["version"],
+		client:    &http.Client{Timeout: 10 * time.Second},
+		secrets:   map[string]map[string]string{},
+	}
+	if p.address == "" {
+		return nil, errors.New("Needs an address, set VAULT_ADDR")
+	}
+	if p.token == "" {
+		return nil, errors.New("Needs a token, set VAULT_TOKEN")
+	}
+	if p.mount == "" {
+		p.mount = "secret"
+	}
+	if p.version == "" {
+		p.version = "2"
+	}
+	if p.version != "1" && p.version != "2" {
+		return nil, fmt.Errorf("Unknown KV version %s, expected 1 or 2", p.version)
+	}
+	return p, nil
+}
+
+// read the secret at path, with KV version 2 its fields are wrapped in the
+// data of the data
+func (p *vaultProvider) read(path string) (map[string]string, error) {
+	url := fmt.Sprintf("%s/v1/%s/%s", p.address, p.mount, path)
+	if p.version == "2" {
+		url = fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, path)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to read %s from Vault: %s", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	fields := body.Data
+	if p.version == "2" {
+		fields = map[string]json.RawMessage{}
+		if err := json.Unmarshal(body.Data["data"], &fields); err != nil {
+			return nil, fmt.Errorf("Unable to read %s from Vault: %s", path, err)
+		}
+	}
+
+	values := map[string]string{}
+	for k, raw := range fields {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			values[k] = s
+		} else {
+			values[k] = string(raw)
+		}
+	}
+	return values, nil
+}
+
+// Get the field key of the secret at path
+func (p *vaultProvider) Get(path, key string) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	values, ok := p.secrets[path]
+	if !ok {
+		var err error
+		values, err = p.read(path)
+		if err != nil {
+			return "", err
+		}
+		p.secrets[path] = values
+	}
+	return pick(values, key, path)
+}
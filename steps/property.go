@@ -0,0 +1,126 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package steps
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Property types a step can declare in its step.yml, the short aliases are
+// accepted as well since older steps use them.
+const (
+	PropertyTypeString  = "string"
+	PropertyTypeInteger = "integer"
+	PropertyTypeNumber  = "number"
+	PropertyTypeBoolean = "boolean"
+	PropertyTypeArray   = "array"
+	PropertyTypeObject  = "object"
+)
+
+var propertyTypeAliases = map[string]string{
+	"":        PropertyTypeString,
+	"string":  PropertyTypeString,
+	"int":     PropertyTypeInteger,
+	"integer": PropertyTypeInteger,
+	"number":  PropertyTypeNumber,
+	"float":   PropertyTypeNumber,
+	"bool":    PropertyTypeBoolean,
+	"boolean": PropertyTypeBoolean,
+	"array":   PropertyTypeArray,
+	"list":    PropertyTypeArray,
+	"object":  PropertyTypeObject,
+	"map":     PropertyTypeObject,
+}
+
+// NormalizePropertyType returns the canonical name for a property type and
+// whether it is one we know about.
+func NormalizePropertyType(t string) (string, bool) {
+	normalized, ok := propertyTypeAliases[t]
+	return normalized, ok
+}
+
+// CheckPropertyValue checks that value, as it was parsed from the yaml, is
+// acceptable for a property of type t. Scalars written as strings are fine
+// for the scalar types as long as they parse, unknown types accept anything.
+func CheckPropertyValue(t string, value interface{}) error {
+	normalized, ok := NormalizePropertyType(t)
+	if !ok || value == nil {
+		return nil
+	}
+
+	switch normalized {
+	case PropertyTypeString:
+		switch value.(type) {
+		case []interface{}, map[string]interface{}:
+			return fmt.Errorf("expected a string, got %s", describePropertyValue(value))
+		}
+		return nil
+	case PropertyTypeInteger:
+		switch v := value.(type) {
+		case int, int32, int64, uint64:
+			return nil
+		case string:
+			if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected an integer, got %s", describePropertyValue(value))
+	case PropertyTypeNumber:
+		switch v := value.(type) {
+		case int, int32, int64, uint64, float32, float64:
+			return nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected a number, got %s", describePropertyValue(value))
+	case PropertyTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return nil
+		case string:
+			if _, err := strconv.ParseBool(v); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected a boolean, got %s", describePropertyValue(value))
+	case PropertyTypeArray:
+		if _, ok := value.([]interface{}); ok {
+			return nil
+		}
+		return fmt.Errorf("expected a list, got %s", describePropertyValue(value))
+	case PropertyTypeObject:
+		if _, ok := value.(map[string]interface{}); ok {
+			return nil
+		}
+		return fmt.Errorf("expected a map, got %s", describePropertyValue(value))
+	}
+	return nil
+}
+
+func describePropertyValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "a map"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
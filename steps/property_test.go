@@ -0,0 +1,93 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckPropertyValue_Valid(t *testing.T) {
+	tests := []struct {
+		propertyType string
+		value        interface{}
+	}{
+		{"string", "foo"},
+		{"string", 12},
+		{"", true},
+		{"int", 12},
+		{"integer", "12"},
+		{"number", 1.5},
+		{"number", "1.5"},
+		{"bool", true},
+		{"boolean", "false"},
+		{"array", []interface{}{"a", "b"}},
+		{"list", []interface{}{}},
+		{"object", map[string]interface{}{"a": 1}},
+		{"map", map[string]interface{}{}},
+		{"password", []interface{}{"unknown types accept anything"}},
+		{"array", nil},
+	}
+
+	for _, test := range tests {
+		err := CheckPropertyValue(test.propertyType, test.value)
+		assert.NoError(t, err, "%s should accept %v", test.propertyType, test.value)
+	}
+}
+
+func Test_CheckPropertyValue_Invalid(t *testing.T) {
+	tests := []struct {
+		propertyType string
+		value        interface{}
+		expected     string
+	}{
+		{"string", []interface{}{"a"}, "expected a string, got a list"},
+		{"int", "twelve", `expected an integer, got "twelve"`},
+		{"integer", 1.5, "expected an integer, got 1.5"},
+		{"number", "many", `expected a number, got "many"`},
+		{"bool", "yes please", `expected a boolean, got "yes please"`},
+		{"array", "a b c", `expected a list, got "a b c"`},
+		{"object", []interface{}{"a"}, "expected a map, got a list"},
+	}
+
+	for _, test := range tests {
+		err := CheckPropertyValue(test.propertyType, test.value)
+		if assert.Error(t, err, "%s should not accept %v", test.propertyType, test.value) {
+			assert.Equal(t, test.expected, err.Error())
+		}
+	}
+}
+
+func Test_ValidateManifest_PropertyTypes(t *testing.T) {
+	manifest := &StepManifest{
+		Name:    "typed",
+		Version: "1.0.0",
+		Summary: "A step with typed properties",
+		Properties: []*StepProperty{
+			{Name: "files", Type: "array"},
+			{Name: "args", Type: "map"},
+			{Name: "verbose", Type: "bool"},
+		},
+	}
+	assert.NoError(t, ValidateManifest(manifest))
+
+	// Unknown types accept anything, so they don't make the step invalid
+	manifest.Properties = append(manifest.Properties, &StepProperty{Name: "size", Type: "huge"})
+	assert.NoError(t, ValidateManifest(manifest))
+
+	manifest.Version = "one"
+	assert.Error(t, ValidateManifest(manifest))
+}
@@ -16,6 +16,7 @@ package steps
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/blang/semver"
 	"github.com/wercker/wercker/util"
@@ -45,5 +46,13 @@ func ValidateManifest(manifest *StepManifest) error {
 		e = append(e, errors.New("Version does not appear to be valid semver"))
 	}
 
+	// Unknown types accept any value, like CheckPropertyValue does, so they
+	// only get a warning
+	for _, property := range manifest.Properties {
+		if _, ok := NormalizePropertyType(property.Type); !ok {
+			util.RootLogger().Warnln(fmt.Sprintf("Property %s has an unknown type %s, it will accept any value", property.Name, property.Type))
+		}
+	}
+
 	return util.SqaushErrors(e)
 }
@@ -0,0 +1,361 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DotenvError is a syntax error in an environment file, Line is 1-based
+type DotenvError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *DotenvError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+var dotenvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// dotenvParser reads the key=value lines of an environment file:
+//   # comments and blank lines are skipped
+//   export KEY=value        # export is optional, so are inline comments
+//   SINGLE='kept as is, $NOT expanded'
+//   DOUBLE="escapes \n \t \" \\ \$, $KEY and ${KEY} references
+//   and newlines are all fine"
+//   UNQUOTED=${KEY}/bin     # only ${KEY} is a reference here, a$b is kept
+// References are to keys set earlier in the file or, failing that, to
+// whatever lookup finds.
+type dotenvParser struct {
+	file   string
+	src    string
+	pos    int
+	line   int
+	env    *Environment
+	lookup func(string) string
+}
+
+// ParseDotenv parses the environment file src, the keys it doesn't set
+// itself are looked up with lookup
+func ParseDotenv(file, src string, lookup func(string) string) (*Environment, error) {
+	p := &dotenvParser{
+		file:   file,
+		src:    src,
+		line:   1,
+		env:    NewEnvironment(),
+		lookup: lookup,
+	}
+	for !p.eof() {
+		p.skipSpace()
+		switch {
+		case p.eof():
+		case p.eol():
+			p.newline()
+		case p.peek() == '#':
+			p.skipLine()
+		default:
+			if err := p.assignment(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p.env, nil
+}
+
+func (p *dotenvParser) errorf(line int, format string, args ...interface{}) error {
+	return &DotenvError{File: p.file, Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *dotenvParser) eol() bool {
+	return p.eof() || p.peek() == '\n' || p.peek() == '\r'
+}
+
+func (p *dotenvParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// newline moves past \n, \r\n or \r
+func (p *dotenvParser) newline() {
+	if p.eof() {
+		return
+	}
+	if p.peek() == '\r' {
+		p.pos++
+		if !p.eof() && p.peek() == '\n' {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+	p.line++
+}
+
+func (p *dotenvParser) skipLine() {
+	for !p.eol() {
+		p.pos++
+	}
+	p.newline()
+}
+
+// word reads up to the next space, = or end of line
+func (p *dotenvParser) word() string {
+	start := p.pos
+	for !p.eol() && !strings.ContainsRune(" \t=", rune(p.peek())) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *dotenvParser) assignment() error {
+	line := p.line
+	key := p.word()
+	if key == "export" && !p.eol() && p.peek() != '=' {
+		p.skipSpace()
+		key = p.word()
+	}
+	if !dotenvKey.MatchString(key) {
+		return p.errorf(line, "Invalid key %q", key)
+	}
+	p.skipSpace()
+	if p.eol() || p.peek() != '=' {
+		return p.errorf(line, "Expected = after %s", key)
+	}
+	p.pos++
+	p.skipSpace()
+
+	var value string
+	var err error
+	switch {
+	case p.eol():
+	case p.peek() == '\'':
+		value, err = p.singleQuoted(key)
+	case p.peek() == '"':
+		value, err = p.doubleQuoted(key)
+	default:
+		value, err = p.unquoted(key)
+	}
+	if err != nil {
+		return err
+	}
+	p.env.Add(key, value)
+
+	// Only a comment may follow the value
+	p.skipSpace()
+	if !p.eol() && p.peek() != '#' {
+		return p.errorf(p.line, "Unexpected %q after the value of %s", p.src[p.pos:p.pos+1], key)
+	}
+	p.skipLine()
+	return nil
+}
+
+func (p *dotenvParser) singleQuoted(key string) (string, error) {
+	line := p.line
+	end := strings.IndexByte(p.src[p.pos+1:], '\'')
+	if end < 0 {
+		return "", p.errorf(line, "Unterminated single-quoted value of %s", key)
+	}
+	value := strings.Replace(p.src[p.pos+1:p.pos+1+end], "\r\n", "\n", -1)
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 2
+	return value, nil
+}
+
+func (p *dotenvParser) doubleQuoted(key string) (string, error) {
+	line := p.line
+	var b bytes.Buffer
+	p.pos++
+	for {
+		if p.eof() {
+			return "", p.errorf(line, "Unterminated double-quoted value of %s", key)
+		}
+		c := p.peek()
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch e := p.peek(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(e)
+			case '\n', '\r':
+				// An escaped newline continues the line
+				p.newline()
+				continue
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+			p.pos++
+		case c == '$':
+			value, n, err := p.reference(p.src[p.pos:], key)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			p.pos += n
+		case c == '\n' || c == '\r':
+			b.WriteByte('\n')
+			p.newline()
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// unquoted values run until the end of the line or a # after a space. Only
+// ${KEY} is expanded in them, environment files used to be read without
+// expanding anything so a $ in a password stays as it is.
+func (p *dotenvParser) unquoted(key string) (string, error) {
+	start := p.pos
+	for !p.eol() {
+		if p.peek() == '#' && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		p.pos++
+	}
+	raw := strings.TrimRight(p.src[start:p.pos], " \t")
+	var b bytes.Buffer
+	for i := 0; i < len(raw); {
+		if !strings.HasPrefix(raw[i:], "${") {
+			b.WriteByte(raw[i])
+			i++
+			continue
+		}
+		value, n, err := p.reference(raw[i:], key)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+		i += n
+	}
+	return b.String(), nil
+}
+
+// reference expands the $VAR or ${VAR} s starts with, n is how much of s
+// it takes up. A $ that doesn't start a reference is kept.
+func (p *dotenvParser) reference(s, key string) (value string, n int, err error) {
+	if strings.HasPrefix(s, "${") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0, p.errorf(p.line, "Unterminated ${ in the value of %s", key)
+		}
+		name := s[2:end]
+		if !dotenvKey.MatchString(name) {
+			return "", 0, p.errorf(p.line, "Invalid reference ${%s} in the value of %s", name, key)
+		}
+		return p.get(name), end + 1, nil
+	}
+	n = 1
+	for n < len(s) && (s[n] == '_' || isAlnum(s[n])) {
+		n++
+	}
+	if n == 1 {
+		return "$", 1, nil
+	}
+	return p.get(s[1:n]), n, nil
+}
+
+func (p *dotenvParser) get(name string) string {
+	if value, ok := p.env.Map[name]; ok {
+		return value
+	}
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+	return ""
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// parseEnvFile parses src as a dotenv file. A file that isn't valid dotenv
+// is read the way environment files were read before instead, so the files
+// that loaded then still load the same, with a warning.
+func parseEnvFile(file, src string, lookup func(string) string) *Environment {
+	env, err := ParseDotenv(file, src, lookup)
+	if err == nil {
+		return env
+	}
+	RootLogger().WithField("Logger", "Environment").Warnln(fmt.Sprintf(
+		"%s, loading it as plain key=value lines. This will be an error in a future release.", err))
+	return parseLegacyEnv(src)
+}
+
+// parseLegacyEnv reads every key=value line of src as it is, only the
+// quotes around a value are stripped and the \" and \n in it expanded.
+// Comments and lines without a = are skipped.
+func parseLegacyEnv(src string) *Environment {
+	env := NewEnvironment()
+	s := bufio.NewScanner(strings.NewReader(src))
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		parts := strings.SplitN(s.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if _, ok := env.Map[parts[0]]; ok {
+			continue
+		}
+		env.Add(parts[0], trimLegacyValue(parts[1]))
+	}
+	return env
+}
+
+func trimLegacyValue(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 1 {
+		f := s[0:1]
+		l := s[len(s)-1:]
+		if f == l && strings.ContainsAny(f, `"'`) {
+			// strip surrounding quotes
+			s = s[1 : len(s)-1]
+
+			// now expand escaped double quotes and newlines
+			s = strings.Replace(s, `\"`, `"`, -1)
+			s = strings.Replace(s, `\n`, "\n", -1)
+		}
+	}
+	return s
+}
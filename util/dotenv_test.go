@@ -0,0 +1,129 @@
+//   Copyright © 2018, Oracle and/or its affiliates.  All rights reserved.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DotenvSuite struct {
+	TestSuite
+}
+
+func TestDotenvSuite(t *testing.T) {
+	suiteTester := new(DotenvSuite)
+	suite.Run(t, suiteTester)
+}
+
+func (s *DotenvSuite) TestParse() {
+	env, err := ParseDotenv("", `# comment
+export HOST=example.com
+PORT = 8080   # inline comment
+URL=https://${HOST}:${PORT}/path#anchor
+PASSWORD=ab$cd
+SINGLE='no $HOST \n here' # comment
+DOUBLE="tab\there \"quoted\" \$HOST ${HOST}"
+MULTI="first
+second"
+JOINED="one \
+two"
+CERT='-----BEGIN-----
+abc
+-----END-----'
+EMPTY=
+HOME_BIN=${HOME}/bin
+`, func(key string) string {
+		return map[string]string{"HOME": "/root"}[key]
+	})
+	s.Require().NoError(err)
+	s.Equal([][]string{
+		{"HOST", "example.com"},
+		{"PORT", "8080"},
+		{"URL", "https://example.com:8080/path#anchor"},
+		{"PASSWORD", "ab$cd"},
+		{"SINGLE", `no $HOST \n here`},
+		{"DOUBLE", "tab\there \"quoted\" $HOST example.com"},
+		{"MULTI", "first\nsecond"},
+		{"JOINED", "one two"},
+		{"CERT", "-----BEGIN-----\nabc\n-----END-----"},
+		{"EMPTY", ""},
+		{"HOME_BIN", "/root/bin"},
+	}, env.Ordered())
+}
+
+func (s *DotenvSuite) TestParseErrors() {
+	cases := map[string]string{
+		"A=1\nB\n":                 "test.env:2: Expected = after B",
+		"A=1\n\n1A=2\n":            `test.env:3: Invalid key "1A"`,
+		"A=1\nB=\"open\nC=2\n":     "test.env:2: Unterminated double-quoted value of B",
+		"A='open\n":                "test.env:1: Unterminated single-quoted value of A",
+		"A=\"x\ny\" z\n":           `test.env:2: Unexpected "z" after the value of A`,
+		"A=1\r\nB=${C\r\n":         "test.env:2: Unterminated ${ in the value of B",
+		"export A=1\nexport B 2\n": "test.env:2: Expected = after B",
+	}
+	for src, expected := range cases {
+		_, err := ParseDotenv("test.env", src, nil)
+		s.Require().Error(err, src)
+		s.Equal(expected, err.Error(), src)
+	}
+}
+
+func (s *DotenvSuite) TestLoadFiles() {
+	dir := s.WorkingDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	s.Require().NoError(ioutil.WriteFile(base, []byte("A=base\nB=base\nUSER=base\n"), 0644))
+	s.Require().NoError(ioutil.WriteFile(local, []byte("B=local-${A}\nC=${USER}\n"), 0644))
+
+	env := NewEnvironment("USER=me")
+	s.Require().NoError(env.LoadFiles(base, local))
+	s.Equal([][]string{
+		{"USER", "me"},
+		{"A", "base"},
+		{"B", "local-base"},
+		{"C", "base"},
+	}, env.Ordered())
+
+	// Files that aren't valid dotenv are loaded the way they used to be
+	s.Require().NoError(ioutil.WriteFile(local, []byte("B='broken\nnot a pair\nC=${A}\n"), 0644))
+	env = NewEnvironment()
+	s.Require().NoError(env.LoadFiles(base, local))
+	s.Equal("'broken", env.Get("B"))
+	s.Equal("${A}", env.Get("C"))
+	s.Equal([]string{"A", "B", "USER", "C"}, env.Order)
+}
+
+func (s *DotenvSuite) TestParseLegacy() {
+	env := parseLegacyEnv(`# comment
+A=1
+B="quoted \"value\""
+C='single'
+D="
+E=ab$cd
+not a pair
+A=2
+`)
+	s.Equal([][]string{
+		{"A", "1"},
+		{"B", `quoted "value"`},
+		{"C", "single"},
+		{"D", `"`},
+		{"E", "ab$cd"},
+	}, env.Ordered())
+}
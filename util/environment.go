@@ -15,8 +15,9 @@
 package util
 
 import (
-	"bufio"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -66,6 +67,20 @@ func (e *Environment) Add(key, value string) {
 	e.Map[key] = value
 }
 
+// Remove an individual record.
+func (e *Environment) Remove(key string) {
+	if _, ok := e.Map[key]; !ok {
+		return
+	}
+	delete(e.Map, key)
+	for i, k := range e.Order {
+		if k == key {
+			e.Order = append(e.Order[:i], e.Order[i+1:]...)
+			break
+		}
+	}
+}
+
 // Get an individual record.
 func (e *Environment) Get(key string) string {
 	if e.Map != nil {
@@ -159,55 +174,53 @@ func (e *Environment) GetInclHidden(key string) string {
 	return ""
 }
 
-// LoadFile imports key,val pairs from the provided file path. File entries
-// should be 1 per line in the form key=value. Blank lines and lines begining
-// with # are ignored.
+// LoadFile imports key,val pairs from the provided file path, see
+// LoadFiles.
 func (e *Environment) LoadFile(f string) error {
-	file, err := os.Open(f)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	s := bufio.NewScanner(file)
-	for ok := s.Scan(); ok; ok = s.Scan() {
-		// Ignore comments
-		if strings.HasPrefix(s.Text(), "#") {
-			continue
+	return e.LoadFiles(f)
+}
+
+// LoadFiles imports key,val pairs from environment files in dotenv syntax,
+// the values of the later files override the ones of the earlier files.
+// Keys that are already set in the environment are left as they are, the
+// files can refer to them with ${KEY} though. A file that isn't valid
+// dotenv is loaded as plain key=value lines with a warning.
+func (e *Environment) LoadFiles(files ...string) error {
+	loaded := NewEnvironment()
+	lookup := func(key string) string {
+		if value, ok := loaded.Map[key]; ok {
+			return value
 		}
-		parts := strings.SplitN(s.Text(), "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key, val := parts[0], parts[1]
-		// Don't override existing environment
-		if e.Get(key) != "" {
-			continue
+		return e.Get(key)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
 		}
-
-		val = trim(val)
-
-		e.Add(key, val)
+		loaded.Update(parseEnvFile(f, string(b), lookup).Ordered())
 	}
-
+	e.addMissing(loaded)
 	return nil
 }
 
-func trim(s string) string {
-	s = strings.TrimSpace(s)
-
-	if len(s) > 1 {
-		f := string(s[0:1])
-		l := string(s[len(s)-1:])
-		if f == l && strings.ContainsAny(f, `"'`) {
-			// strip surrounding quotes
-			s = string(s[1 : len(s)-1])
+// Load imports key,val pairs from r like LoadFile does from a file.
+func (e *Environment) Load(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	e.addMissing(parseEnvFile("", string(b), e.Get))
+	return nil
+}
 
-			// now expand escaped double quotes and newlines
-			s = strings.Replace(s, `\"`, `"`, -1)
-			s = strings.Replace(s, `\n`, "\n", -1)
+// addMissing adds the pairs of env that aren't set yet
+func (e *Environment) addMissing(env *Environment) {
+	for _, pair := range env.Ordered() {
+		// Don't override existing environment
+		if e.Get(pair[0]) != "" {
+			continue
 		}
+		e.Add(pair[0], pair[1])
 	}
-
-	return s
 }
@@ -107,3 +107,19 @@ func (s *EnvironmentSuite) TestLoadFile() {
 	s.Equal(expected, env.Ordered(), "LoadFile should maintain order.")
 	s.Equal([]string{"PUBLIC", "A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M", "N"}, env.Order, "LoadFile should maintain ordered keys.")
 }
+
+func (s *EnvironmentSuite) TestLoadDotenvFile() {
+	env := NewEnvironment("HOST=localhost")
+	err := env.LoadFile("../tests/environment-test-load-dotenv.env")
+	s.Require().NoError(err)
+	s.Equal([][]string{
+		[]string{"HOST", "localhost"},
+		[]string{"PORT", "8080"},
+		[]string{"URL", "https://example.com:8080"},
+		[]string{"PASSWORD", "ab$cd"},
+		[]string{"SINGLE", "no ${HOST} here"},
+		[]string{"MULTI", "first\nsecond"},
+		[]string{"JOINED", "one two"},
+		[]string{"ESCAPES", "tab\t\"quoted\""},
+	}, env.Ordered())
+}
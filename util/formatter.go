@@ -16,6 +16,7 @@ package util
 
 import (
 	"fmt"
+	"hash/fnv"
 	"strings"
 )
 
@@ -26,6 +27,19 @@ const (
 	reset        = "\x1b[m"
 )
 
+// labelColors are picked from for the labels in front of the lines of
+// output from different sources, like services
+var labelColors = []string{
+	"\x1b[36m", // cyan
+	"\x1b[35m", // magenta
+	"\x1b[34m", // blue
+	"\x1b[33m", // yellow
+	"\x1b[32m", // green
+	"\x1b[96m", // bright cyan
+	"\x1b[95m", // bright magenta
+	"\x1b[94m", // bright blue
+}
+
 // Formatter formats the messages, and optionally disabling colors. See
 // FormatMessage for the structure of messages.
 type Formatter struct {
@@ -47,6 +61,18 @@ func (f *Formatter) Fail(messages ...string) string {
 	return FormatMessage(failColor, f.ShowColors, messages...)
 }
 
+// Label formats name as the "[name] " in front of lines of output. The color
+// is picked by name, so the same name always gets the same color.
+func (f *Formatter) Label(name string) string {
+	if !f.ShowColors {
+		return fmt.Sprintf("[%s] ", name)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	color := labelColors[h.Sum32()%uint32(len(labelColors))]
+	return fmt.Sprintf("%s[%s]%s ", color, name, reset)
+}
+
 // FormatMessage handles one or two messages. If more messages are used, those
 // are ignore. If no messages are used, than it will return an empty string.
 // 1 message : --> message[0]